@@ -0,0 +1,121 @@
+// Copyright 2011 The Go Authors.  All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package qr
+
+// Artwork blending for QR codes.
+
+import (
+	"image"
+	"image/color"
+	"math"
+)
+
+// ArtBands sets the luminance bands used by Blend: pixels forced dark
+// have a relative luminance of at most dark, and pixels forced light
+// at least light, both between 0 and 1.  The default bands are 0.1
+// and 0.5, a contrast ratio of about 3.7.
+func ArtBands(dark, light float64) RenderOption {
+	return func(r *render) { r.artDark, r.artLight = dark, light }
+}
+
+// ArtCenter sets the fraction of each data pixel's width, centered
+// in the pixel, that Blend forces to the pixel's color.  The default
+// is 1/3.  Larger values scan more reliably but show less artwork.
+func ArtCenter(fraction float64) RenderOption {
+	return func(r *render) { r.artCenter = fraction }
+}
+
+// Blend returns an image of the code drawn over art, which is stretched
+// to cover the whole image including the margin.  The center of each
+// data and error correction pixel, as set by ArtCenter, is adjusted to
+// fall in the dark or light band set by ArtBands, keeping its hue,
+// while the rest of the pixel shows the artwork unchanged.
+// Function patterns and the quiet zone, which scanners need intact,
+// are adjusted over their whole area.
+//
+// Blend needs ModuleSize of at least 3 to leave room for artwork.
+// Colors, shapes, and gradients are ignored.
+func (c *Code) Blend(art image.Image, opts ...RenderOption) *image.RGBA {
+	r := c.render(opts)
+	d := (c.Size + 2*r.margin) * r.scale
+	dst := image.NewRGBA(image.Rect(0, 0, d, d))
+	ab := art.Bounds()
+	pix := c.pixelMap()
+
+	// Sub-pixels [inset, scale-inset) of each cell are its center.
+	f := r.artCenter
+	if f <= 0 || f > 1 {
+		f = 1.0 / 3
+	}
+	inset := int(float64(r.scale)*(1-f)/2 + 0.5)
+	if 2*inset >= r.scale {
+		inset = (r.scale - 1) / 2
+	}
+
+	for y := 0; y < d; y++ {
+		my, sy := y/r.scale-r.margin, y%r.scale
+		for x := 0; x < d; x++ {
+			mx, sx := x/r.scale-r.margin, x%r.scale
+			var col color.Color = whiteColor
+			if !ab.Empty() {
+				col = art.At(ab.Min.X+x*ab.Dx()/d, ab.Min.Y+y*ab.Dy()/d)
+			}
+			role := c.role(pix, mx, my)
+			center := inset <= sx && sx < r.scale-inset && inset <= sy && sy < r.scale-inset
+			if center || (role != RoleData && role != RoleCheck) {
+				col = r.band(col, c.Black(mx, my))
+			}
+			dst.Set(x, y, col)
+		}
+	}
+	return dst
+}
+
+// band returns col, composited over white, adjusted to lie in r's
+// dark band if black is set or its light band otherwise.
+func (r *render) band(col color.Color, black bool) color.RGBA {
+	cr, cg, cb, ca := col.RGBA()
+	lin := [3]float64{
+		srgbToLinear(float64(cr+0xffff-ca) / 0xffff),
+		srgbToLinear(float64(cg+0xffff-ca) / 0xffff),
+		srgbToLinear(float64(cb+0xffff-ca) / 0xffff),
+	}
+	y := 0.2126*lin[0] + 0.7152*lin[1] + 0.0722*lin[2]
+	dark, light := r.artDark, r.artLight
+	switch {
+	case black && y > dark:
+		// Scale toward black, which keeps the hue.
+		for i := range lin {
+			lin[i] *= dark / y
+		}
+	case !black && y < light:
+		// Mix toward white.
+		t := (light - y) / (1 - y)
+		for i := range lin {
+			lin[i] += (1 - lin[i]) * t
+		}
+	}
+	return color.RGBA{linearToSRGB(lin[0]), linearToSRGB(lin[1]), linearToSRGB(lin[2]), 0xff}
+}
+
+// srgbToLinear converts an sRGB channel value from 0 to 1 to linear light.
+func srgbToLinear(s float64) float64 {
+	if s <= 0.04045 {
+		return s / 12.92
+	}
+	return math.Pow((s+0.055)/1.055, 2.4)
+}
+
+// linearToSRGB converts a linear light value from 0 to 1 to an 8-bit
+// sRGB channel value.
+func linearToSRGB(l float64) uint8 {
+	var s float64
+	if l <= 0.0031308 {
+		s = l * 12.92
+	} else {
+		s = 1.055*math.Pow(l, 1/2.4) - 0.055
+	}
+	return uint8(math.Max(0, math.Min(255, s*255+0.5)))
+}
@@ -0,0 +1,59 @@
+// Copyright 2011 The Go Authors.  All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package qr
+
+import (
+	"image"
+	"image/color"
+	"testing"
+)
+
+func TestBlend(t *testing.T) {
+	c, err := Encode("hello, world", L)
+	if err != nil {
+		t.Fatal(err)
+	}
+	art := image.NewRGBA(image.Rect(10, 10, 100, 100))
+	for y := 10; y < 100; y++ {
+		for x := 10; x < 100; x++ {
+			art.Set(x, y, color.RGBA{uint8(x * 2), uint8(y * 2), 0x80, 0xff})
+		}
+	}
+	const scale = 9
+	img := c.Blend(art, ModuleSize(scale))
+	m := c.border()
+	d := (c.Size + 2*m) * scale
+	if img.Bounds() != image.Rect(0, 0, d, d) {
+		t.Fatalf("Blend bounds = %v, want %dx%d", img.Bounds(), d, d)
+	}
+	pix := c.pixelMap()
+	kept := 0
+	for y := 0; y < d; y++ {
+		for x := 0; x < d; x++ {
+			mx, my := x/scale-m, y/scale-m
+			role := c.role(pix, mx, my)
+			center := 3 <= x%scale && x%scale < 6 && 3 <= y%scale && y%scale < 6
+			l := luminance(img.At(x, y))
+			switch {
+			case center || (role != RoleData && role != RoleCheck):
+				if c.Black(mx, my) && l > 0.1+0.005 {
+					t.Fatalf("dark pixel %d,%d (%v) has luminance %.3f", x, y, role, l)
+				}
+				if !c.Black(mx, my) && l < 0.5-0.005 {
+					t.Fatalf("light pixel %d,%d (%v) has luminance %.3f", x, y, role, l)
+				}
+			default:
+				want := art.At(10+x*90/d, 10+y*90/d)
+				if !sameColor(img.At(x, y), want) {
+					t.Fatalf("artwork pixel %d,%d = %v, want %v", x, y, img.At(x, y), want)
+				}
+				kept++
+			}
+		}
+	}
+	if kept == 0 {
+		t.Errorf("Blend shows no artwork")
+	}
+}
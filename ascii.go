@@ -0,0 +1,35 @@
+// Copyright 2011 The Go Authors.  All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package qr
+
+// ASCII writer for QR codes.
+
+import "strings"
+
+// ASCII returns a text drawing of the code using only the glyphs set
+// by the Glyphs option, by default "##" for black pixels and two spaces
+// for white ones, suitable for email, source comments, and logs.
+// Each line ends in a newline.  ModuleSize is ignored.
+func (c *Code) ASCII(opts ...RenderOption) string {
+	r := c.render(opts)
+	black, white := r.black, r.white
+	if r.invert {
+		black, white = white, black
+	}
+	d := c.Size + 2*r.margin
+	var b strings.Builder
+	b.Grow(d * (d*len(black) + 1))
+	for y := 0; y < d; y++ {
+		for x := 0; x < d; x++ {
+			if c.Black(x-r.margin, y-r.margin) {
+				b.WriteString(black)
+			} else {
+				b.WriteString(white)
+			}
+		}
+		b.WriteString("\n")
+	}
+	return b.String()
+}
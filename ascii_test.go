@@ -0,0 +1,45 @@
+// Copyright 2011 The Go Authors.  All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package qr
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestASCII(t *testing.T) {
+	c, err := Encode("hello, world", L)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, tt := range []struct {
+		opts         []RenderOption
+		black, white string
+		margin       int
+	}{
+		{nil, "##", "  ", 4},
+		{[]RenderOption{Margin(1), Glyphs("X", ".")}, "X", ".", 1},
+		{[]RenderOption{Margin(0), Glyphs("X", "."), Invert(true)}, ".", "X", 0},
+	} {
+		lines := strings.Split(c.ASCII(tt.opts...), "\n")
+		d := c.Size + 2*tt.margin
+		if len(lines) != d+1 || lines[d] != "" {
+			t.Fatalf("ASCII(%q, %q) has %d lines, want %d", tt.black, tt.white, len(lines)-1, d)
+		}
+		for y, line := range lines[:d] {
+			var want strings.Builder
+			for x := 0; x < d; x++ {
+				if c.Black(x-tt.margin, y-tt.margin) {
+					want.WriteString(tt.black)
+				} else {
+					want.WriteString(tt.white)
+				}
+			}
+			if line != want.String() {
+				t.Errorf("ASCII(%q, %q) line %d = %q, want %q", tt.black, tt.white, y, line, want.String())
+			}
+		}
+	}
+}
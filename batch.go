@@ -0,0 +1,56 @@
+// Copyright 2011 The Go Authors.  All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package qr
+
+import (
+	"fmt"
+	"runtime"
+	"sync"
+)
+
+// EncodeAll encodes each of texts at the given error correction level,
+// using a pool of goroutines.  See Encoder.EncodeAll.
+func EncodeAll(texts []string, level Level) ([]*Code, error) {
+	e, err := NewEncoder(WithLevel(level))
+	if err != nil {
+		return nil, err
+	}
+	return e.EncodeAll(texts)
+}
+
+// EncodeAll encodes each of texts, using one goroutine per CPU.
+// The codes are returned in the same order as texts.
+// If any text cannot be encoded, EncodeAll returns the error for
+// the first such text, and the codes for the others.
+func (e *Encoder) EncodeAll(texts []string) ([]*Code, error) {
+	codes := make([]*Code, len(texts))
+	errs := make([]error, len(texts))
+	n := runtime.GOMAXPROCS(0)
+	if n > len(texts) {
+		n = len(texts)
+	}
+	next := make(chan int)
+	var wg sync.WaitGroup
+	wg.Add(n)
+	for ; n > 0; n-- {
+		go func() {
+			defer wg.Done()
+			for i := range next {
+				codes[i], errs[i] = e.Encode(texts[i])
+			}
+		}()
+	}
+	for i := range texts {
+		next <- i
+	}
+	close(next)
+	wg.Wait()
+	for i, err := range errs {
+		if err != nil {
+			return codes, fmt.Errorf("text %d: %w", i, err)
+		}
+	}
+	return codes, nil
+}
@@ -0,0 +1,91 @@
+// Copyright 2011 The Go Authors.  All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package qr
+
+import (
+	"context"
+
+	"github.com/inkstray/rsc-qr/coding"
+)
+
+// A Builder assembles a QR code from explicitly chosen segments.
+// Each method appends a segment or sets an option and returns
+// the Builder, so that calls can be chained:
+//
+//	c, err := qr.NewBuilder().Numeric("123").Alpha("ABC").Level(qr.Q).Build()
+//
+// Invalid segments are reported by Build.
+type Builder struct {
+	segs []coding.Encoding
+	opts []Option
+}
+
+// NewBuilder returns a new, empty Builder.
+func NewBuilder() *Builder {
+	return new(Builder)
+}
+
+// Numeric appends a numeric segment holding the digits s.
+func (b *Builder) Numeric(s string) *Builder {
+	return b.Segment(coding.Num(s))
+}
+
+// Alpha appends an alphanumeric segment holding s,
+// which may contain 0-9A-Z$%*+-./: and space.
+func (b *Builder) Alpha(s string) *Builder {
+	return b.Segment(coding.Alpha(s))
+}
+
+// String appends an 8-bit segment holding the bytes of s.
+func (b *Builder) String(s string) *Builder {
+	return b.Segment(coding.String(s))
+}
+
+// Bytes appends an 8-bit segment holding data.
+func (b *Builder) Bytes(data []byte) *Builder {
+	return b.Segment(coding.Bytes(data))
+}
+
+// Kanji appends a kanji segment holding s,
+// which may contain characters in JIS X 0208.
+func (b *Builder) Kanji(s string) *Builder {
+	return b.Segment(coding.Kanji(s))
+}
+
+// Segment appends an arbitrary segment.
+func (b *Builder) Segment(e coding.Encoding) *Builder {
+	b.segs = append(b.segs, e)
+	return b
+}
+
+// Level sets the error correction level.
+func (b *Builder) Level(l Level) *Builder {
+	return b.Options(WithLevel(l))
+}
+
+// Options sets additional Encoder options.
+// Segmentation options have no effect on a Builder.
+func (b *Builder) Options(opts ...Option) *Builder {
+	b.opts = append(b.opts, opts...)
+	return b
+}
+
+// Build encodes the segments in the smallest version that holds them.
+func (b *Builder) Build() (*Code, error) {
+	for _, s := range b.segs {
+		if err := s.Check(); err != nil {
+			return nil, err
+		}
+	}
+	e, err := NewEncoder(b.opts...)
+	if err != nil {
+		return nil, err
+	}
+	if len(b.segs) == 0 && e.noEmpty {
+		return nil, ErrEmptyPayload
+	}
+	c, _, err := e.encode(context.Background(), nil, func(int) []coding.Encoding { return b.segs })
+	return c, err
+}
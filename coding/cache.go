@@ -0,0 +1,162 @@
+// Copyright 2011 The Go Authors.  All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package coding
+
+import (
+	"container/list"
+	"fmt"
+	"sync"
+)
+
+// The plan cache holds the Plans used by AutoPlan, which are
+// expensive to build: each holds a bitmap for all 8 masks and
+// a pixel map.  Plans are built on first use and kept until
+// ClearPlanCache is called or, if SetPlanCacheLimit has set a
+// limit, until they are the least recently used plans and
+// the cache is over the limit.
+
+type planKey struct {
+	v Version
+	l Level
+}
+
+// A planEntry is a cached Plan.  The Plan is built by the first
+// goroutine to call once.Do; others wait for it.
+type planEntry struct {
+	key  planKey
+	once sync.Once
+	p    *Plan
+	err  error
+	size int // bytes used by p, or 0 if not yet built
+}
+
+type planCacheType struct {
+	mu    sync.Mutex
+	plans map[planKey]*list.Element // of *planEntry
+	lru   list.List                 // most recently used at front
+	size  int                       // total bytes used by built plans
+	limit int                       // maximum size; 0 for no limit
+}
+
+var planCache planCacheType
+
+func makeAutoPlan(version Version, level Level) (*Plan, error) {
+	if version < MinVersion || version > MaxVersion {
+		return nil, fmt.Errorf("%w %d", ErrBadVersion, int(version))
+	}
+	if level < L || level > H {
+		return nil, fmt.Errorf("%w %d", ErrBadLevel, int(level))
+	}
+	k := planKey{version, level}
+
+	c := &planCache
+	c.mu.Lock()
+	if c.plans == nil {
+		c.plans = make(map[planKey]*list.Element)
+	}
+	var e *planEntry
+	if el, ok := c.plans[k]; ok {
+		c.lru.MoveToFront(el)
+		e = el.Value.(*planEntry)
+	} else {
+		e = &planEntry{key: k}
+		c.plans[k] = c.lru.PushFront(e)
+	}
+	c.mu.Unlock()
+
+	e.once.Do(func() {
+		e.p, e.err = NewPlan(version, level, -1)
+		if e.err != nil {
+			return
+		}
+		c.mu.Lock()
+		if el, ok := c.plans[k]; ok && el.Value == e {
+			e.size = planSize(e.p)
+			c.size += e.size
+			c.evict()
+		}
+		c.mu.Unlock()
+	})
+	return e.p, e.err
+}
+
+// planSize returns the approximate number of bytes used by p.
+func planSize(p *Plan) int {
+	return len(p.Code.Bitmap) + 4*len(p.Pixel)*len(p.Pixel)
+}
+
+// evict removes least recently used plans until the cache
+// is within its limit.  The most recently used plan is always kept.
+// c.mu must be held.
+func (c *planCacheType) evict() {
+	for c.limit > 0 && c.size > c.limit && c.lru.Len() > 1 {
+		c.remove(c.lru.Back())
+	}
+}
+
+// remove removes the plan el from the cache.  c.mu must be held.
+func (c *planCacheType) remove(el *list.Element) {
+	e := c.lru.Remove(el).(*planEntry)
+	delete(c.plans, e.key)
+	c.size -= e.size
+}
+
+// PreloadPlans builds and caches the plans that AutoPlan uses
+// for all combinations of the given versions and levels,
+// so that later calls to Encode do not pay the cost of building them.
+func PreloadPlans(versions []Version, levels []Level) error {
+	for _, v := range versions {
+		for _, l := range levels {
+			if _, err := makeAutoPlan(v, l); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+// ClearPlanCache removes all plans from the plan cache,
+// releasing their memory.  Plans are rebuilt as needed.
+func ClearPlanCache() {
+	c := &planCache
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	for c.lru.Len() > 0 {
+		c.remove(c.lru.Front())
+	}
+}
+
+// SetPlanCacheLimit limits the memory used by the plan cache to about
+// n bytes, evicting the least recently used plans as needed.
+// A limit of 0, the default, means no limit.  The cache always keeps
+// the most recently used plan, which takes from about 2 kB for
+// version 1 to about 160 kB for version 40.
+func SetPlanCacheLimit(n int) {
+	c := &planCache
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.limit = n
+	c.evict()
+}
+
+// PlanCacheSize returns the approximate number of bytes
+// used by the plans in the plan cache.
+func PlanCacheSize() int {
+	c := &planCache
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.size
+}
+
+// PixelMap returns the pixel map of codes with the given version
+// and level, as in Plan.Pixel, using the plan cache.
+// The map is shared and must not be modified.
+func PixelMap(version Version, level Level) ([][]Pixel, error) {
+	p, err := makeAutoPlan(version, level)
+	if err != nil {
+		return nil, err
+	}
+	return p.Pixel, nil
+}
@@ -0,0 +1,167 @@
+// Copyright 2011 The Go Authors.  All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+// Package codingtest supports testing the coding package's encoder
+// and decoder against each other.
+//
+// To fuzz them, add a test file calling FuzzRoundTrip:
+//
+//	func FuzzRoundTrip(f *testing.F) { codingtest.FuzzRoundTrip(f) }
+//
+// and run go test -fuzz=FuzzRoundTrip.  This package's own tests do so.
+package codingtest
+
+import (
+	"errors"
+	"fmt"
+	"reflect"
+	"testing"
+
+	"github.com/inkstray/rsc-qr/coding"
+)
+
+// A Case is one round trip: the segments to encode
+// in a code with the given version, level, and mask.
+type Case struct {
+	Version  coding.Version
+	Level    coding.Level
+	Mask     coding.Mask
+	Segments []coding.Encoding
+}
+
+func (c Case) String() string {
+	return fmt.Sprintf("version %v, level %v, mask %d, %v", c.Version, c.Level, c.Mask, c.Segments)
+}
+
+// Characters for Alpha, Kanji, and Hanzi segments.
+const (
+	alphabet = "0123456789ABCDEFGHIJKLMNOPQRSTUVWXYZ $%*+-./:"
+	kanji    = "日本語漢字点茗東京大阪円年月"
+	hanzi    = "中文汉字测试北京上海码，。（）"
+)
+
+// NewCase derives a Case from data, which can be any bytes, such as
+// those from a fuzzer.  Each byte picks the next choice: the version,
+// level, and mask, then optional structured append and FNC1 headers,
+// then numeric, alphanumeric, byte, Kanji, ECI, and Hanzi segments
+// until data runs out.  The segments are valid but may not fit in
+// the code.
+// They use only the Encoding types that coding.Decode returns, so
+// that a correct round trip returns them unchanged.
+func NewCase(data []byte) Case {
+	next := func() int {
+		if len(data) == 0 {
+			return 0
+		}
+		b := data[0]
+		data = data[1:]
+		return int(b)
+	}
+	c := Case{
+		Version: coding.Version(1 + next()%coding.MaxVersion),
+		Level:   coding.Level(next() % 4),
+		Mask:    coding.Mask(next() % 8),
+	}
+	flags := next()
+	if flags&1 != 0 {
+		total := 1 + next()%coding.MaxStructuredAppend
+		c.Segments = append(c.Segments, coding.StructuredAppend{Index: next() % total, Total: total, Parity: byte(next())})
+	}
+	if flags&2 != 0 {
+		if flags&4 != 0 {
+			c.Segments = append(c.Segments, coding.Raw{Mode: uint(coding.ModeFNC1First)})
+		} else {
+			c.Segments = append(c.Segments, coding.Raw{Mode: uint(coding.ModeFNC1Second), Data: []byte{byte(next())}})
+		}
+	}
+	kanjiRunes, hanziRunes := []rune(kanji), []rune(hanzi)
+	for len(data) > 0 {
+		kind, n := next()%6, next()%32
+		switch kind {
+		case 0:
+			buf := make([]byte, n)
+			for i := range buf {
+				buf[i] = byte('0' + next()%10)
+			}
+			c.Segments = append(c.Segments, coding.Num(buf))
+		case 1:
+			buf := make([]byte, n)
+			for i := range buf {
+				buf[i] = alphabet[next()%len(alphabet)]
+			}
+			c.Segments = append(c.Segments, coding.Alpha(buf))
+		case 2:
+			buf := make([]byte, n)
+			for i := range buf {
+				buf[i] = byte(next())
+			}
+			c.Segments = append(c.Segments, coding.Bytes(buf))
+		case 3:
+			buf := make([]rune, n)
+			for i := range buf {
+				buf[i] = kanjiRunes[next()%len(kanjiRunes)]
+			}
+			c.Segments = append(c.Segments, coding.Kanji(string(buf)))
+		case 4:
+			d := (n<<16 | next()<<8 | next()) % 1000000
+			c.Segments = append(c.Segments, coding.ECI(d))
+		case 5:
+			buf := make([]rune, n)
+			for i := range buf {
+				buf[i] = hanziRunes[next()%len(hanziRunes)]
+			}
+			c.Segments = append(c.Segments, coding.Hanzi(string(buf)))
+		}
+	}
+	return c
+}
+
+// RoundTrip encodes c, decodes the code, and checks that the decoder
+// returns c's version, level, mask, and segments.  If the segments do
+// not fit in the code, RoundTrip returns the *coding.ErrDataTooLong
+// from the encoder.
+func RoundTrip(c Case) error {
+	p, err := coding.NewPlan(c.Version, c.Level, c.Mask)
+	if err != nil {
+		return err
+	}
+	code, err := p.Encode(c.Segments...)
+	if err != nil {
+		return err
+	}
+	info, err := coding.Decode(code)
+	if err != nil {
+		return fmt.Errorf("%v: decode: %w", c, err)
+	}
+	if info.Version != c.Version || info.Level != c.Level || info.Mask != c.Mask {
+		return fmt.Errorf("%v: decoded version %v, level %v, mask %d", c, info.Version, info.Level, info.Mask)
+	}
+	if !reflect.DeepEqual(info.Segments, c.Segments) {
+		return fmt.Errorf("%v: decoded %v", c, info.Segments)
+	}
+	return nil
+}
+
+// FuzzRoundTrip is a fuzz target that checks RoundTrip for the
+// Case that NewCase derives from each input.  Inputs whose segments
+// do not fit in their code are skipped.
+func FuzzRoundTrip(f *testing.F) {
+	f.Add([]byte{})
+	f.Add([]byte{0, 1, 2, 0, 0, 5, '1', '2', '3', '4', '5'})
+	f.Add([]byte{9, 3, 7, 7, 2, 0, 0x5a, 2, 4, 'h', 'i', '!', '?', 3, 2, 0, 1})
+	f.Add([]byte{39, 0, 0, 0, 4, 0, 0, 26, 2, 31, 0xff, 0xfe, 0xfd, 1, 10, 40, 41, 42, 43, 44, 0, 1, 2, 3, 4})
+	f.Add([]byte{4, 1, 3, 1, 4, 2, 0x33, 0, 3, '9', '8', '7'})
+	f.Add([]byte{4, 1, 3, 2, 0x61, 2, 3, 'a', 'b', 'c'})
+	f.Add([]byte{5, 2, 1, 0, 5, 4, 0, 1, 10, 11, 0, 2, '4', '2', 5, 1, 14})
+	f.Fuzz(func(t *testing.T, data []byte) {
+		err := RoundTrip(NewCase(data))
+		var tooLong *coding.ErrDataTooLong
+		if errors.As(err, &tooLong) {
+			t.Skip(err)
+		}
+		if err != nil {
+			t.Fatal(err)
+		}
+	})
+}
@@ -0,0 +1,42 @@
+// Copyright 2011 The Go Authors.  All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package codingtest_test
+
+import (
+	"testing"
+
+	"github.com/inkstray/rsc-qr/coding"
+	"github.com/inkstray/rsc-qr/coding/codingtest"
+)
+
+func FuzzRoundTrip(f *testing.F) { codingtest.FuzzRoundTrip(f) }
+
+func TestNewCaseHeaders(t *testing.T) {
+	for _, tt := range []struct {
+		flags    byte
+		sa, fnc1 bool
+	}{
+		{0, false, false},
+		{1, true, false},
+		{2, false, true},
+		{3, true, true},
+		{6, false, true},
+		{9, true, false},
+	} {
+		c := codingtest.NewCase([]byte{1, 0, 0, tt.flags, 0, 0, 0})
+		var sa, fnc1 bool
+		for _, seg := range c.Segments {
+			switch seg := seg.(type) {
+			case coding.StructuredAppend:
+				sa = true
+			case coding.Raw:
+				fnc1 = seg.Mode == uint(coding.ModeFNC1First) || seg.Mode == uint(coding.ModeFNC1Second)
+			}
+		}
+		if sa != tt.sa || fnc1 != tt.fnc1 {
+			t.Errorf("NewCase with flags %#x: structured append %v, FNC1 %v, want %v, %v", tt.flags, sa, fnc1, tt.sa, tt.fnc1)
+		}
+	}
+}
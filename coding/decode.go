@@ -0,0 +1,651 @@
+// Copyright 2011 The Go Authors.  All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package coding
+
+// Decoding of QR code bitmaps.
+
+import (
+	"errors"
+	"fmt"
+	"strconv"
+
+	"golang.org/x/text/encoding/japanese"
+	"golang.org/x/text/encoding/simplifiedchinese"
+)
+
+// Errors reporting codes that cannot be decoded.
+// Decode returns them wrapped with details,
+// so callers should test for them using errors.Is.
+var (
+	ErrBadFormat     = errors.New("unreadable QR format information")
+	ErrTooManyErrors = errors.New("too many errors in QR code")
+	ErrBadData       = errors.New("invalid QR data")
+)
+
+// Decode decodes c, which must hold exactly the 4v+17 pixels on a side
+// of a version v code, with no quiet zone, in its normal orientation.
+// It returns the version, level, and mask read from c, along with the
+// segments of data it holds.  Segments in modes other than numeric,
+// alphanumeric, byte, ECI, and structured append are returned as Raw
+// segments.  The byte segments are returned as Bytes, and Kanji
+// segments as Kanji, converted back to UTF-8.
+func Decode(c *Code) (*Info, error) {
+	return decode(c, nil)
+}
+
+// DecodeErased is like Decode, but it treats the pixels for which
+// erased returns true as unreadable, such as those hidden by a logo
+// or glare.  Every byte with an unreadable pixel is an erasure, and
+// each block's check bytes can restore twice as many erasures as
+// errors in unknown places.  The format information is read as usual.
+func DecodeErased(c *Code, erased func(x, y int) bool) (*Info, error) {
+	return decode(c, erased)
+}
+
+func decode(c *Code, erased func(x, y int) bool) (*Info, error) {
+	v, err := c.version()
+	if err != nil {
+		return nil, err
+	}
+	l, m, ferr, err := c.format()
+	if err != nil {
+		return nil, err
+	}
+	msg, bad, err := readMsg(c, v, l, m, erased)
+	if err != nil {
+		return nil, err
+	}
+
+	// Correct each block.
+	sizes, ne := blockSizes(v, l)
+	ndata := v.DataBytes(l)
+	data := make([]byte, 0, ndata)
+	block := make([]byte, 0, sizes[len(sizes)-1]+ne)
+	var erasures []int
+	var failed []int
+	corrected := make([]int, 0, len(sizes))
+	doff, coff := 0, ndata // offsets of block in msg
+	for i, nd := range sizes {
+		block = append(append(block[:0], msg[doff:doff+nd]...), msg[coff:coff+ne]...)
+		if bad != nil {
+			erasures = erasures[:0]
+			for j := range block {
+				o := doff + j
+				if j >= nd {
+					o = coff + j - nd
+				}
+				if bad[o] {
+					erasures = append(erasures, j)
+				}
+			}
+		}
+		n, err := Field.CorrectRS(block, ne, erasures)
+		if err != nil {
+			// Keep the block as read, and go on
+			// to report which others are intact.
+			failed = append(failed, i)
+			n = -1
+			block = append(block[:0], msg[doff:doff+nd]...)
+		}
+		corrected = append(corrected, n)
+		data = append(data, block[:nd]...)
+		doff, coff = doff+nd, coff+ne
+	}
+	if failed != nil {
+		return nil, newDamagedBlocks(v, data, sizes, corrected, failed)
+	}
+
+	segs, nbit, err := parseSegments(v, data)
+	if err != nil {
+		return nil, err
+	}
+	info := &Info{
+		Version:      v,
+		Level:        l,
+		Mask:         m,
+		Penalty:      c.Penalty(),
+		Segments:     segs,
+		DataBits:     nbit,
+		Corrected:    corrected,
+		FormatErrors: ferr,
+	}
+	if n := (nbit + 4 + 7) / 8; n < len(data) {
+		info.PadBytes = len(data) - n
+	}
+	return info, nil
+}
+
+// An ErrDamagedBlocks reports a code in which some error correction
+// blocks have too many errors to correct.  It describes what can
+// still be recovered, so that callers can salvage part of the data
+// or ask for a better scan.  It matches ErrTooManyErrors in errors.Is.
+type ErrDamagedBlocks struct {
+	Failed    []int // indexes of the blocks that could not be corrected
+	Corrected []int // bytes corrected in each block, or -1 if it failed
+
+	// Data holds the data bytes of the code: corrected in the intact
+	// blocks and as read in the failed ones.  Recovered lists the
+	// ranges [start, end) of Data from intact blocks, in order.
+	Data      []byte
+	Recovered [][2]int
+
+	// Segments holds the complete segments that the data
+	// before the first failed block holds.
+	Segments []Encoding
+}
+
+func (e *ErrDamagedBlocks) Error() string {
+	return fmt.Sprintf("%v in blocks %v of %d", ErrTooManyErrors, e.Failed, len(e.Corrected))
+}
+
+func (e *ErrDamagedBlocks) Unwrap() error {
+	return ErrTooManyErrors
+}
+
+// newDamagedBlocks returns the error for a version v code whose blocks
+// with the given sizes hold data, in which the blocks listed in failed
+// could not be corrected.
+func newDamagedBlocks(v Version, data []byte, sizes, corrected, failed []int) *ErrDamagedBlocks {
+	e := &ErrDamagedBlocks{Failed: failed, Corrected: corrected, Data: data}
+	off := 0
+	for i, nd := range sizes {
+		if corrected[i] >= 0 {
+			if n := len(e.Recovered); n > 0 && e.Recovered[n-1][1] == off {
+				e.Recovered[n-1][1] += nd
+			} else {
+				e.Recovered = append(e.Recovered, [2]int{off, off + nd})
+			}
+		}
+		off += nd
+	}
+	if len(e.Recovered) > 0 && e.Recovered[0][0] == 0 {
+		e.Segments, _, _ = parseSegments(v, data[:e.Recovered[0][1]])
+	}
+	return e
+}
+
+// version returns the version of a code of c's size.
+func (c *Code) version() (Version, error) {
+	v := Version((c.Size - 17) / 4)
+	if c.Size != 17+4*int(v) || v < MinVersion || v > MaxVersion {
+		return 0, fmt.Errorf("%w for size %d", ErrBadVersion, c.Size)
+	}
+	return v, nil
+}
+
+// readMsg reads the unmasked data and check bytes of c, a version v,
+// level l code with mask m, in the order that the pixel offsets
+// number them before interleaving: all the data blocks, then all the
+// check blocks.  If erased is not nil, readMsg also reports which
+// bytes have pixels that erased marks unreadable.
+func readMsg(c *Code, v Version, l Level, m Mask, erased func(x, y int) bool) (msg []byte, bad []bool, err error) {
+	pix, err := PixelMap(v, l)
+	if err != nil {
+		return nil, nil, err
+	}
+	msg = make([]byte, vtab[v].bytes)
+	if erased != nil {
+		bad = make([]bool, len(msg))
+	}
+	for y, row := range pix {
+		for x, p := range row {
+			switch p.Role() {
+			case Data, Check:
+				o := p.Offset()
+				if c.Black(x, y) != m.Invert(y, x) {
+					msg[o/8] |= 1 << uint(7-o&7)
+				}
+				if erased != nil && erased(x, y) {
+					bad[o/8] = true
+				}
+			}
+		}
+	}
+	return msg, bad, nil
+}
+
+// Codewords returns the codewords of c as read, before any error
+// correction, along with the level and mask from its format
+// information.  Like Decode, it requires c to hold exactly a code
+// with no quiet zone.  The codewords are unmasked and in the order
+// they are placed in the code; Deinterleave splits them into blocks.
+// Codewords is meant for examining damaged codes and for showing
+// how codes work: comparing its blocks with those of the corrected
+// data shows where the damage is.
+func Codewords(c *Code) (Level, Mask, []byte, error) {
+	v, err := c.version()
+	if err != nil {
+		return 0, 0, nil, err
+	}
+	l, m, _, err := c.format()
+	if err != nil {
+		return 0, 0, nil, err
+	}
+	msg, _, err := readMsg(c, v, l, m, nil)
+	if err != nil {
+		return 0, 0, nil, err
+	}
+
+	// Interleave the blocks, the inverse of Deinterleave.
+	sizes, ne := blockSizes(v, l)
+	ndata := v.DataBytes(l)
+	cw := make([]byte, 0, len(msg))
+	for j := 0; j < sizes[len(sizes)-1]; j++ {
+		off := 0
+		for _, nd := range sizes {
+			if j < nd {
+				cw = append(cw, msg[off+j])
+			}
+			off += nd
+		}
+	}
+	for j := 0; j < ne; j++ {
+		for i := range sizes {
+			cw = append(cw, msg[ndata+i*ne+j])
+		}
+	}
+	return l, m, cw, nil
+}
+
+// Unmask returns a copy of c, a version v code with mask m, with the
+// mask removed from its data, check, and extra pixels, so that the
+// pixels show the bits as placed.  The function patterns and format
+// and version information are left as they are.  Applying Unmask to
+// its result restores the mask.  The copy has no Info.
+// Unmask returns nil if v is not a valid version or c is not the
+// size of a version v code.
+func Unmask(c *Code, v Version, m Mask) *Code {
+	pix, err := PixelMap(v, L) // roles do not depend on the level
+	if err != nil || c.Size != len(pix) {
+		return nil
+	}
+	d := c.Clone()
+	d.Info = nil
+	for y, row := range pix {
+		for x, p := range row {
+			if r := p.Role(); (r == Data || r == Check || r == Extra) && m.Invert(y, x) {
+				d.Bitmap[y*d.Stride+x/8] ^= 1 << uint(7-x&7)
+			}
+		}
+	}
+	return d
+}
+
+// blockSizes returns the number of data bytes in each error
+// correction block of a version v, level l code, and the number
+// of check bytes in every block.  The last blocks may hold one
+// more data byte than the first ones.
+func blockSizes(v Version, l Level) (data []int, check int) {
+	lev := &vtab[v].level[l]
+	nd := v.DataBytes(l)
+	data = make([]int, lev.nblock)
+	for i := range data {
+		data[i] = nd / lev.nblock
+		if i >= lev.nblock-nd%lev.nblock {
+			data[i]++
+		}
+	}
+	return data, lev.check
+}
+
+// A Block is one error correction block of a code:
+// data bytes and the Reed-Solomon check bytes protecting them.
+type Block struct {
+	Data  []byte
+	Check []byte
+}
+
+// Deinterleave splits the codewords of a version v, level l code
+// into its error correction blocks.  The codewords are in the order
+// they are placed in the code, which takes the first data byte of
+// each block, then the second, and so on, and then the check bytes
+// in the same way.  It is the inverse of that interleaving, which
+// Plan.Encode performs when laying out the bytes from AddCheckBytes.
+func Deinterleave(v Version, l Level, codewords []byte) ([]Block, error) {
+	if v < MinVersion || v > MaxVersion {
+		return nil, fmt.Errorf("%w %d", ErrBadVersion, int(v))
+	}
+	if l < L || l > H {
+		return nil, fmt.Errorf("%w %d", ErrBadLevel, int(l))
+	}
+	if n := vtab[v].bytes; len(codewords) != n {
+		return nil, fmt.Errorf("version %v holds %d codewords, not %d", v, n, len(codewords))
+	}
+	sizes, ne := blockSizes(v, l)
+	blocks := make([]Block, len(sizes))
+	for i, nd := range sizes {
+		blocks[i] = Block{Data: make([]byte, nd), Check: make([]byte, ne)}
+	}
+	p := codewords
+	for j := 0; j < sizes[len(sizes)-1]; j++ {
+		for i := range blocks {
+			if j < len(blocks[i].Data) {
+				blocks[i].Data[j], p = p[0], p[1:]
+			}
+		}
+	}
+	for j := 0; j < ne; j++ {
+		for i := range blocks {
+			blocks[i].Check[j], p = p[0], p[1:]
+		}
+	}
+	return blocks, nil
+}
+
+// formatBits returns the 15 format bits for level l and mask m:
+// the level and mask, a BCH error correcting code, and the
+// fixed XOR pattern.
+func formatBits(l Level, m Mask) uint32 {
+	fb := uint32(l^1) << 13 // level: L=01, M=00, Q=11, H=10
+	fb |= uint32(m) << 10   // mask
+	const formatPoly = 0x537
+	rem := fb
+	for i := 14; i >= 10; i-- {
+		if rem&(1<<uint(i)) != 0 {
+			rem ^= formatPoly << uint(i-10)
+		}
+	}
+	fb |= rem
+	fb ^= uint32(0x5412)
+	return fb
+}
+
+// format reads both copies of the format bits in c and returns
+// the level and mask of the nearest valid format, along with the
+// number of bits in which the closer copy differs from it.
+func (c *Code) format() (Level, Mask, int, error) {
+	siz := c.Size
+	var fb1, fb2 uint32
+	for i := 0; i < 15; i++ {
+		var x1, y1, x2, y2 int
+		switch {
+		case i < 6:
+			x1, y1 = 8, i
+		case i < 8:
+			x1, y1 = 8, i+1
+		case i < 9:
+			x1, y1 = 7, 8
+		default:
+			x1, y1 = 14-i, 8
+		}
+		switch {
+		case i < 8:
+			x2, y2 = siz-1-i, 8
+		default:
+			x2, y2 = 8, siz-1-14+i
+		}
+		if c.Black(x1, y1) {
+			fb1 |= 1 << uint(i)
+		}
+		if c.Black(x2, y2) {
+			fb2 |= 1 << uint(i)
+		}
+	}
+
+	l1, m1, d1 := nearestFormat(fb1)
+	l2, m2, d2 := nearestFormat(fb2)
+	if d2 < d1 {
+		l1, m1, d1 = l2, m2, d2
+	}
+	if d1 > 3 {
+		return 0, 0, 0, fmt.Errorf("%w: %#04x, %#04x", ErrBadFormat, fb1, fb2)
+	}
+	return l1, m1, d1, nil
+}
+
+// DecodeFormat decodes the 15 format bits read from a code, as laid
+// out by the specification with the level in bits 14 and 13 and the
+// mask in bits 12 through 10, and with the fixed XOR pattern still
+// applied.  The BCH code protecting the bits allows DecodeFormat to
+// correct up to 3 bit errors.
+func DecodeFormat(bits uint32) (Level, Mask, error) {
+	l, m, d := nearestFormat(bits)
+	if bits>>15 != 0 || d > 3 {
+		return 0, 0, fmt.Errorf("%w %#04x", ErrBadFormat, bits)
+	}
+	return l, m, nil
+}
+
+// nearestFormat returns the level and mask whose format bits
+// are nearest to bits, and the number of bits that differ.
+func nearestFormat(bits uint32) (Level, Mask, int) {
+	best, bestl, bestm := 16, L, Mask(0)
+	for l := L; l <= H; l++ {
+		for m := Mask(0); m < 8; m++ {
+			if d := bitCount(formatBits(l, m) ^ bits); d < best {
+				best, bestl, bestm = d, l, m
+			}
+		}
+	}
+	return bestl, bestm, best
+}
+
+// bitCount returns the number of 1 bits in x.
+func bitCount(x uint32) int {
+	n := 0
+	for ; x != 0; x &= x - 1 {
+		n++
+	}
+	return n
+}
+
+// parseSegments parses the data bytes of a version v code into
+// segments.  It returns the segments and the number of data bits
+// they occupy, not counting the terminator.  On error, it returns
+// the segments before the bad one and the bit offset at which the
+// bad one starts.
+func parseSegments(v Version, data []byte) ([]Encoding, int, error) {
+	r := NewBitReader(data)
+	var err error
+	read := func(nbit int) uint {
+		if err != nil {
+			return 0
+		}
+		if r.Remaining() < nbit {
+			err = fmt.Errorf("%w: truncated segment at bit %d", ErrBadData, r.Offset())
+			return 0
+		}
+		return r.ReadBits(nbit)
+	}
+
+	var segs []Encoding
+	for r.Remaining() >= 4 {
+		start, nseg := r.Offset(), len(segs)
+		mode := Mode(read(4))
+		if mode == ModeTerminator {
+			return segs, start, nil
+		}
+		var subset uint
+		if mode == ModeHanzi {
+			// The Hanzi subset comes before the count.
+			subset = read(4)
+		}
+		n := int(read(CountBits(mode, v)))
+		switch mode {
+		default:
+			return nil, 0, fmt.Errorf("%w: unknown mode %v at bit %d", ErrBadData, mode, start)
+
+		case ModeNumeric:
+			buf := make([]byte, 0, n)
+			for ; n > 0 && err == nil; n -= 3 {
+				k, nbit := 3, 10
+				switch n {
+				case 1:
+					k, nbit = 1, 4
+				case 2:
+					k, nbit = 2, 7
+				}
+				d := read(nbit)
+				s := strconv.Itoa(int(d))
+				if len(s) > k {
+					return nil, 0, fmt.Errorf("%w: numeric value %d at bit %d", ErrBadData, d, start)
+				}
+				for i := len(s); i < k; i++ {
+					buf = append(buf, '0')
+				}
+				buf = append(buf, s...)
+			}
+			segs = append(segs, Num(buf))
+
+		case ModeAlphanumeric:
+			buf := make([]byte, 0, n)
+			for ; n > 0 && err == nil; n -= 2 {
+				if n == 1 {
+					d := read(6)
+					if d >= 45 {
+						return nil, 0, fmt.Errorf("%w: alphanumeric value %d at bit %d", ErrBadData, d, start)
+					}
+					buf = append(buf, alphabet[d])
+					break
+				}
+				d := read(11)
+				if d >= 45*45 {
+					return nil, 0, fmt.Errorf("%w: alphanumeric value %d at bit %d", ErrBadData, d, start)
+				}
+				buf = append(buf, alphabet[d/45], alphabet[d%45])
+			}
+			segs = append(segs, Alpha(buf))
+
+		case ModeByte:
+			buf := make([]byte, n)
+			for i := range buf {
+				buf[i] = byte(read(8))
+			}
+			segs = append(segs, Bytes(buf))
+
+		case ModeKanji:
+			var b Bits
+			var sjis []byte
+			for i := 0; i < n; i++ {
+				w := read(13)
+				b.Write(w, 13)
+				// Invert Kanji.Encode: lead bytes 0x81-0x9f and
+				// 0xe0-0xeb map to 0x01-0x2b, trail bytes 0x40-0xfc.
+				c0 := byte(w/0xc0 + 1)
+				if c0 < 0x20 {
+					c0 |= 0x80
+				} else {
+					c0 |= 0xc0
+				}
+				sjis = append(sjis, c0, byte(w%0xc0+0x40))
+			}
+			if err != nil {
+				break
+			}
+			if s, ok := fromShiftJIS(sjis); ok {
+				segs = append(segs, Kanji(s))
+				break
+			}
+
+			// Keep Kanji that does not convert back to the same
+			// Shift JIS as a Raw segment that re-encodes identically.
+			nbit := b.Bits()
+			b.Write(0, -nbit&7)
+			segs = append(segs, Raw{
+				Mode:      uint(mode),
+				CountBits: CountBits(mode, v),
+				Count:     uint(n),
+				Data:      b.Bytes(),
+				DataBits:  nbit,
+			})
+
+		case ModeHanzi:
+			var b Bits
+			b.Write(subset, 4)
+			b.Write(uint(n), CountBits(mode, v))
+			var gb []byte
+			for i := 0; i < n; i++ {
+				w := read(13)
+				b.Write(w, 13)
+				// Invert Hanzi.Encode: rows 0xa1-0xaa map to 0x00-0x09
+				// and rows 0xb0-0xfa to 0x0a-0x54, in radix 0x60.
+				c := w/0x60<<8 | w%0x60
+				if c < 0x0a00 {
+					c += 0xa1a1
+				} else {
+					c += 0xa6a1
+				}
+				gb = append(gb, byte(c>>8), byte(c))
+			}
+			if err != nil {
+				break
+			}
+			if subset == 1 {
+				if s, ok := fromGB2312(gb); ok {
+					segs = append(segs, Hanzi(s))
+					break
+				}
+			}
+
+			// Keep other subsets, and text that does not convert
+			// back to the same GB 2312, as a Raw segment that
+			// re-encodes identically.  The subset precedes the
+			// count, so both are part of the Raw data.
+			nbit := b.Bits()
+			b.Write(0, -nbit&7)
+			segs = append(segs, Raw{
+				Mode:     uint(mode),
+				Data:     b.Bytes(),
+				DataBits: nbit,
+			})
+
+		case ModeECI:
+			d := read(8)
+			switch {
+			case d&0x80 == 0:
+			case d&0xc0 == 0x80:
+				d = d&0x3f<<8 | read(8)
+			case d&0xe0 == 0xc0:
+				d = d&0x1f<<16 | read(16)
+			default:
+				return nil, 0, fmt.Errorf("%w: invalid ECI designator at bit %d", ErrBadData, start)
+			}
+			segs = append(segs, ECI(d))
+
+		case ModeStructuredAppend:
+			a := StructuredAppend{Index: int(read(4)), Total: int(read(4)) + 1}
+			a.Parity = byte(read(8))
+			segs = append(segs, a)
+
+		case ModeFNC1First:
+			segs = append(segs, Raw{Mode: uint(mode)})
+
+		case ModeFNC1Second:
+			segs = append(segs, Raw{Mode: uint(mode), Data: []byte{byte(read(8))}})
+		}
+		if err != nil {
+			return segs[:nseg], start, err
+		}
+	}
+	return segs, r.Offset(), nil
+}
+
+// fromGB2312 converts k, a sequence of double-byte GB 2312
+// characters, to UTF-8.  It reports whether the conversion is the
+// exact inverse of toGB2312.
+func fromGB2312(k []byte) (string, bool) {
+	s, err := simplifiedchinese.GBK.NewDecoder().Bytes(k)
+	if err != nil {
+		return "", false
+	}
+	if back, ok := toGB2312(string(s)); !ok || back != string(k) {
+		return "", false
+	}
+	return string(s), true
+}
+
+// fromShiftJIS converts k, a sequence of double-byte Shift JIS
+// characters, to UTF-8.  It reports whether the conversion is the
+// exact inverse of toShiftJIS.
+func fromShiftJIS(k []byte) (string, bool) {
+	s, err := japanese.ShiftJIS.NewDecoder().Bytes(k)
+	if err != nil {
+		return "", false
+	}
+	if back, ok := toShiftJIS(string(s)); !ok || back != string(k) {
+		return "", false
+	}
+	return string(s), true
+}
@@ -0,0 +1,421 @@
+// Copyright 2011 The Go Authors.  All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package coding
+
+import (
+	"bytes"
+	"errors"
+	"math/rand"
+	"reflect"
+	"testing"
+
+	"github.com/inkstray/rsc-qr/gf256"
+)
+
+var decodeTests = []struct {
+	v    Version
+	l    Level
+	segs []Encoding
+}{
+	{1, L, []Encoding{Num("01234567")}},
+	{1, H, []Encoding{Alpha("HELLO")}},
+	{2, M, []Encoding{Bytes("hello, world")}},
+	{5, Q, []Encoding{Num("1"), Alpha("AB"), Num("12"), Bytes{0, 0xff}}},
+	{7, L, []Encoding{ECI(26), Bytes("héllo")}},
+	{10, H, []Encoding{StructuredAppend{Index: 2, Total: 3, Parity: 0x5a}, Alpha("PART")}},
+	{3, M, []Encoding{Raw{Mode: 5}, Num("0101234")}},
+	{3, M, []Encoding{Raw{Mode: 9, Data: []byte{37}}, Bytes("x")}},
+	{4, L, []Encoding{Kanji("点茗")}},
+	{4, L, []Encoding{Bytes("x"), Kanji("日本語"), Num("1")}},
+	{4, L, []Encoding{Raw{Mode: 8, CountBits: 8, Count: 1, Data: []byte{0x18, 0x00}, DataBits: 13}}},
+	{5, M, []Encoding{Hanzi("中文"), Num("123")}},
+	{6, Q, []Encoding{Bytes("x"), Hanzi("汉字，测试"), Kanji("日本")}},
+	{4, L, []Encoding{Raw{Mode: 13, Data: []byte{0x00, 0x10, 0x00, 0x80}, DataBits: 25}}},
+	{40, H, []Encoding{Bytes(make([]byte, 1000))}},
+}
+
+func TestDecode(t *testing.T) {
+	for _, tt := range decodeTests {
+		for m := Mask(0); m < 8; m += 3 {
+			p, err := NewPlan(tt.v, tt.l, m)
+			if err != nil {
+				t.Fatal(err)
+			}
+			c, err := p.Encode(tt.segs...)
+			if err != nil {
+				t.Fatalf("Encode(%v): %v", tt.segs, err)
+			}
+			info, err := Decode(c)
+			if err != nil {
+				t.Errorf("Decode(%v, %v, %v): %v", tt.v, tt.l, m, err)
+				continue
+			}
+			if info.Version != tt.v || info.Level != tt.l || info.Mask != m {
+				t.Errorf("Decode = version %v, level %v, mask %v, want %v, %v, %v", info.Version, info.Level, info.Mask, tt.v, tt.l, m)
+			}
+			if !reflect.DeepEqual(info.Segments, tt.segs) {
+				t.Errorf("Decode(%v, %v, %v) = %v, want %v", tt.v, tt.l, m, info.Segments, tt.segs)
+			}
+			if want := make([]int, vtab[tt.v].level[tt.l].nblock); !reflect.DeepEqual(info.Corrected, want) {
+				t.Errorf("Decode(%v, %v, %v) corrected %v, want %v", tt.v, tt.l, m, info.Corrected, want)
+			}
+			if info.DataBits != c.Info.DataBits || info.PadBytes != c.Info.PadBytes || info.Penalty != c.Info.Penalty {
+				t.Errorf("Decode(%v, %v, %v) = bits %d, pad %d, penalty %d, want %d, %d, %d", tt.v, tt.l, m,
+					info.DataBits, info.PadBytes, info.Penalty, c.Info.DataBits, c.Info.PadBytes, c.Info.Penalty)
+			}
+		}
+	}
+}
+
+func TestDecodeErrors(t *testing.T) {
+	r := rand.New(rand.NewSource(1))
+	for _, v := range []Version{1, 6, 15} {
+		for l := L; l <= H; l++ {
+			c, err := Encode(v, l, Bytes("errors"))
+			if err != nil {
+				t.Fatal(err)
+			}
+			pix, _ := PixelMap(v, l)
+
+			// Damage up to ne/2 bytes in each block by
+			// flipping pixels of the first check bytes.
+			ne := vtab[v].level[l].check
+			nblock := vtab[v].level[l].nblock
+			dataBits := uint(8 * (vtab[v].bytes - ne*nblock))
+			d := c.Clone()
+			damaged := make(map[uint]bool)
+			for y, row := range pix {
+				for x, p := range row {
+					if p.Role() != Check {
+						continue
+					}
+					o := p.Offset() - dataBits
+					if int(o/8)%ne < ne/2 && r.Intn(2) == 0 {
+						d.Bitmap[y*d.Stride+x/8] ^= 1 << uint(7-x&7)
+						damaged[o/8] = true
+					}
+				}
+			}
+			want := make([]int, nblock)
+			for k := range damaged {
+				want[int(k)/ne]++
+			}
+			info, err := Decode(d)
+			if err != nil {
+				t.Errorf("Decode(damaged %v %v): %v", v, l, err)
+				continue
+			}
+			if !reflect.DeepEqual(info.Segments, c.Info.Segments) {
+				t.Errorf("Decode(damaged %v %v) = %v, want %v", v, l, info.Segments, c.Info.Segments)
+			}
+			if !reflect.DeepEqual(info.Corrected, want) {
+				t.Errorf("Decode(damaged %v %v) corrected %v, want %v", v, l, info.Corrected, want)
+			}
+
+			// Flipping every data pixel is too much.
+			for y, row := range pix {
+				for x, p := range row {
+					if p.Role() == Data {
+						d.Bitmap[y*d.Stride+x/8] ^= 1 << uint(7-x&7)
+					}
+				}
+			}
+			if _, err := Decode(d); !errors.Is(err, ErrTooManyErrors) {
+				t.Errorf("Decode(destroyed %v %v) = %v, want ErrTooManyErrors", v, l, err)
+			}
+		}
+	}
+}
+
+func TestDecodeFormat(t *testing.T) {
+	c, err := Encode(2, Q, Alpha("FORMAT"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	// Three errors in the first copy are corrected.
+	d := c.Clone()
+	for _, y := range []int{0, 1, 2} {
+		d.Bitmap[y*d.Stride+1] ^= 0x80 // x = 8
+	}
+	if info, err := Decode(d); err != nil || info.Level != Q || info.Mask != c.Info.Mask || info.FormatErrors != 0 {
+		t.Errorf("Decode with 3 format errors = %v, %v", info, err)
+	}
+	// With an error in the second copy too, that copy
+	// is the closer one, and one error is corrected.
+	x := d.Size - 1
+	d.Bitmap[8*d.Stride+x/8] ^= 1 << uint(7-x&7)
+	if info, err := Decode(d); err != nil || info.Level != Q || info.Mask != c.Info.Mask || info.FormatErrors != 1 {
+		t.Errorf("Decode with 3+1 format errors = %+v, %v", info, err)
+	}
+
+	if _, err := Decode(&Code{Size: 22, Stride: 3, Bitmap: make([]byte, 66)}); !errors.Is(err, ErrBadVersion) {
+		t.Errorf("Decode(size 22) = %v, want ErrBadVersion", err)
+	}
+	if _, err := Decode(&Code{Size: 21, Stride: 3, Bitmap: make([]byte, 63)}); !errors.Is(err, ErrBadFormat) {
+		t.Errorf("Decode(blank) = %v, want ErrBadFormat", err)
+	}
+}
+
+func TestDecodeFormatBits(t *testing.T) {
+	if l, m, err := DecodeFormat(0x5412); l != M || m != 0 || err != nil {
+		t.Errorf("DecodeFormat(0x5412) = %v, %v, %v, want M, 0, nil", l, m, err)
+	}
+	r := rand.New(rand.NewSource(1))
+	for l := L; l <= H; l++ {
+		for m := Mask(0); m < 8; m++ {
+			fb := formatBits(l, m)
+			for nerr := 0; nerr <= 3; nerr++ {
+				bits := fb
+				for _, i := range r.Perm(15)[:nerr] {
+					bits ^= 1 << uint(i)
+				}
+				if gl, gm, err := DecodeFormat(bits); gl != l || gm != m || err != nil {
+					t.Errorf("DecodeFormat(%#04x) = %v, %v, %v, want %v, %v, nil", bits, gl, gm, err, l, m)
+				}
+			}
+		}
+	}
+	if _, _, err := DecodeFormat(1 << 15); !errors.Is(err, ErrBadFormat) {
+		t.Errorf("DecodeFormat(1<<15) = %v, want ErrBadFormat", err)
+	}
+	if _, _, err := DecodeFormat(0x5412 ^ 0xf); !errors.Is(err, ErrBadFormat) {
+		t.Errorf("DecodeFormat with 4 errors = %v, want ErrBadFormat", err)
+	}
+}
+
+func TestDeinterleave(t *testing.T) {
+	for _, tt := range []struct {
+		v Version
+		l Level
+	}{{1, L}, {5, Q}, {13, H}, {40, M}} {
+		var b Bits
+		Bytes("deinterleave").Encode(&b, tt.v)
+		b.AddCheckBytes(tt.v, tt.l)
+		stream := b.Bytes()
+
+		// Interleave the stream of data blocks then check blocks
+		// as lplan does, by reading the blocks column-wise.
+		sizes, ne := blockSizes(tt.v, tt.l)
+		var data, check [][]byte
+		d, c := stream[:tt.v.DataBytes(tt.l)], stream[tt.v.DataBytes(tt.l):]
+		for _, nd := range sizes {
+			data, d = append(data, d[:nd]), d[nd:]
+			check, c = append(check, c[:ne]), c[ne:]
+		}
+		var cw []byte
+		for j := 0; j <= sizes[len(sizes)-1]; j++ {
+			for _, blk := range data {
+				if j < len(blk) {
+					cw = append(cw, blk[j])
+				}
+			}
+		}
+		for j := 0; j < ne; j++ {
+			for _, blk := range check {
+				cw = append(cw, blk[j])
+			}
+		}
+
+		blocks, err := Deinterleave(tt.v, tt.l, cw)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if len(blocks) != len(sizes) {
+			t.Fatalf("Deinterleave(%v, %v) = %d blocks, want %d", tt.v, tt.l, len(blocks), len(sizes))
+		}
+		for i, blk := range blocks {
+			if !bytes.Equal(blk.Data, data[i]) || !bytes.Equal(blk.Check, check[i]) {
+				t.Errorf("Deinterleave(%v, %v) block %d = %x %x, want %x %x", tt.v, tt.l, i, blk.Data, blk.Check, data[i], check[i])
+			}
+			ecc := make([]byte, ne)
+			gf256.NewRSEncoder(Field, ne).ECC(blk.Data, ecc)
+			if !bytes.Equal(ecc, blk.Check) {
+				t.Errorf("Deinterleave(%v, %v) block %d: check bytes do not match data", tt.v, tt.l, i)
+			}
+		}
+	}
+
+	if _, err := Deinterleave(1, L, make([]byte, 25)); err == nil {
+		t.Errorf("Deinterleave(25 codewords) succeeded, want error")
+	}
+	if _, err := Deinterleave(41, L, nil); !errors.Is(err, ErrBadVersion) {
+		t.Errorf("Deinterleave(version 41) = %v, want ErrBadVersion", err)
+	}
+}
+
+func TestDecodeErased(t *testing.T) {
+	c, err := Encode(5, H, Bytes("hidden under a logo"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	// Scramble a square in the middle of the code,
+	// too big for error correction alone.
+	d := c.Clone()
+	logo := func(x, y int) bool { return 9 <= x && x < 28 && 9 <= y && y < 28 }
+	for y := 0; y < d.Size; y++ {
+		for x := 0; x < d.Size; x++ {
+			if logo(x, y) {
+				d.Bitmap[y*d.Stride+x/8] ^= 1 << uint(7-x&7)
+			}
+		}
+	}
+	if _, err := Decode(d); !errors.Is(err, ErrTooManyErrors) {
+		t.Errorf("Decode(logo) = %v, want ErrTooManyErrors", err)
+	}
+	info, err := DecodeErased(d, logo)
+	if err != nil {
+		t.Fatalf("DecodeErased(logo): %v", err)
+	}
+	if !reflect.DeepEqual(info.Segments, c.Info.Segments) {
+		t.Errorf("DecodeErased(logo) = %v, want %v", info.Segments, c.Info.Segments)
+	}
+}
+
+func TestCodewords(t *testing.T) {
+	c, err := Encode(5, Q, Bytes("raw codewords"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	l, m, cw, err := Codewords(c)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if l != Q || m != c.Info.Mask || len(cw) != vtab[5].bytes {
+		t.Fatalf("Codewords = %v, %v, %d bytes, want Q, %v, %d bytes", l, m, len(cw), c.Info.Mask, vtab[5].bytes)
+	}
+	blocks, err := Deinterleave(5, Q, cw)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for i, blk := range blocks {
+		ecc := make([]byte, len(blk.Check))
+		gf256.NewRSEncoder(Field, len(ecc)).ECC(blk.Data, ecc)
+		if !bytes.Equal(ecc, blk.Check) {
+			t.Errorf("Codewords block %d: check bytes do not match data", i)
+		}
+	}
+
+	// Damage is returned as read, not corrected.
+	d := c.Clone()
+	for x := d.Size - 4; x < d.Size; x++ {
+		d.Bitmap[(d.Size-1)*d.Stride+x/8] ^= 1 << uint(7-x&7)
+	}
+	_, _, dcw, err := Codewords(d)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if bytes.Equal(dcw, cw) || dcw[0] == cw[0] {
+		t.Errorf("Codewords(damaged) = %x, want first codeword to differ from %x", dcw, cw)
+	}
+	if _, _, _, err := Codewords(&Code{Size: 22, Stride: 3, Bitmap: make([]byte, 66)}); !errors.Is(err, ErrBadVersion) {
+		t.Errorf("Codewords(size 22) = %v, want ErrBadVersion", err)
+	}
+}
+
+func TestUnmask(t *testing.T) {
+	p, err := NewPlan(3, M, 5)
+	if err != nil {
+		t.Fatal(err)
+	}
+	c, err := p.Encode(Alpha("UNMASK"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	u := Unmask(c, 3, 5)
+	if u == nil || u.Info != nil {
+		t.Fatalf("Unmask = %v, want code without Info", u)
+	}
+	for y, row := range p.Pixel {
+		for x, pix := range row {
+			flip := false
+			switch pix.Role() {
+			case Data, Check, Extra:
+				flip = Mask(5).Invert(y, x)
+			}
+			if u.Black(x, y) != (c.Black(x, y) != flip) {
+				t.Fatalf("Unmask pixel (%d, %d) role %v = %v, want %v", x, y, pix.Role(), u.Black(x, y), c.Black(x, y) != flip)
+			}
+		}
+	}
+	if !Unmask(u, 3, 5).Equal(c) {
+		t.Errorf("Unmask(Unmask(c)) != c")
+	}
+
+	// Unmasked, the first data bits are the alphanumeric mode
+	// indicator 0010, placed up the rightmost column pair.
+	n := c.Size - 1
+	bits := []bool{u.Black(n, n), u.Black(n-1, n), u.Black(n, n-1), u.Black(n-1, n-1)}
+	if !reflect.DeepEqual(bits, []bool{false, false, true, false}) {
+		t.Errorf("Unmask first bits = %v, want 0010", bits)
+	}
+
+	if Unmask(c, 4, 5) != nil {
+		t.Errorf("Unmask(version 3 code, 4) != nil")
+	}
+}
+
+func TestDecodeDamagedBlocks(t *testing.T) {
+	const v, l = Version(5), Q
+	c, err := Encode(v, l, Num("123"), Alpha("AB"), Bytes(bytes.Repeat([]byte("salvage "), 6)))
+	if err != nil {
+		t.Fatal(err)
+	}
+	sizes, ne := blockSizes(v, l)
+	if len(sizes) != 4 {
+		t.Fatalf("version %v level %v has %d blocks, want 4", v, l, len(sizes))
+	}
+
+	// Destroy blocks 1 and 2, data and check bytes alike.
+	ndata := v.DataBytes(l)
+	inBlock := func(o, i int) bool {
+		start := 0
+		for _, nd := range sizes[:i] {
+			start += nd
+		}
+		if o < ndata {
+			return start <= o && o < start+sizes[i]
+		}
+		return ndata+i*ne <= o && o < ndata+(i+1)*ne
+	}
+	pix, _ := PixelMap(v, l)
+	d := c.Clone()
+	for y, row := range pix {
+		for x, p := range row {
+			if r := p.Role(); r != Data && r != Check {
+				continue
+			}
+			if o := int(p.Offset() / 8); inBlock(o, 1) || inBlock(o, 2) {
+				d.Bitmap[y*d.Stride+x/8] ^= 1 << uint(7-x&7)
+			}
+		}
+	}
+
+	_, err = Decode(d)
+	var e *ErrDamagedBlocks
+	if !errors.As(err, &e) || !errors.Is(err, ErrTooManyErrors) {
+		t.Fatalf("Decode(damaged) = %v, want ErrDamagedBlocks", err)
+	}
+	if !reflect.DeepEqual(e.Failed, []int{1, 2}) || e.Corrected[1] != -1 || e.Corrected[2] != -1 || e.Corrected[0] != 0 || e.Corrected[3] != 0 {
+		t.Errorf("Failed = %v, Corrected = %v, want [1 2], [0 -1 -1 0]", e.Failed, e.Corrected)
+	}
+	end1, start3 := sizes[0], ndata-sizes[3]
+	if want := [][2]int{{0, end1}, {start3, ndata}}; !reflect.DeepEqual(e.Recovered, want) {
+		t.Errorf("Recovered = %v, want %v", e.Recovered, want)
+	}
+	var b Bits
+	for _, s := range c.Info.Segments {
+		s.Encode(&b, v)
+	}
+	b.Pad(ndata*8 - b.Bits())
+	want := b.Bytes()
+	for _, r := range e.Recovered {
+		if !bytes.Equal(e.Data[r[0]:r[1]], want[r[0]:r[1]]) {
+			t.Errorf("Data[%d:%d] = %x, want %x", r[0], r[1], e.Data[r[0]:r[1]], want[r[0]:r[1]])
+		}
+	}
+	if !reflect.DeepEqual(e.Segments, []Encoding{Num("123"), Alpha("AB")}) {
+		t.Errorf("Segments = %v, want [Num(123) Alpha(AB)]", e.Segments)
+	}
+}
@@ -0,0 +1,104 @@
+// Copyright 2011 The Go Authors.  All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package coding
+
+import (
+	"sync"
+
+	"golang.org/x/text/encoding"
+	"golang.org/x/text/encoding/charmap"
+	"golang.org/x/text/encoding/japanese"
+	"golang.org/x/text/encoding/korean"
+	"golang.org/x/text/encoding/simplifiedchinese"
+	"golang.org/x/text/encoding/traditionalchinese"
+	"golang.org/x/text/encoding/unicode"
+	"golang.org/x/text/encoding/unicode/utf32"
+)
+
+// eciTab lists the standard ECI character set assignments.
+// When a character set has more than one designator,
+// the last one listed is the one used for encoding.
+var eciTab = []struct {
+	eci ECI
+	enc encoding.Encoding
+}{
+	{0, charmap.CodePage437},
+	{1, charmap.ISO8859_1},
+	{2, charmap.CodePage437},
+	{3, charmap.ISO8859_1},
+	{4, charmap.ISO8859_2},
+	{5, charmap.ISO8859_3},
+	{6, charmap.ISO8859_4},
+	{7, charmap.ISO8859_5},
+	{8, charmap.ISO8859_6},
+	{9, charmap.ISO8859_7},
+	{10, charmap.ISO8859_8},
+	{11, charmap.ISO8859_9},
+	{12, charmap.ISO8859_10},
+	{13, charmap.Windows874}, // ISO 8859-11 plus extensions
+	{15, charmap.ISO8859_13},
+	{16, charmap.ISO8859_14},
+	{17, charmap.ISO8859_15},
+	{18, charmap.ISO8859_16},
+	{20, japanese.ShiftJIS},
+	{21, charmap.Windows1250},
+	{22, charmap.Windows1251},
+	{23, charmap.Windows1252},
+	{24, charmap.Windows1256},
+	{25, unicode.UTF16(unicode.BigEndian, unicode.IgnoreBOM)},
+	{26, unicode.UTF8},
+	{28, traditionalchinese.Big5},
+	{29, simplifiedchinese.GBK}, // GB 2312 plus extensions
+	{30, korean.EUCKR},
+	{32, simplifiedchinese.GB18030},
+	{33, unicode.UTF16(unicode.LittleEndian, unicode.IgnoreBOM)},
+	{34, utf32.UTF32(utf32.BigEndian, utf32.IgnoreBOM)},
+	{35, utf32.UTF32(utf32.LittleEndian, utf32.IgnoreBOM)},
+}
+
+var eciReg struct {
+	sync.RWMutex
+	enc map[ECI]encoding.Encoding
+	eci map[encoding.Encoding]ECI
+}
+
+func init() {
+	eciReg.enc = make(map[ECI]encoding.Encoding)
+	eciReg.eci = make(map[encoding.Encoding]ECI)
+	for _, t := range eciTab {
+		eciReg.enc[t.eci] = t.enc
+		eciReg.eci[t.enc] = t.eci
+	}
+}
+
+// RegisterECI assigns the character set enc to designator d,
+// replacing any existing assignment for d, and makes d the
+// designator returned by CharsetECI(enc).
+// The dynamic type of enc must be comparable.
+func RegisterECI(d ECI, enc encoding.Encoding) {
+	eciReg.Lock()
+	defer eciReg.Unlock()
+	if old, ok := eciReg.enc[d]; ok && eciReg.eci[old] == d {
+		delete(eciReg.eci, old)
+	}
+	eciReg.enc[d] = enc
+	eciReg.eci[enc] = d
+}
+
+// ECICharset returns the character set assigned to designator d.
+func ECICharset(d ECI) (enc encoding.Encoding, ok bool) {
+	eciReg.RLock()
+	defer eciReg.RUnlock()
+	enc, ok = eciReg.enc[d]
+	return
+}
+
+// CharsetECI returns the designator assigned to the character set enc.
+func CharsetECI(enc encoding.Encoding) (d ECI, ok bool) {
+	eciReg.RLock()
+	defer eciReg.RUnlock()
+	d, ok = eciReg.eci[enc]
+	return
+}
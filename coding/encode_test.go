@@ -0,0 +1,680 @@
+// Copyright 2011 The Go Authors.  All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package coding
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"math/big"
+	"strings"
+	"testing"
+	"unicode/utf8"
+
+	"golang.org/x/text/encoding"
+	"golang.org/x/text/encoding/charmap"
+	"golang.org/x/text/encoding/unicode"
+)
+
+var encodeTests = []struct {
+	enc  Encoding
+	v    Version
+	bits []byte
+}{
+	// 1101 0001 00000010 1001000101111 0111100100011
+	// 1000 00000010 0110110011111 1101010101010
+	{Kanji("点茗"), 1, []byte{0x80, 0x26, 0xcf, 0xea, 0xa8}},
+	{Hanzi("中文"), 1, []byte{0xd1, 0x02, 0x91, 0x7b, 0xc8, 0xc0}},
+	{Latin1("né"), 1, []byte{0x40, 0x26, 0xee, 0x90}},
+	{&Reader{R: strings.NewReader("ab"), N: 2}, 1, []byte{0x40, 0x26, 0x16, 0x20}},
+	{Bytes{0x00, 0xff}, 1, []byte{0x40, 0x20, 0x0f, 0xf0}},
+	{Raw{Mode: 5}, 1, []byte{0x50}},
+	{Raw{Mode: 9, CountBits: 4, Count: 3, Data: []byte{0xab, 0xc0}, DataBits: 12}, 1, []byte{0x93, 0xab, 0xc0}},
+	{StructuredAppend{Index: 1, Total: 4, Parity: 0xa5}, 1, []byte{0x31, 0x3a, 0x50}},
+	{ECI(3), 1, []byte{0x70, 0x30}},
+	{ECI(1000), 1, []byte{0x78, 0x3e, 0x80}},
+	{ECI(100000), 1, []byte{0x7c, 0x18, 0x6a, 0x00}},
+	{UTF8("é"), 1, []byte{0x71, 0xa4, 0x02, 0xc3, 0xa9}},
+}
+
+func TestEncodeBits(t *testing.T) {
+	for _, tt := range encodeTests {
+		if err := tt.enc.Check(); err != nil {
+			t.Errorf("%v.Check(): %v", tt.enc, err)
+			continue
+		}
+		var b Bits
+		tt.enc.Encode(&b, tt.v)
+		if n := tt.enc.Bits(tt.v); n != b.Bits() {
+			t.Errorf("%v.Bits(%v) = %d, but Encode wrote %d", tt.enc, tt.v, n, b.Bits())
+		}
+		b.Write(0, -b.Bits()&7)
+		if !bytes.Equal(b.Bytes(), tt.bits) {
+			t.Errorf("%v.Encode(%v) = %x, want %x", tt.enc, tt.v, b.Bytes(), tt.bits)
+		}
+	}
+}
+
+func TestLatin1Check(t *testing.T) {
+	for _, s := range []string{"€", "a\xffb"} {
+		if err := Latin1(s).Check(); err == nil {
+			t.Errorf("Latin1(%q).Check() = nil, want error", s)
+		}
+	}
+}
+
+func TestKanjiCheck(t *testing.T) {
+	for _, s := range []string{"ab", "漢a", "\u00e9"} {
+		if err := Kanji(s).Check(); err == nil {
+			t.Errorf("Kanji(%q).Check() = nil, want error", s)
+		}
+		var b Bits
+		if err := Kanji(s).EncodeErr(&b, 1); err == nil || b.Bits() != 0 {
+			t.Errorf("Kanji(%q).EncodeErr() = %v, wrote %d bits, want error", s, err, b.Bits())
+		}
+	}
+}
+
+func TestHanziCheck(t *testing.T) {
+	for _, s := range []string{"abc", "漢字", "中文a"} {
+		if err := Hanzi(s).Check(); err == nil {
+			t.Errorf("Hanzi(%q).Check() = nil, want error", s)
+		}
+	}
+}
+
+func TestReaderShort(t *testing.T) {
+	r := &Reader{R: strings.NewReader("a"), N: 3}
+	var b Bits
+	r.Encode(&b, 1)
+	if b.Bits() != r.Bits(1) {
+		t.Errorf("Encode wrote %d bits, want %d", b.Bits(), r.Bits(1))
+	}
+	if err := r.Err(); err != io.ErrUnexpectedEOF {
+		t.Errorf("Err() = %v, want %v", err, io.ErrUnexpectedEOF)
+	}
+}
+
+// A countReader counts the bytes read from it.
+type countReader struct {
+	n int
+}
+
+func (r *countReader) Read(p []byte) (int, error) {
+	r.n += len(p)
+	return len(p), nil
+}
+
+func TestReaderLength(t *testing.T) {
+	var cr countReader
+	if _, err := Encode(1, L, &Reader{R: &cr, N: -1}); err == nil {
+		t.Errorf("Encode(Reader with N=-1) succeeded, want error")
+	}
+	var tooLong *ErrDataTooLong
+	if _, err := Encode(40, L, &Reader{R: &cr, N: 1 << 30}); !errors.As(err, &tooLong) {
+		t.Errorf("Encode(Reader with N=1<<30) = %v, want ErrDataTooLong", err)
+	}
+	if _, err := Encode(1, L, String("hello"), &Reader{R: &cr, N: 15}); !errors.As(err, &tooLong) {
+		t.Errorf("Encode(Reader too long for version 1) = %v, want ErrDataTooLong", err)
+	}
+	if cr.n != 0 {
+		t.Errorf("Encode read %d bytes from rejected Readers", cr.n)
+	}
+}
+
+func TestNumFrom(t *testing.T) {
+	x, _ := new(big.Int).SetString("123456789012345678901234567890", 10)
+	if n, err := NumFromInt(x); err != nil || n != "123456789012345678901234567890" {
+		t.Errorf("NumFromInt(%v) = %v, %v", x, n, err)
+	}
+	if _, err := NumFromInt(big.NewInt(-1)); err == nil {
+		t.Errorf("NumFromInt(-1) succeeded, want error")
+	}
+	if n, err := NumFromBytes([]byte{0, 4, 2}); err != nil || n != "042" {
+		t.Errorf("NumFromBytes(0, 4, 2) = %v, %v", n, err)
+	}
+	if _, err := NumFromBytes([]byte("1")); err == nil {
+		t.Errorf("NumFromBytes(\"1\") succeeded, want error")
+	}
+}
+
+func TestFoldAlpha(t *testing.T) {
+	if a, folded := FoldAlpha("http://example.com/ABC"); a != "HTTP://EXAMPLE.COM/ABC" || !folded {
+		t.Errorf("FoldAlpha = %v, %v", a, folded)
+	}
+	if a, folded := FoldAlpha("ABC 123"); a != "ABC 123" || folded {
+		t.Errorf("FoldAlpha = %v, %v", a, folded)
+	}
+}
+
+var splitTests = []struct {
+	text string
+	segs []Encoding
+}{
+	{"", []Encoding{String("")}},
+	{"hello", []Encoding{String("hello")}},
+	{"12345", []Encoding{Num("12345")}},
+	{"HELLO WORLD", []Encoding{Alpha("HELLO WORLD")}},
+	{"a1b", []Encoding{String("a1b")}},
+	{"https://example.com/t/12345678901234567890", []Encoding{
+		String("https://example.com/t/"),
+		Num("12345678901234567890"),
+	}},
+	{"id=12345678901234567890&x=y", []Encoding{
+		String("id="),
+		Num("12345678901234567890"),
+		String("&x=y"),
+	}},
+}
+
+func TestSplit(t *testing.T) {
+	for _, tt := range splitTests {
+		segs := Split(tt.text, 1)
+		if fmt.Sprint(segs) != fmt.Sprint(tt.segs) {
+			t.Errorf("Split(%q) = %v, want %v", tt.text, segs, tt.segs)
+		}
+	}
+}
+
+func TestErrDataTooLong(t *testing.T) {
+	_, err := Encode(1, H, Num(strings.Repeat("1", 20)))
+	cerr, ok := err.(*ErrDataTooLong)
+	if !ok {
+		t.Fatalf("Encode: %v, want *ErrDataTooLong", err)
+	}
+	if cerr.Bits != 81 || cerr.Capacity != 72 || cerr.MinVersion != 2 || cerr.Level != H {
+		t.Errorf("Encode: %+v", *cerr)
+	}
+
+	_, err = Encode(40, H, Bytes(make([]byte, 2000)))
+	cerr, ok = err.(*ErrDataTooLong)
+	if !ok {
+		t.Fatalf("Encode: %v, want *ErrDataTooLong", err)
+	}
+	if cerr.MinVersion != 38 || cerr.Level != M {
+		t.Errorf("Encode: %+v", *cerr)
+	}
+}
+
+func TestBitReader(t *testing.T) {
+	var b Bits
+	Num("01234567").Encode(&b, 1)
+	b.Write(0, -b.Bits()&7)
+	r := NewBitReader(b.Bytes())
+	if m := r.ReadBits(4); m != 1 {
+		t.Errorf("mode = %d, want 1", m)
+	}
+	if n := r.ReadBits(10); n != 8 {
+		t.Errorf("count = %d, want 8", n)
+	}
+	for _, want := range []uint{12, 345, 67} {
+		nbit := 10
+		if want == 67 {
+			nbit = 7
+		}
+		if w := r.ReadBits(nbit); w != want {
+			t.Errorf("ReadBits(%d) = %d, want %d", nbit, w, want)
+		}
+	}
+	if r.Aligned() || r.Remaining() != 7 {
+		t.Errorf("Aligned() = %v, Remaining() = %d, want false, 7", r.Aligned(), r.Remaining())
+	}
+	r.Align()
+	if !r.Aligned() || r.Remaining() != 0 || r.Offset() != b.Bits() {
+		t.Errorf("after Align: Aligned() = %v, Remaining() = %d, Offset() = %d", r.Aligned(), r.Remaining(), r.Offset())
+	}
+}
+
+func TestTotalBits(t *testing.T) {
+	// 4+10+7 bits of Num, 4+9+11 bits of Alpha, 4 bits of terminator,
+	// 1 bit of padding.
+	if n := TotalBits(1, Num("12"), Alpha("AB")); n != 56 {
+		t.Errorf("TotalBits = %d, want 56", n)
+	}
+}
+
+func TestECIRegistry(t *testing.T) {
+	if enc, ok := ECICharset(eciUTF8); !ok || enc != unicode.UTF8 {
+		t.Errorf("ECICharset(%d) = %v, %v, want UTF-8", eciUTF8, enc, ok)
+	}
+	if d, ok := CharsetECI(charmap.ISO8859_1); !ok || d != 3 {
+		t.Errorf("CharsetECI(ISO8859_1) = %v, %v, want 3", d, ok)
+	}
+	if _, ok := CharsetECI(charmap.KOI8R); ok {
+		t.Errorf("CharsetECI(KOI8R) succeeded before registration")
+	}
+	restoreECIRegistry(t)
+	RegisterECI(899, charmap.KOI8R)
+	if d, ok := CharsetECI(charmap.KOI8R); !ok || d != 899 {
+		t.Errorf("CharsetECI(KOI8R) = %v, %v, want 899", d, ok)
+	}
+	if enc, ok := ECICharset(899); !ok || enc != charmap.KOI8R {
+		t.Errorf("ECICharset(899) = %v, %v, want KOI8R", enc, ok)
+	}
+}
+
+// restoreECIRegistry arranges for the ECI registry to be restored
+// to its current state when t finishes.
+func restoreECIRegistry(t *testing.T) {
+	eciReg.RLock()
+	enc := make(map[ECI]encoding.Encoding, len(eciReg.enc))
+	for d, e := range eciReg.enc {
+		enc[d] = e
+	}
+	eci := make(map[encoding.Encoding]ECI, len(eciReg.eci))
+	for e, d := range eciReg.eci {
+		eci[e] = d
+	}
+	eciReg.RUnlock()
+	t.Cleanup(func() {
+		eciReg.Lock()
+		eciReg.enc, eciReg.eci = enc, eci
+		eciReg.Unlock()
+	})
+}
+
+func TestCountBits(t *testing.T) {
+	for _, tt := range []struct {
+		m    Mode
+		v    Version
+		want int
+	}{
+		{ModeNumeric, 1, 10}, {ModeNumeric, 10, 12}, {ModeNumeric, 27, 14},
+		{ModeAlphanumeric, 9, 9}, {ModeByte, 26, 16}, {ModeKanji, 40, 12},
+		{ModeHanzi, 1, 8}, {ModeECI, 1, 0},
+	} {
+		if n := CountBits(tt.m, tt.v); n != tt.want {
+			t.Errorf("CountBits(%v, %v) = %d, want %d", tt.m, tt.v, n, tt.want)
+		}
+	}
+}
+
+func TestPad(t *testing.T) {
+	for _, tt := range []struct {
+		pad  Padding
+		want []byte
+		npad int
+	}{
+		{0, []byte{0xab, 0xcd, 0x00, 0xec, 0x11, 0xec}, 3},
+		{PadZeroBytes, []byte{0xab, 0xcd, 0x00, 0x00, 0x00, 0x00}, 3},
+		{PadNoTerminator, []byte{0xab, 0xcd, 0xec, 0x11, 0xec, 0x11}, 4},
+		{PadNoTerminator | PadZeroBytes, []byte{0xab, 0xcd, 0x00, 0x00, 0x00, 0x00}, 4},
+	} {
+		b := Bits{Padding: tt.pad}
+		b.Write(0xabcd, 16)
+		b.Pad(48 - b.Bits())
+		if !bytes.Equal(b.Bytes(), tt.want) || b.PadCodewords() != tt.npad {
+			t.Errorf("Pad with %d = %x, %d codewords, want %x, %d", tt.pad, b.Bytes(), b.PadCodewords(), tt.want, tt.npad)
+		}
+	}
+}
+
+func TestSmallestVersion(t *testing.T) {
+	if v, err := SmallestVersion(H, Num(strings.Repeat("1", 20))); v != 2 || err != nil {
+		t.Errorf("SmallestVersion = %v, %v, want 2, nil", v, err)
+	}
+	if _, err := SmallestVersion(H, Bytes(make([]byte, 2000))); err == nil {
+		t.Errorf("SmallestVersion succeeded for 2000 bytes at H")
+	}
+	c, err := EncodeSmallest(M, String("hello, world"))
+	if err != nil || c.Size != 21 {
+		t.Errorf("EncodeSmallest = %v, %v, want size 21", c, err)
+	}
+}
+
+func TestCapacity(t *testing.T) {
+	for _, tt := range []struct {
+		v    Version
+		l    Level
+		m    Mode
+		want int
+	}{
+		{1, L, ModeNumeric, 41},
+		{1, L, ModeAlphanumeric, 25},
+		{1, L, ModeByte, 17},
+		{1, L, ModeKanji, 10},
+		{1, H, ModeNumeric, 17},
+		{10, M, ModeByte, 213},
+		{40, L, ModeNumeric, 7089},
+		{40, L, ModeAlphanumeric, 4296},
+		{40, L, ModeByte, 2953},
+		{40, L, ModeKanji, 1817},
+		{40, H, ModeByte, 1273},
+		{1, L, ModeECI, 0},
+	} {
+		if c := Capacity(tt.v, tt.l, tt.m); c != tt.want {
+			t.Errorf("Capacity(%v, %v, %v) = %d, want %d", tt.v, tt.l, tt.m, c, tt.want)
+		}
+	}
+}
+
+func TestEncodeTo(t *testing.T) {
+	for _, mask := range []Mask{-1, 3} {
+		p, err := NewPlan(5, M, mask)
+		if err != nil {
+			t.Fatal(err)
+		}
+		var dst Code
+		for _, text := range []string{"hello, world", "0123456789", "HELLO WORLD"} {
+			want, err := p.Encode(String(text))
+			if err != nil {
+				t.Fatal(err)
+			}
+			old := dst.Bitmap
+			if err := p.EncodeTo(&dst, String(text)); err != nil {
+				t.Fatal(err)
+			}
+			if dst.Size != want.Size || dst.Stride != want.Stride || !bytes.Equal(dst.Bitmap, want.Bitmap) {
+				t.Errorf("mask %d: EncodeTo(%q) differs from Encode", mask, text)
+			}
+			if old != nil && &old[0] != &dst.Bitmap[0] {
+				t.Errorf("mask %d: EncodeTo(%q) did not reuse bitmap", mask, text)
+			}
+		}
+	}
+}
+
+func TestCloneEqual(t *testing.T) {
+	c, err := Encode(1, L, String("hello"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	d := c.Clone()
+	if !c.Equal(d) {
+		t.Fatalf("Clone not Equal to original")
+	}
+	d.Bitmap[0] ^= 0x80
+	if c.Equal(d) || c.Bitmap[0] == d.Bitmap[0] {
+		t.Fatalf("Clone shares bitmap with original")
+	}
+	d.Bitmap[0] ^= 0x80
+
+	// Nor does it share Info's slices.
+	c.Info.Segments = append(c.Info.Segments, Raw{Mode: 0, Data: []byte{1}}, Bytes{1})
+	c.Info.Corrected = []int{1, 2}
+	d = c.Clone()
+	c.Info.Segments[0] = String("world")
+	c.Info.Segments[1].(Raw).Data[0] = 2
+	c.Info.Segments[2].(Bytes)[0] = 2
+	c.Info.Corrected[0] = 3
+	if d.Info.Segments[0] != String("hello") || d.Info.Segments[1].(Raw).Data[0] != 1 ||
+		d.Info.Segments[2].(Bytes)[0] != 1 || d.Info.Corrected[0] != 1 {
+		t.Fatalf("Clone shares Info with original: %v %v", d.Info.Segments, d.Info.Corrected)
+	}
+
+	// Padding bits and stride do not matter.
+	d.Bitmap[d.Stride-1] |= 1
+	if !c.Equal(d) {
+		t.Errorf("Equal compares padding bits")
+	}
+	wide := &Code{Size: c.Size, Stride: c.Stride + 1, Bitmap: make([]byte, c.Size*(c.Stride+1))}
+	for y := 0; y < c.Size; y++ {
+		copy(wide.Bitmap[y*wide.Stride:], c.Bitmap[y*c.Stride:(y+1)*c.Stride])
+	}
+	if !c.Equal(wide) || !wide.Equal(c) {
+		t.Errorf("Equal compares strides")
+	}
+}
+
+func TestMatrix(t *testing.T) {
+	c, err := Encode(1, L, String("hello"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	m := c.Matrix()
+	if len(m) != c.Size {
+		t.Fatalf("Matrix has %d rows, want %d", len(m), c.Size)
+	}
+	for y, row := range m {
+		for x, black := range row {
+			if black != c.Black(x, y) {
+				t.Fatalf("Matrix()[%d][%d] = %v, want %v", y, x, black, c.Black(x, y))
+			}
+		}
+	}
+	d, err := NewCodeFromMatrix(m)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !c.Equal(d) || d.Info != nil {
+		t.Errorf("NewCodeFromMatrix(c.Matrix()) differs from c")
+	}
+
+	for _, bad := range [][][]bool{
+		nil,
+		{{true, false}},
+		{{true, false}, {true}},
+	} {
+		if _, err := NewCodeFromMatrix(bad); err == nil {
+			t.Errorf("NewCodeFromMatrix(%v) succeeded, want error", bad)
+		}
+	}
+}
+
+func TestCodeString(t *testing.T) {
+	c, err := Encode(1, L, String("hello"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	lines := strings.Split(c.String(), "\n")
+	d := c.Size + 8
+	if len(lines) != (d+1)/2+1 || lines[len(lines)-1] != "" {
+		t.Fatalf("String has %d lines, want %d", len(lines)-1, (d+1)/2)
+	}
+	for i, line := range lines[:len(lines)-1] {
+		row := []rune(line)
+		if len(row) != d {
+			t.Fatalf("line %d has %d characters, want %d", i, len(row), d)
+		}
+		for x, r := range row {
+			top, bot := c.Black(x-4, 2*i-4), c.Black(x-4, 2*i+1-4)
+			want := [2][2]rune{{' ', '▄'}, {'▀', '█'}}[b2i(top)][b2i(bot)]
+			if r != want {
+				t.Fatalf("line %d column %d = %q, want %q", i, x, r, want)
+			}
+		}
+	}
+	// The top finder pattern starts on line 2.
+	if !strings.HasPrefix(lines[2], "    █▀▀▀▀▀█ ") {
+		t.Errorf("line 2 = %q, want finder pattern top", lines[2])
+	}
+	if g := fmt.Sprintf("%#v", c); !strings.HasPrefix(g, "coding.Code{Size: 21, Stride: 3, Version: 1, Level: L, Mask: ") ||
+		!strings.HasSuffix(g, c.String()) {
+		t.Errorf("GoString = %q", g)
+	}
+}
+
+func b2i(b bool) int {
+	if b {
+		return 1
+	}
+	return 0
+}
+
+func TestInfoJSON(t *testing.T) {
+	info := Info{
+		Version: 3,
+		Level:   H,
+		Mask:    5,
+		Segments: []Encoding{
+			StructuredAppend{Index: 1, Total: 2, Parity: 0x5a},
+			ECI(26),
+			Num("0123"),
+			Alpha("AB"),
+			String("x\xff\xfe"),
+			Bytes{0, 0xff},
+			Latin1("é"),
+			UTF8("ü"),
+			Kanji("点"),
+			Hanzi("中"),
+			Raw{Mode: 4, CountBits: 8, Count: 1, Data: []byte{0x41}},
+			&Reader{N: 7},
+		},
+		DataBits: 100,
+		PadBytes: 3,
+	}
+	data, err := json.Marshal(info)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var out Info
+	if err := json.Unmarshal(data, &out); err != nil {
+		t.Fatal(err)
+	}
+	if fmt.Sprintf("%v", out) != fmt.Sprintf("%v", info) {
+		t.Errorf("Unmarshal(Marshal(info)) = %v, want %v", out, info)
+	}
+	if s := out.Segments[4]; s != String("x\xff\xfe") {
+		t.Errorf("Unmarshal(Marshal(info)) String segment = %q, want %q", s, "x\xff\xfe")
+	}
+
+	if _, err := json.Marshal(Info{Segments: []Encoding{nil}}); err == nil {
+		t.Errorf("Marshal of nil segment succeeded")
+	}
+	if err := json.Unmarshal([]byte(`{"version":1,"level":"L","segments":[{"type":"Bogus"}]}`), &out); err == nil {
+		t.Errorf("Unmarshal of unknown segment type succeeded")
+	}
+	if err := json.Unmarshal([]byte(`{"version":1,"level":"Z"}`), &out); !errors.Is(err, ErrBadLevel) {
+		t.Errorf("Unmarshal of level Z = %v, want ErrBadLevel", err)
+	}
+	for _, tt := range []struct {
+		json string
+		err  error
+	}{
+		{`{"version":0,"level":"L","mask":0}`, ErrBadVersion},
+		{`{"version":41,"level":"L","mask":0}`, ErrBadVersion},
+		{`{"version":1,"level":"L","mask":-1}`, ErrBadMask},
+		{`{"version":1,"level":"L","mask":8}`, ErrBadMask},
+	} {
+		if err := json.Unmarshal([]byte(tt.json), &out); !errors.Is(err, tt.err) {
+			t.Errorf("Unmarshal(%s) = %v, want %v", tt.json, err, tt.err)
+		}
+	}
+}
+
+var invalidCharTests = []struct {
+	enc  Encoding
+	char ErrInvalidChar
+}{
+	{Num("0123x5"), ErrInvalidChar{'x', 4, ModeNumeric}},
+	{Alpha("HELLO, WORLD"), ErrInvalidChar{',', 5, ModeAlphanumeric}},
+	{Latin1("naïve ☃"), ErrInvalidChar{'☃', 7, ModeByte}},
+	{UTF8("ok\xffno"), ErrInvalidChar{utf8.RuneError, 2, ModeByte}},
+	{Kanji("点茗x"), ErrInvalidChar{'x', 6, ModeKanji}},
+	{Hanzi("中文a"), ErrInvalidChar{'a', 6, ModeHanzi}},
+}
+
+func TestErrInvalidChar(t *testing.T) {
+	for _, tt := range invalidCharTests {
+		err := tt.enc.Check()
+		var cerr *ErrInvalidChar
+		if !errors.As(err, &cerr) {
+			t.Errorf("%v.Check() = %v, want *ErrInvalidChar", tt.enc, err)
+			continue
+		}
+		if *cerr != tt.char {
+			t.Errorf("%v.Check() = %+v, want %+v", tt.enc, *cerr, tt.char)
+		}
+	}
+}
+
+func TestErrBad(t *testing.T) {
+	if _, err := NewPlan(41, L, 0); !errors.Is(err, ErrBadVersion) {
+		t.Errorf("NewPlan(41, L, 0) = %v, want ErrBadVersion", err)
+	}
+	if _, err := NewPlan(1, H+1, 0); !errors.Is(err, ErrBadLevel) {
+		t.Errorf("NewPlan(1, H+1, 0) = %v, want ErrBadLevel", err)
+	}
+	if _, err := NewPlan(1, L, 8); !errors.Is(err, ErrBadMask) {
+		t.Errorf("NewPlan(1, L, 8) = %v, want ErrBadMask", err)
+	}
+}
+
+func TestPlanCache(t *testing.T) {
+	defer SetPlanCacheLimit(0)
+
+	ClearPlanCache()
+	if n := PlanCacheSize(); n != 0 {
+		t.Fatalf("PlanCacheSize after ClearPlanCache = %d, want 0", n)
+	}
+	if err := PreloadPlans([]Version{1, 2, 3}, []Level{L, M}); err != nil {
+		t.Fatal(err)
+	}
+	n := PlanCacheSize()
+	if n == 0 {
+		t.Fatalf("PlanCacheSize after PreloadPlans = 0")
+	}
+	if err := PreloadPlans([]Version{41}, []Level{L}); !errors.Is(err, ErrBadVersion) {
+		t.Errorf("PreloadPlans(41) = %v, want ErrBadVersion", err)
+	}
+
+	// A limit keeps only the most recently used plans.
+	SetPlanCacheLimit(n / 2)
+	if m := PlanCacheSize(); m > n/2 || m == 0 {
+		t.Errorf("PlanCacheSize with limit %d = %d", n/2, m)
+	}
+	if _, err := Encode(40, H, String("hello")); err != nil {
+		t.Fatal(err)
+	}
+	if m := PlanCacheSize(); m < n/2 {
+		t.Errorf("PlanCacheSize after version 40 plan = %d, want at least %d", m, n/2)
+	}
+
+	// Concurrent use is safe.
+	ClearPlanCache()
+	SetPlanCacheLimit(0)
+	done := make(chan bool)
+	for i := 0; i < 8; i++ {
+		go func(i int) {
+			defer func() { done <- true }()
+			for v := Version(1); v <= 5; v++ {
+				if _, err := Encode(v, Level(i%4), String("hello")); err != nil {
+					t.Error(err)
+				}
+			}
+		}(i)
+	}
+	for i := 0; i < 8; i++ {
+		<-done
+	}
+	ClearPlanCache()
+}
+
+func TestInfo(t *testing.T) {
+	c, err := Encode(2, M, Num("0123456789"), String("hello"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	info := c.Info
+	if info == nil {
+		t.Fatal("Encode returned nil Info")
+	}
+	bits := 4 + 10 + 34 + 4 + 8 + 40
+	if info.Version != 2 || info.Level != M || info.DataBits != bits || len(info.Segments) != 2 {
+		t.Errorf("Info = %+v", *info)
+	}
+	// 28 data bytes: 13 bytes of data and terminator, then 15 pad bytes.
+	if info.PadBytes != 15 {
+		t.Errorf("Info.PadBytes = %d, want 15", info.PadBytes)
+	}
+	if info.Penalty != c.Penalty() {
+		t.Errorf("Info.Penalty = %d, want %d", info.Penalty, c.Penalty())
+	}
+
+	// The chosen mask reproduces the code.
+	p, err := NewPlan(2, M, info.Mask)
+	if err != nil {
+		t.Fatal(err)
+	}
+	c1, err := p.Encode(Num("0123456789"), String("hello"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !c1.Equal(c) || c1.Info.Mask != info.Mask || c1.Info.Penalty != info.Penalty {
+		t.Errorf("NewPlan(2, M, %d).Encode differs from auto-masked code", info.Mask)
+	}
+}
@@ -0,0 +1,181 @@
+// Copyright 2011 The Go Authors.  All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package coding
+
+// JSON encoding of encode metadata.
+
+import (
+	"encoding/json"
+	"fmt"
+	"strconv"
+)
+
+// MarshalText returns l as "L", "M", "Q", or "H".
+func (l Level) MarshalText() ([]byte, error) {
+	if l < L || l > H {
+		return nil, fmt.Errorf("%w %d", ErrBadLevel, int(l))
+	}
+	return []byte(l.String()), nil
+}
+
+// UnmarshalText sets l from "L", "M", "Q", or "H".
+func (l *Level) UnmarshalText(text []byte) error {
+	for v := L; v <= H; v++ {
+		if string(text) == v.String() {
+			*l = v
+			return nil
+		}
+	}
+	return fmt.Errorf("%w %s", ErrBadLevel, strconv.Quote(string(text)))
+}
+
+// A jsonSegment is the JSON form of an Encoding.
+// Type names the Encoding's Go type; the other fields
+// hold the values that type uses.
+type jsonSegment struct {
+	Type      string `json:"type"`
+	Text      string `json:"text,omitempty"`
+	Data      []byte `json:"data,omitempty"`
+	ECI       int    `json:"eci,omitempty"`
+	Index     int    `json:"index,omitempty"`
+	Total     int    `json:"total,omitempty"`
+	Parity    byte   `json:"parity,omitempty"`
+	Mode      uint   `json:"mode,omitempty"`
+	CountBits int    `json:"countBits,omitempty"`
+	Count     uint   `json:"count,omitempty"`
+	DataBits  int    `json:"dataBits,omitempty"`
+	N         int    `json:"n,omitempty"`
+}
+
+// A jsonInfo is the JSON form of an Info.
+type jsonInfo struct {
+	Version      Version       `json:"version"`
+	Level        Level         `json:"level"`
+	Mask         Mask          `json:"mask"`
+	Penalty      int           `json:"penalty"`
+	Segments     []jsonSegment `json:"segments"`
+	DataBits     int           `json:"dataBits"`
+	PadBytes     int           `json:"padBytes"`
+	Corrected    []int         `json:"corrected,omitempty"`
+	FormatErrors int           `json:"formatErrors,omitempty"`
+}
+
+// MarshalJSON encodes i as a JSON object.  Each segment is an object
+// whose "type" field names its Encoding type, such as "Num" or "UTF8",
+// and whose other fields hold its contents.  The contents of Bytes,
+// String, and Raw segments, which need not be UTF-8, are base64 data.  Segments of types
+// not defined in this package cannot be marshaled.
+func (i Info) MarshalJSON() ([]byte, error) {
+	j := jsonInfo{
+		Version:      i.Version,
+		Level:        i.Level,
+		Mask:         i.Mask,
+		Penalty:      i.Penalty,
+		Segments:     make([]jsonSegment, 0, len(i.Segments)),
+		DataBits:     i.DataBits,
+		PadBytes:     i.PadBytes,
+		Corrected:    i.Corrected,
+		FormatErrors: i.FormatErrors,
+	}
+	for _, e := range i.Segments {
+		var s jsonSegment
+		switch e := e.(type) {
+		case Num:
+			s = jsonSegment{Type: "Num", Text: string(e)}
+		case Alpha:
+			s = jsonSegment{Type: "Alpha", Text: string(e)}
+		case String:
+			// String holds byte data, which need not be UTF-8.
+			s = jsonSegment{Type: "String", Data: []byte(e)}
+		case Latin1:
+			s = jsonSegment{Type: "Latin1", Text: string(e)}
+		case UTF8:
+			s = jsonSegment{Type: "UTF8", Text: string(e)}
+		case Kanji:
+			s = jsonSegment{Type: "Kanji", Text: string(e)}
+		case Hanzi:
+			s = jsonSegment{Type: "Hanzi", Text: string(e)}
+		case Bytes:
+			s = jsonSegment{Type: "Bytes", Data: []byte(e)}
+		case ECI:
+			s = jsonSegment{Type: "ECI", ECI: int(e)}
+		case StructuredAppend:
+			s = jsonSegment{Type: "StructuredAppend", Index: e.Index, Total: e.Total, Parity: e.Parity}
+		case Raw:
+			s = jsonSegment{Type: "Raw", Mode: e.Mode, CountBits: e.CountBits, Count: e.Count, Data: e.Data, DataBits: e.DataBits}
+		case *Reader:
+			s = jsonSegment{Type: "Reader", N: e.N}
+		default:
+			return nil, fmt.Errorf("cannot marshal QR segment of type %T", e)
+		}
+		j.Segments = append(j.Segments, s)
+	}
+	return json.Marshal(j)
+}
+
+// UnmarshalJSON decodes an Info encoded by MarshalJSON.
+// It rejects an invalid version, level, or mask.
+// A Reader segment, whose data is not recorded, is decoded as a Reader
+// with the original length and a nil R.
+func (i *Info) UnmarshalJSON(data []byte) error {
+	var j jsonInfo
+	if err := json.Unmarshal(data, &j); err != nil {
+		return err
+	}
+	if j.Version < MinVersion || j.Version > MaxVersion {
+		return fmt.Errorf("%w %d", ErrBadVersion, int(j.Version))
+	}
+	if j.Level < L || j.Level > H {
+		return fmt.Errorf("%w %d", ErrBadLevel, int(j.Level))
+	}
+	if j.Mask < 0 || 7 < j.Mask {
+		return fmt.Errorf("%w %d", ErrBadMask, int(j.Mask))
+	}
+	segs := make([]Encoding, 0, len(j.Segments))
+	for _, s := range j.Segments {
+		var e Encoding
+		switch s.Type {
+		case "Num":
+			e = Num(s.Text)
+		case "Alpha":
+			e = Alpha(s.Text)
+		case "String":
+			e = String(s.Data)
+		case "Latin1":
+			e = Latin1(s.Text)
+		case "UTF8":
+			e = UTF8(s.Text)
+		case "Kanji":
+			e = Kanji(s.Text)
+		case "Hanzi":
+			e = Hanzi(s.Text)
+		case "Bytes":
+			e = Bytes(s.Data)
+		case "ECI":
+			e = ECI(s.ECI)
+		case "StructuredAppend":
+			e = StructuredAppend{Index: s.Index, Total: s.Total, Parity: s.Parity}
+		case "Raw":
+			e = Raw{Mode: s.Mode, CountBits: s.CountBits, Count: s.Count, Data: s.Data, DataBits: s.DataBits}
+		case "Reader":
+			e = &Reader{N: s.N}
+		default:
+			return fmt.Errorf("unknown QR segment type %q", s.Type)
+		}
+		segs = append(segs, e)
+	}
+	*i = Info{
+		Version:      j.Version,
+		Level:        j.Level,
+		Mask:         j.Mask,
+		Penalty:      j.Penalty,
+		Segments:     segs,
+		DataBits:     j.DataBits,
+		PadBytes:     j.PadBytes,
+		Corrected:    j.Corrected,
+		FormatErrors: j.FormatErrors,
+	}
+	return nil
+}
@@ -6,12 +6,18 @@
 package coding // import "rsc.io/qr/coding"
 
 import (
+	"context"
+	"errors"
 	"fmt"
+	"io"
+	"math/big"
 	"strconv"
 	"strings"
 	"sync"
+	"unicode/utf8"
 
 	"golang.org/x/text/encoding/japanese"
+	"golang.org/x/text/encoding/simplifiedchinese"
 	"github.com/inkstray/rsc-qr/gf256"
 )
 
@@ -50,6 +56,13 @@ func (v Version) DataBytes(l Level) int {
 	return vt.bytes - lev.nblock*lev.check
 }
 
+// BlockCheckBytes returns the number of error correcting check bytes
+// in each block of a QR code with the given version and level.
+// A block can correct errors in up to half of them.
+func (v Version) BlockCheckBytes(l Level) int {
+	return vtab[v].level[l].check
+}
+
 // Encoding implements a QR data encoding scheme.
 // The implementations--Numeric, Alphanumeric, and String--specify
 // the character set and the mapping from UTF-8 to code bits.
@@ -60,14 +73,39 @@ type Encoding interface {
 	Encode(b *Bits, v Version)
 }
 
+// An ErrEncoding is an Encoding whose encoding step can fail,
+// such as one that must transcode its text or read it from elsewhere.
+// EncodeErr is like Encode but returns an error instead of writing
+// an invalid segment, and it need not be preceded by a call to Check.
+// Plan.Encode uses EncodeErr when it is available.
+type ErrEncoding interface {
+	Encoding
+	EncodeErr(b *Bits, v Version) error
+}
+
 type Bits struct {
 	b    []byte
 	nbit int
+	npad int // pad codewords written by Pad
+
+	// Padding controls how Pad fills unused data capacity.
+	Padding Padding
 }
 
+// Padding selects how Bits.Pad fills the unused data capacity of a code.
+// The zero Padding is the standard one: a 4-bit terminator, zero bits
+// to the next byte boundary, then pad codewords alternating 0xEC and 0x11.
+type Padding int
+
+const (
+	PadNoTerminator Padding = 1 << iota // omit the 4-bit terminator
+	PadZeroBytes                        // use 0x00 for all pad codewords
+)
+
 func (b *Bits) Reset() {
 	b.b = b.b[:0]
 	b.nbit = 0
+	b.npad = 0
 }
 
 func (b *Bits) Bits() int {
@@ -111,6 +149,152 @@ func (b *Bits) Write(v uint, nbit int) {
 	}
 }
 
+// A Mode is a 4-bit mode indicator, which begins each segment
+// and determines how the rest of the segment is interpreted.
+type Mode uint
+
+const (
+	ModeTerminator       Mode = 0  // end of data
+	ModeNumeric          Mode = 1  // Num
+	ModeAlphanumeric     Mode = 2  // Alpha
+	ModeStructuredAppend Mode = 3  // StructuredAppend
+	ModeByte             Mode = 4  // String, Bytes, Latin1, Reader
+	ModeFNC1First        Mode = 5  // GS1 data
+	ModeECI              Mode = 7  // ECI
+	ModeKanji            Mode = 8  // Kanji
+	ModeFNC1Second       Mode = 9  // industry application data
+	ModeHanzi            Mode = 13 // Hanzi
+)
+
+var modes = map[Mode]string{
+	ModeTerminator:       "terminator",
+	ModeNumeric:          "numeric",
+	ModeAlphanumeric:     "alphanumeric",
+	ModeStructuredAppend: "structured append",
+	ModeByte:             "byte",
+	ModeFNC1First:        "fnc1 first",
+	ModeECI:              "eci",
+	ModeKanji:            "kanji",
+	ModeFNC1Second:       "fnc1 second",
+	ModeHanzi:            "hanzi",
+}
+
+func (m Mode) String() string {
+	if s, ok := modes[m]; ok {
+		return s
+	}
+	return strconv.Itoa(int(m))
+}
+
+// CountBits returns the width in bits of the character count field
+// that follows mode indicator m in a code of version v.
+// It returns 0 for modes that have no count field.
+func CountBits(m Mode, v Version) int {
+	switch m {
+	case ModeNumeric:
+		return numLen[v.sizeClass()]
+	case ModeAlphanumeric:
+		return alphaLen[v.sizeClass()]
+	case ModeByte:
+		return stringLen[v.sizeClass()]
+	case ModeKanji, ModeHanzi:
+		return kanjiLen[v.sizeClass()]
+	}
+	return 0
+}
+
+// Capacity returns the maximum number of characters that a code
+// with version v and level l can hold in a single segment of mode m.
+// Characters are digits for ModeNumeric, bytes for ModeByte,
+// and double-byte characters for ModeKanji and ModeHanzi.
+// Capacity returns 0 for other modes.
+func Capacity(v Version, l Level, m Mode) int {
+	n := CountBits(m, v)
+	if n == 0 {
+		return 0
+	}
+	bits := v.DataBytes(l)*8 - 4 - n
+	var c int
+	switch m {
+	case ModeNumeric:
+		c = bits / 10 * 3
+		switch r := bits % 10; {
+		case r >= 7:
+			c += 2
+		case r >= 4:
+			c++
+		}
+	case ModeAlphanumeric:
+		c = bits / 11 * 2
+		if bits%11 >= 6 {
+			c++
+		}
+	case ModeByte:
+		c = bits / 8
+	case ModeKanji:
+		c = bits / 13
+	case ModeHanzi:
+		c = (bits - 4) / 13
+	}
+	if max := 1<<uint(n) - 1; c > max {
+		c = max
+	}
+	return c
+}
+
+// A BitReader reads a sequence of bits written by Bits,
+// most significant bit first.
+type BitReader struct {
+	b    []byte
+	nbit int // number of bits read
+}
+
+// NewBitReader returns a BitReader reading the bits in p.
+func NewBitReader(p []byte) *BitReader {
+	return &BitReader{b: p}
+}
+
+// ReadBits reads and returns the next nbit bits, for nbit ≤ 32.
+// It panics if fewer than nbit bits remain.
+func (r *BitReader) ReadBits(nbit int) uint {
+	if nbit < 0 || nbit > 32 || nbit > r.Remaining() {
+		panic("qr: invalid bit read")
+	}
+	var v uint
+	for nbit > 0 {
+		m := 8 - r.nbit&7 // bits left in current byte
+		n := nbit
+		if n > m {
+			n = m
+		}
+		c := uint(r.b[r.nbit/8]) >> uint(m-n) & (1<<uint(n) - 1)
+		v = v<<uint(n) | c
+		r.nbit += n
+		nbit -= n
+	}
+	return v
+}
+
+// Remaining returns the number of bits left to read.
+func (r *BitReader) Remaining() int {
+	return 8*len(r.b) - r.nbit
+}
+
+// Offset returns the number of bits read so far.
+func (r *BitReader) Offset() int {
+	return r.nbit
+}
+
+// Aligned reports whether the reader is at a byte boundary.
+func (r *BitReader) Aligned() bool {
+	return r.nbit&7 == 0
+}
+
+// Align skips to the next byte boundary, if not already there.
+func (r *BitReader) Align() {
+	r.nbit += -r.nbit & 7
+}
+
 // Num is the encoding for numeric data.
 // The only valid characters are the decimal digits 0 through 9.
 type Num string
@@ -120,14 +304,36 @@ func (s Num) String() string {
 }
 
 func (s Num) Check() error {
-	for _, c := range s {
+	for i, c := range s {
 		if c < '0' || '9' < c {
-			return fmt.Errorf("non-numeric string %#q", string(s))
+			return &ErrInvalidChar{Rune: c, Pos: i, Mode: ModeNumeric}
 		}
 	}
 	return nil
 }
 
+// NumFromInt returns the numeric encoding of the decimal digits of x,
+// which must not be negative.
+func NumFromInt(x *big.Int) (Num, error) {
+	if x.Sign() < 0 {
+		return "", fmt.Errorf("negative number %v", x)
+	}
+	return Num(x.Append(nil, 10)), nil
+}
+
+// NumFromBytes returns the numeric encoding of the digits d,
+// each of which must be a value from 0 to 9 (not an ASCII digit).
+func NumFromBytes(d []byte) (Num, error) {
+	b := make([]byte, len(d))
+	for i, c := range d {
+		if c > 9 {
+			return "", &ErrInvalidChar{Rune: rune(c), Pos: i, Mode: ModeNumeric}
+		}
+		b[i] = '0' + c
+	}
+	return Num(b), nil
+}
+
 var numLen = [3]int{10, 12, 14}
 
 func (s Num) Bits(v Version) int {
@@ -135,7 +341,7 @@ func (s Num) Bits(v Version) int {
 }
 
 func (s Num) Encode(b *Bits, v Version) {
-	b.Write(1, 4)
+	b.Write(uint(ModeNumeric), 4)
 	b.Write(uint(len(s)), numLen[v.sizeClass()])
 	var i int
 	for i = 0; i+3 <= len(s); i += 3 {
@@ -163,14 +369,30 @@ func (s Alpha) String() string {
 }
 
 func (s Alpha) Check() error {
-	for _, c := range s {
+	for i, c := range s {
 		if strings.IndexRune(alphabet, c) < 0 {
-			return fmt.Errorf("non-alphanumeric string %#q", string(s))
+			return &ErrInvalidChar{Rune: c, Pos: i, Mode: ModeAlphanumeric}
 		}
 	}
 	return nil
 }
 
+// FoldAlpha returns s with ASCII lower-case letters converted to
+// upper case, as an Alpha encoding, and reports whether any letters
+// were converted.  Many payloads, such as the scheme and host of a URL,
+// are case-insensitive and fit in alphanumeric mode once upper-cased.
+// Other characters are left alone, so the result may still fail Check.
+func FoldAlpha(s string) (a Alpha, folded bool) {
+	b := []byte(s)
+	for i, c := range b {
+		if 'a' <= c && c <= 'z' {
+			b[i] = c - 'a' + 'A'
+			folded = true
+		}
+	}
+	return Alpha(b), folded
+}
+
 var alphaLen = [3]int{9, 11, 13}
 
 func (s Alpha) Bits(v Version) int {
@@ -178,7 +400,7 @@ func (s Alpha) Bits(v Version) int {
 }
 
 func (s Alpha) Encode(b *Bits, v Version) {
-	b.Write(2, 4)
+	b.Write(uint(ModeAlphanumeric), 4)
 	b.Write(uint(len(s)), alphaLen[v.sizeClass()])
 	var i int
 	for i = 0; i+2 <= len(s); i += 2 {
@@ -211,13 +433,256 @@ func (s String) Bits(v Version) int {
 }
 
 func (s String) Encode(b *Bits, v Version) {
-	b.Write(4, 4)
+	b.Write(uint(ModeByte), 4)
 	b.Write(uint(len(s)), stringLen[v.sizeClass()])
 	for i := 0; i < len(s); i++ {
 		b.Write(uint(s[i]), 8)
 	}
 }
 
+// Bytes is the encoding for 8-bit binary data.  All bytes are valid.
+// It is equivalent to String but avoids converting the data to a string.
+type Bytes []byte
+
+func (s Bytes) String() string {
+	return fmt.Sprintf("Bytes(%x)", []byte(s))
+}
+
+func (s Bytes) Check() error {
+	return nil
+}
+
+func (s Bytes) Bits(v Version) int {
+	return 4 + stringLen[v.sizeClass()] + 8*len(s)
+}
+
+func (s Bytes) Encode(b *Bits, v Version) {
+	b.Write(uint(ModeByte), 4)
+	b.Write(uint(len(s)), stringLen[v.sizeClass()])
+	for _, c := range s {
+		b.Write(uint(c), 8)
+	}
+}
+
+// A Reader is the encoding for N bytes of 8-bit data read from R.
+// The data is copied from R directly into the code bits as it is
+// encoded, so that large payloads need not be buffered first.
+//
+// A Reader can be encoded only once.  If R returns fewer than N
+// bytes, the rest of the segment is zero-filled and the error is
+// reported by Err.
+type Reader struct {
+	R   io.Reader
+	N   int
+	err error
+}
+
+func (r *Reader) String() string {
+	return fmt.Sprintf("Reader(%d)", r.N)
+}
+
+func (r *Reader) Check() error {
+	if r.N < 0 {
+		return fmt.Errorf("invalid reader length %d", r.N)
+	}
+	return nil
+}
+
+func (r *Reader) Bits(v Version) int {
+	return 4 + stringLen[v.sizeClass()] + 8*r.N
+}
+
+func (r *Reader) Encode(b *Bits, v Version) {
+	b.Write(uint(ModeByte), 4)
+	b.Write(uint(r.N), stringLen[v.sizeClass()])
+	var buf [512]byte
+	for n := r.N; n > 0; {
+		p := buf[:]
+		if n < len(p) {
+			p = p[:n]
+		}
+		m := 0
+		if r.err == nil {
+			m, r.err = io.ReadFull(r.R, p)
+		}
+		for i := m; i < len(p); i++ {
+			p[i] = 0
+		}
+		for _, c := range p {
+			b.Write(uint(c), 8)
+		}
+		n -= len(p)
+	}
+}
+
+// EncodeErr is like Encode but checks r first
+// and also returns Err.
+func (r *Reader) EncodeErr(b *Bits, v Version) error {
+	if err := r.Check(); err != nil {
+		return err
+	}
+	r.Encode(b, v)
+	return r.Err()
+}
+
+// Err returns the first error encountered reading from r.R, if any.
+func (r *Reader) Err() error {
+	if r.err == io.EOF {
+		return io.ErrUnexpectedEOF
+	}
+	return r.err
+}
+
+// Latin1 is the encoding for 8-bit data given as UTF-8 text.
+// The text is transcoded to ISO-8859-1, the character set that
+// readers assume for byte data when no ECI is present.
+// Valid characters are U+0000 through U+00FF.
+type Latin1 string
+
+func (s Latin1) String() string {
+	return fmt.Sprintf("Latin1(%#q)", string(s))
+}
+
+func (s Latin1) Check() error {
+	for i, c := range s {
+		if c > 0xff {
+			return &ErrInvalidChar{Rune: c, Pos: i, Mode: ModeByte}
+		}
+	}
+	return nil
+}
+
+func (s Latin1) Bits(v Version) int {
+	return 4 + stringLen[v.sizeClass()] + 8*utf8.RuneCountInString(string(s))
+}
+
+func (s Latin1) Encode(b *Bits, v Version) {
+	b.Write(uint(ModeByte), 4)
+	b.Write(uint(utf8.RuneCountInString(string(s))), stringLen[v.sizeClass()])
+	for _, c := range s {
+		b.Write(uint(c)&0xff, 8)
+	}
+}
+
+// ECI is an Extended Channel Interpretation header.
+// It sets the character set (or other interpretation) of the
+// segments that follow it to the assignment with the given
+// designator, from 0 to 999999.
+type ECI int
+
+func (e ECI) String() string {
+	return fmt.Sprintf("ECI(%d)", int(e))
+}
+
+func (e ECI) Check() error {
+	if e < 0 || 999999 < e {
+		return fmt.Errorf("invalid ECI designator %d", int(e))
+	}
+	return nil
+}
+
+func (e ECI) Bits(v Version) int {
+	switch {
+	case e < 1<<7:
+		return 4 + 8
+	case e < 1<<14:
+		return 4 + 16
+	}
+	return 4 + 24
+}
+
+func (e ECI) Encode(b *Bits, v Version) {
+	b.Write(uint(ModeECI), 4)
+	switch {
+	case e < 1<<7:
+		b.Write(uint(e), 8)
+	case e < 1<<14:
+		b.Write(2<<14|uint(e), 16)
+	default:
+		b.Write(6<<21|uint(e), 24)
+	}
+}
+
+// A StructuredAppend is the header of one code in a series of
+// up to 16 codes that together hold a single message.
+// Index is the position of the code in the series, starting at 0,
+// Total is the number of codes in the series, and Parity is the
+// parity of the entire message, as computed by Parity.
+// The header must be the first segment in each code.
+type StructuredAppend struct {
+	Index  int
+	Total  int
+	Parity byte
+}
+
+// MaxStructuredAppend is the maximum number of codes in a series.
+const MaxStructuredAppend = 16
+
+func (a StructuredAppend) String() string {
+	return fmt.Sprintf("StructuredAppend(%d/%d, %#02x)", a.Index, a.Total, a.Parity)
+}
+
+func (a StructuredAppend) Check() error {
+	if a.Total < 1 || a.Total > MaxStructuredAppend || a.Index < 0 || a.Index >= a.Total {
+		return fmt.Errorf("invalid structured append position %d of %d", a.Index, a.Total)
+	}
+	return nil
+}
+
+func (a StructuredAppend) Bits(v Version) int {
+	return 4 + 4 + 4 + 8
+}
+
+func (a StructuredAppend) Encode(b *Bits, v Version) {
+	b.Write(uint(ModeStructuredAppend), 4)
+	b.Write(uint(a.Index), 4)
+	b.Write(uint(a.Total-1), 4)
+	b.Write(uint(a.Parity), 8)
+}
+
+// Parity returns the structured append parity of a message:
+// the exclusive or of all its bytes.
+func Parity(data []byte) byte {
+	var p byte
+	for _, c := range data {
+		p ^= c
+	}
+	return p
+}
+
+// eciUTF8 is the ECI designator for UTF-8.
+const eciUTF8 = 26
+
+// UTF8 is the encoding for UTF-8 text.
+// It is encoded as an ECI header selecting UTF-8
+// followed by 8-bit data, so that readers need not guess
+// the character set.  Valid strings are those that are valid UTF-8.
+type UTF8 string
+
+func (s UTF8) String() string {
+	return fmt.Sprintf("UTF8(%#q)", string(s))
+}
+
+func (s UTF8) Check() error {
+	for i, c := range s {
+		if c == utf8.RuneError {
+			if _, n := utf8.DecodeRuneInString(string(s[i:])); n == 1 {
+				return &ErrInvalidChar{Rune: c, Pos: i, Mode: ModeByte}
+			}
+		}
+	}
+	return nil
+}
+
+func (s UTF8) Bits(v Version) int {
+	return ECI(eciUTF8).Bits(v) + String(s).Bits(v)
+}
+
+func (s UTF8) Encode(b *Bits, v Version) {
+	ECI(eciUTF8).Encode(b, v)
+	String(s).Encode(b, v)
+}
+
 // Kanji is the encoding for kanji.
 // Valid characters are those in JIS X 0208.
 type Kanji string
@@ -226,12 +691,35 @@ func (s Kanji) String() string {
 	return fmt.Sprintf("Kanji(%#q)", string(s))
 }
 
-func (s Kanji) Check() error {
-	// XXX not the best way to achieve this
-	_, err := japanese.ShiftJIS.NewEncoder().String(string(s))
-	if err != nil {
-		err = fmt.Errorf("non-kanji string %#q", string(s))
+// shiftJIS converts s to Shift JIS and checks that every character
+// is a double-byte character that Kanji mode can represent.
+func shiftJIS(s string) (string, error) {
+	k, ok := toShiftJIS(s)
+	if !ok {
+		return "", invalidChar(s, ModeKanji, func(c string) bool {
+			_, ok := toShiftJIS(c)
+			return ok
+		})
 	}
+	return k, nil
+}
+
+func toShiftJIS(s string) (string, bool) {
+	k, err := japanese.ShiftJIS.NewEncoder().String(s)
+	if err != nil || len(k)&1 != 0 {
+		return "", false
+	}
+	for i := 0; i < len(k); i += 2 {
+		c0, c1 := k[i], k[i+1]
+		if c1 < 0x40 || 0xfc < c1 || c1 == 0x7f || !(0x81 <= c0 && c0 <= 0x9f || 0xe0 <= c0 && c0 <= 0xeb) {
+			return "", false
+		}
+	}
+	return k, true
+}
+
+func (s Kanji) Check() error {
+	_, err := shiftJIS(string(s))
 	return err
 }
 
@@ -246,17 +734,158 @@ func (s Kanji) Bits(v Version) int {
 }
 
 func (s Kanji) Encode(b *Bits, v Version) {
-	k, err := japanese.ShiftJIS.NewEncoder().String(string(s))
-	if err != nil || len(k)&1 != 0 {
-		println("fail!", string(k), err.Error())
-		return
+	s.EncodeErr(b, v)
+}
+
+// EncodeErr is like Encode but reports invalid text
+// instead of writing nothing.  It converts s to Shift JIS once,
+// checking and encoding the result in a single pass.
+func (s Kanji) EncodeErr(b *Bits, v Version) error {
+	k, err := shiftJIS(string(s))
+	if err != nil {
+		return err
 	}
-	b.Write(8, 4)
+	b.Write(uint(ModeKanji), 4)
 	b.Write(uint(len(k)/2), kanjiLen[v.sizeClass()])
 	for i := 0; i < len(k); i += 2 {
 		w := uint(k[i]&^0xc0)*0xc0 + uint(k[i+1]) - 0x100
 		b.Write(w, 13)
 	}
+	return nil
+}
+
+// Hanzi is the encoding for simplified Chinese characters,
+// as defined by GB/T 18284.
+// Valid characters are those in the GB 2312 double-byte set.
+type Hanzi string
+
+func (s Hanzi) String() string {
+	return fmt.Sprintf("Hanzi(%#q)", string(s))
+}
+
+// gb2312 converts s to GB 2312 and checks that every character
+// falls in one of the two ranges that Hanzi mode can represent.
+func gb2312(s string) (string, error) {
+	k, ok := toGB2312(s)
+	if !ok {
+		return "", invalidChar(s, ModeHanzi, func(c string) bool {
+			_, ok := toGB2312(c)
+			return ok
+		})
+	}
+	return k, nil
+}
+
+func toGB2312(s string) (string, bool) {
+	k, err := simplifiedchinese.GBK.NewEncoder().String(s)
+	if err != nil || len(k)&1 != 0 {
+		return "", false
+	}
+	for i := 0; i < len(k); i += 2 {
+		c0, c1 := k[i], k[i+1]
+		if c1 < 0xa1 || 0xfe < c1 || !(0xa1 <= c0 && c0 <= 0xaa || 0xb0 <= c0 && c0 <= 0xfa) {
+			return "", false
+		}
+	}
+	return k, true
+}
+
+func (s Hanzi) Check() error {
+	_, err := gb2312(string(s))
+	return err
+}
+
+func (s Hanzi) Bits(v Version) int {
+	n := 4 + 4 + kanjiLen[v.sizeClass()]
+	for range s {
+		n += 13
+	}
+	return n
+}
+
+func (s Hanzi) Encode(b *Bits, v Version) {
+	s.EncodeErr(b, v)
+}
+
+// EncodeErr is like Encode but reports invalid text
+// instead of writing nothing.
+func (s Hanzi) EncodeErr(b *Bits, v Version) error {
+	k, err := gb2312(string(s))
+	if err != nil {
+		return err
+	}
+	b.Write(uint(ModeHanzi), 4)
+	b.Write(1, 4) // subset: GB 2312
+	b.Write(uint(len(k)/2), kanjiLen[v.sizeClass()])
+	for i := 0; i < len(k); i += 2 {
+		c := uint(k[i])<<8 | uint(k[i+1])
+		if c < 0xb0a1 {
+			c -= 0xa1a1
+		} else {
+			c -= 0xa6a1
+		}
+		w := c>>8*0x60 + c&0xff
+		b.Write(w, 13)
+	}
+	return nil
+}
+
+// A Raw is a segment with an arbitrary mode indicator and payload,
+// for experimenting with reserved modes and vendor extensions.
+// It is written as the 4-bit Mode, then Count in a CountBits-bit
+// field (omitted if CountBits is 0), then the first DataBits bits
+// of Data, most significant bit first.  If DataBits is 0, all of
+// Data is written.
+//
+// Raw does not check that the segment is meaningful to readers.
+type Raw struct {
+	Mode      uint
+	CountBits int
+	Count     uint
+	Data      []byte
+	DataBits  int
+}
+
+func (r Raw) String() string {
+	return fmt.Sprintf("Raw(%d, %d, %x)", r.Mode, r.Count, r.Data)
+}
+
+func (r Raw) dataBits() int {
+	if r.DataBits == 0 {
+		return 8 * len(r.Data)
+	}
+	return r.DataBits
+}
+
+func (r Raw) Check() error {
+	if r.Mode > 15 {
+		return fmt.Errorf("invalid mode indicator %d", r.Mode)
+	}
+	if r.CountBits < 0 || r.CountBits > 16 || r.Count>>uint(r.CountBits) != 0 {
+		return fmt.Errorf("invalid count %d in %d bits", r.Count, r.CountBits)
+	}
+	if r.DataBits < 0 || r.DataBits > 8*len(r.Data) {
+		return fmt.Errorf("invalid data length %d bits for %d bytes", r.DataBits, len(r.Data))
+	}
+	return nil
+}
+
+func (r Raw) Bits(v Version) int {
+	return 4 + r.CountBits + r.dataBits()
+}
+
+func (r Raw) Encode(b *Bits, v Version) {
+	b.Write(r.Mode, 4)
+	b.Write(r.Count, r.CountBits)
+	n := r.dataBits()
+	for i := 0; n > 0; i++ {
+		if n < 8 {
+			b.Write(uint(r.Data[i])>>uint(8-n), n)
+			break
+		}
+		b.Write(uint(r.Data[i]), 8)
+		n -= 8
+	}
 }
 
 // A Pixel describes a single pixel in a QR code.
@@ -343,6 +972,31 @@ type Code struct {
 	Bitmap []byte // 1 is black, 0 is white
 	Size   int    // number of pixels on a side
 	Stride int    // number of bytes per row
+
+	// Info describes how the code was encoded.
+	// It is nil for codes not returned by an Encode method.
+	Info *Info
+}
+
+// Info describes the choices made in encoding a QR code,
+// for debugging and for reporting.
+type Info struct {
+	Version  Version
+	Level    Level
+	Mask     Mask       // mask applied to the code
+	Penalty  int        // penalty score of the code; see Code.Penalty
+	Segments []Encoding // encoded segments, in order
+	DataBits int        // bits of encoded data, before padding
+	PadBytes int        // number of pad codewords following the data
+
+	// Corrected is the number of bytes corrected in each error
+	// correction block, for an Info returned by Decode.
+	// A block can correct errors in up to half its check bytes.
+	Corrected []int
+
+	// FormatErrors is the number of format information bits
+	// corrected, up to 3, for an Info returned by Decode.
+	FormatErrors int
 }
 
 func (c *Code) Black(x, y int) bool {
@@ -350,6 +1004,142 @@ func (c *Code) Black(x, y int) bool {
 		c.Bitmap[y*c.Stride+x/8]&(1<<uint(7-x&7)) != 0
 }
 
+// Clone returns a copy of c that does not share its bitmap or Info.
+func (c *Code) Clone() *Code {
+	d := *c
+	d.Bitmap = append([]byte(nil), c.Bitmap...)
+	d.Info = c.Info.Clone()
+	return &d
+}
+
+// Clone returns a copy of i that does not share its slices,
+// including the data of Bytes and Raw segments.
+// If i is nil, Clone returns nil.
+func (i *Info) Clone() *Info {
+	if i == nil {
+		return nil
+	}
+	info := *i
+	if info.Segments != nil {
+		info.Segments = append([]Encoding(nil), info.Segments...)
+		for j, seg := range info.Segments {
+			switch seg := seg.(type) {
+			case Bytes:
+				info.Segments[j] = append(Bytes(nil), seg...)
+			case Raw:
+				seg.Data = append([]byte(nil), seg.Data...)
+				info.Segments[j] = seg
+			}
+		}
+	}
+	if info.Corrected != nil {
+		info.Corrected = append([]int(nil), info.Corrected...)
+	}
+	return &info
+}
+
+// Equal reports whether c and d have the same size and pixels.
+// Info is ignored.
+// The padding bits at the end of each row and the strides
+// themselves are ignored.
+func (c *Code) Equal(d *Code) bool {
+	if c.Size != d.Size {
+		return false
+	}
+	n := c.Size / 8                        // full bytes per row
+	mask := byte(0xff) << uint(8-c.Size%8) // pixels in last partial byte
+	for y := 0; y < c.Size; y++ {
+		cr := c.Bitmap[y*c.Stride:]
+		dr := d.Bitmap[y*d.Stride:]
+		for i := 0; i < n; i++ {
+			if cr[i] != dr[i] {
+				return false
+			}
+		}
+		if c.Size%8 != 0 && (cr[n]^dr[n])&mask != 0 {
+			return false
+		}
+	}
+	return true
+}
+
+// String returns a compact drawing of c for debugging, with a 4-pixel
+// quiet zone.  Each character shows two pixels stacked vertically,
+// using Unicode half blocks for black pixels.
+func (c *Code) String() string {
+	const quiet = 4
+	d := c.Size + 2*quiet
+	var b strings.Builder
+	for y := 0; y < d; y += 2 {
+		for x := 0; x < d; x++ {
+			top := c.Black(x-quiet, y-quiet)
+			bot := c.Black(x-quiet, y+1-quiet)
+			switch {
+			case top && bot:
+				b.WriteString("█")
+			case top:
+				b.WriteString("▀")
+			case bot:
+				b.WriteString("▄")
+			default:
+				b.WriteString(" ")
+			}
+		}
+		b.WriteString("\n")
+	}
+	return b.String()
+}
+
+// GoString returns a description of c followed by a drawing of it,
+// so that %#v shows the code in test failures.
+func (c *Code) GoString() string {
+	s := fmt.Sprintf("coding.Code{Size: %d, Stride: %d", c.Size, c.Stride)
+	if c.Info != nil {
+		s += fmt.Sprintf(", Version: %d, Level: %v, Mask: %d", int(c.Info.Version), c.Info.Level, int(c.Info.Mask))
+	}
+	return s + "}\n" + c.String()
+}
+
+// Matrix returns the pixels of c as a matrix indexed by [y][x],
+// with true for black.
+func (c *Code) Matrix() [][]bool {
+	m := make([][]bool, c.Size)
+	pix := make([]bool, c.Size*c.Size)
+	for y := range m {
+		m[y], pix = pix[:c.Size], pix[c.Size:]
+		for x := range m[y] {
+			m[y][x] = c.Black(x, y)
+		}
+	}
+	return m
+}
+
+// NewCodeFromMatrix returns a Code with the pixels of m,
+// which is indexed by [y][x] and uses true for black.
+// The matrix must be square and not empty.
+// The returned Code has a nil Info.
+func NewCodeFromMatrix(m [][]bool) (*Code, error) {
+	siz := len(m)
+	if siz == 0 {
+		return nil, fmt.Errorf("empty QR matrix")
+	}
+	for y, row := range m {
+		if len(row) != siz {
+			return nil, fmt.Errorf("QR matrix row %d has %d pixels, want %d", y, len(row), siz)
+		}
+	}
+	c := &Code{Size: siz, Stride: (siz + 7) / 8}
+	c.Bitmap = make([]byte, c.Stride*siz)
+	for y, row := range m {
+		for x, black := range row {
+			if black {
+				c.set(c.Bitmap, y, x)
+			}
+		}
+	}
+	return c, nil
+}
+
 func (c *Code) set(b []byte, y, x int) {
 	b[y*c.Stride+x/8] |= 1 << (7 - x&7)
 }
@@ -522,23 +1312,27 @@ type Plan struct {
 
 	Pixel [][]Pixel // pixel map
 	Code  Code      // 1 is black/inverted
+
+	Padding Padding // padding used by Encode
 }
 
 // NewPlan returns a Plan for a QR code with the given
 // version, level, and mask.
-// If mask is -1, the Encode method will choose the best mask for the code.
+// If mask is -1, the Encode method will choose the best mask for the code:
+// the one with the smallest Penalty, or if several masks share the
+// smallest penalty, the lowest-numbered of those.
 func NewPlan(version Version, level Level, mask Mask) (*Plan, error) {
 	if version < MinVersion || version > MaxVersion {
-		return nil, fmt.Errorf("invalid QR version %d", int(version))
+		return nil, fmt.Errorf("%w %d", ErrBadVersion, int(version))
 	}
 	if level < L || level > H {
-		return nil, fmt.Errorf("invalid QR level %d", int(level))
+		return nil, fmt.Errorf("%w %d", ErrBadLevel, int(level))
 	}
 	n := 1
 	if mask == -1 {
 		n = 8
 	} else if mask < 0 || 7 < mask {
-		return nil, fmt.Errorf("invalid QR mask %d", int(mask))
+		return nil, fmt.Errorf("%w %d", ErrBadMask, int(mask))
 	}
 	p, err := vplan(version, n)
 	if err != nil {
@@ -569,38 +1363,11 @@ type AutoPlan struct {
 	Level   Level   // Error correction level
 }
 
-const (
-	versions = MaxVersion - MinVersion + 1
-	levels   = H - L + 1
-)
-
-type autoPlan struct {
-	once sync.Once
-	p    *Plan
-}
-
-var autoPlans [versions][levels]autoPlan
-
-func makeAutoPlan(version Version, level Level) (*Plan, error) {
-	if version < MinVersion || version > MaxVersion {
-		return nil, fmt.Errorf("invalid QR version %d", int(version))
-	}
-	if level < L || level > H {
-		return nil, fmt.Errorf("invalid QR level %d", int(level))
-	}
-	p := &autoPlans[version-MinVersion][level]
-	if p.p == nil {
-		p.once.Do(func() {
-			p.p, _ = NewPlan(version, level, -1)
-		})
-	}
-	return p.p, nil
-}
-
 // NewAutoPlan returns an AutoPlan for a QR code with the given
 // version and level.  Its Encode method is functionally equivalent
 // to that of a Plan returned by NewPlan(version, level, -1),
-// except the Plan is permanently allocated.
+// except the Plan is kept in the plan cache for reuse.
+// See PreloadPlans and SetPlanCacheLimit.
 func NewAutoPlan(version Version, level Level) (AutoPlan, error) {
 	if _, err := makeAutoPlan(version, level); err != nil {
 		return AutoPlan{}, err
@@ -608,29 +1375,69 @@ func NewAutoPlan(version Version, level Level) (AutoPlan, error) {
 	return AutoPlan{version, level}, nil
 }
 
+// Pad appends n bits of padding to b, as selected by b.Padding.
 func (b *Bits) Pad(n int) {
 	if n < 0 {
 		panic("qr: invalid pad size")
 	}
-	if n <= 4 {
-		b.Write(0, n)
-	} else {
+	b.npad = 0
+	if b.Padding&PadNoTerminator == 0 {
+		if n <= 4 {
+			b.Write(0, n)
+			return
+		}
 		b.Write(0, 4)
 		n -= 4
-		n -= -b.Bits() & 7
-		b.Write(0, -b.Bits()&7)
-		pad := n / 8
-		for i := 0; i < pad; i += 2 {
-			b.Write(0xec, 8)
-			if i+1 >= pad {
-				break
-			}
-			b.Write(0x11, 8)
+	}
+	z := -b.Bits() & 7
+	if z > n {
+		z = n
+	}
+	b.Write(0, z)
+	n -= z
+	pad := n / 8
+	for i := 0; i < pad; i++ {
+		c := uint(0xec)
+		if i&1 != 0 {
+			c = 0x11
+		}
+		if b.Padding&PadZeroBytes != 0 {
+			c = 0
 		}
+		b.Write(c, 8)
 	}
+	b.npad = pad
+}
+
+// PadCodewords returns the number of pad codewords
+// written by the last call to Pad.
+func (b *Bits) PadCodewords() int {
+	return b.npad
+}
+
+// rsPool holds Reed-Solomon encoders for reuse, indexed by
+// number of check bytes.  An RSEncoder is not safe for concurrent
+// use, but building one is much more expensive than using it.
+var rsPool [31]sync.Pool
+
+func getRS(check int) *gf256.RSEncoder {
+	if rs, ok := rsPool[check].Get().(*gf256.RSEncoder); ok {
+		return rs
+	}
+	return gf256.NewRSEncoder(Field, check)
+}
+
+func putRS(check int, rs *gf256.RSEncoder) {
+	rsPool[check].Put(rs)
 }
 
 func (b *Bits) AddCheckBytes(v Version, l Level) {
+	b.addCheckBytes(context.Background(), v, l)
+}
+
+// addCheckBytes is like AddCheckBytes but stops early,
+// returning ctx.Err(), if ctx is done between blocks.
+func (b *Bits) addCheckBytes(ctx context.Context, v Version, l Level) error {
 	nd := v.DataBytes(l)
 	if b.nbit < nd*8 {
 		b.Pad(nd*8 - b.nbit)
@@ -645,8 +1452,12 @@ func (b *Bits) AddCheckBytes(v Version, l Level) {
 	db := nd / lev.nblock
 	extra := nd % lev.nblock
 	chk := make([]byte, lev.check)
-	rs := gf256.NewRSEncoder(Field, lev.check)
+	rs := getRS(lev.check)
+	defer putRS(lev.check, rs)
 	for i := 0; i < lev.nblock; i++ {
+		if err := ctx.Err(); err != nil {
+			return err
+		}
 		if i == lev.nblock-extra {
 			db++
 		}
@@ -658,28 +1469,96 @@ func (b *Bits) AddCheckBytes(v Version, l Level) {
 	if len(b.Bytes()) != vt.bytes {
 		panic("qr: internal error")
 	}
+	return nil
 }
 
 func (p *Plan) Encode(text ...Encoding) (*Code, error) {
-	var b Bits
+	return p.EncodeContext(context.Background(), text...)
+}
+
+// EncodeContext is like Encode but stops early, returning ctx.Err(),
+// if ctx is done before the code is complete.  It checks ctx between
+// the error correction blocks and between the evaluation of masks.
+func (p *Plan) EncodeContext(ctx context.Context, text ...Encoding) (*Code, error) {
+	c := new(Code)
+	if err := p.EncodeToContext(ctx, c, text...); err != nil {
+		return nil, err
+	}
+	return c, nil
+}
+
+// EncodeTo is like Encode but stores the code in dst, reusing
+// dst.Bitmap if it has enough capacity.  The scratch space used
+// to choose the best mask is also reused across calls.
+func (p *Plan) EncodeTo(dst *Code, text ...Encoding) error {
+	return p.EncodeToContext(context.Background(), dst, text...)
+}
+
+// EncodeToContext is like EncodeTo but stops early if ctx is done.
+// See EncodeContext.
+func (p *Plan) EncodeToContext(ctx context.Context, dst *Code, text ...Encoding) error {
+	b := Bits{Padding: p.Padding}
 	for _, t := range text {
+		if r, ok := t.(*Reader); ok {
+			// Check the length before reading any of the data.
+			if err := r.Check(); err != nil {
+				return err
+			}
+			if n := b.Bits() + r.Bits(p.Version); n > p.DataBytes*8 {
+				err := &ErrDataTooLong{Bits: n, Capacity: p.DataBytes * 8}
+				err.MinVersion, err.Level = fit(p.Level, text)
+				return err
+			}
+		}
+		if t, ok := t.(ErrEncoding); ok {
+			if err := t.EncodeErr(&b, p.Version); err != nil {
+				return err
+			}
+			continue
+		}
 		if err := t.Check(); err != nil {
-			return nil, err
+			return err
 		}
 		t.Encode(&b, p.Version)
 	}
 	if b.Bits() > p.DataBytes*8 {
-		return nil, fmt.Errorf("cannot encode %d bits into %d-bit code",
-			b.Bits(), p.DataBytes*8)
+		err := &ErrDataTooLong{Bits: b.Bits(), Capacity: p.DataBytes * 8}
+		err.MinVersion, err.Level = fit(p.Level, text)
+		return err
+	}
+	info := &Info{
+		Version:  p.Version,
+		Level:    p.Level,
+		Mask:     p.Mask,
+		Segments: append([]Encoding(nil), text...),
+		DataBits: b.Bits(),
+	}
+	if err := b.addCheckBytes(ctx, p.Version, p.Level); err != nil {
+		return err
 	}
-	b.AddCheckBytes(p.Version, p.Level)
+	info.PadBytes = b.PadCodewords()
 	bytes := b.Bytes()
 
+	n := p.Code.Size * p.Code.Stride
+	bitmap := dst.Bitmap[:0]
+	if cap(bitmap) < n {
+		bitmap = make([]byte, n)
+	}
+	bitmap = bitmap[:n]
+
 	// Now we have the checksum bytes and the data bytes.
 	// Construct the bitmap consisting of data and checksum bits.
-	data := make([]byte, p.Code.Size*p.Code.Stride)
-	if len(data) == len(p.Code.Bitmap) {
+	var data []byte
+	if n == len(p.Code.Bitmap) {
+		data = bitmap
 		copy(data, p.Code.Bitmap) // one mask: copy the bitmap
+	} else {
+		buf := getBuf(n)
+		defer putBuf(buf)
+		data = *buf
+		for i := range data {
+			data[i] = 0
+		}
 	}
 	crow := data
 	for _, row := range p.Pixel {
@@ -695,38 +1574,212 @@ func (p *Plan) Encode(text ...Encoding) (*Code, error) {
 		crow = crow[p.Code.Stride:]
 	}
 
-	c := &Code{Size: p.Code.Size, Stride: p.Code.Stride}
-	if len(data) == len(p.Code.Bitmap) {
-		c.Bitmap = data // one mask: done
-	} else {
+	if n != len(p.Code.Bitmap) {
 		// Apply masks to the bitmap to construct the actual codes.
 		// Choose the code with the smallest penalty.
-		c.Bitmap = make([]byte, len(data))
-		best := make([]byte, len(data)) // best bitmap so far
-		pen := 2 << 30                  // largest penalty is < 2<<23
-		for b := p.Code.Bitmap; len(b) != 0; {
+		buf := getBuf(n)
+		defer putBuf(buf)
+		c := &Code{Bitmap: *buf, Size: p.Code.Size, Stride: p.Code.Stride}
+		info.Penalty = 2 << 30 // largest penalty is < 2<<23
+		for m, b := Mask(0), p.Code.Bitmap; len(b) != 0; m++ {
+			if err := ctx.Err(); err != nil {
+				return err
+			}
 			// set bitmap to plan bits xor data bits
 			b = b[copy(c.Bitmap, b):]
 			for i, v := range data {
 				c.Bitmap[i] ^= v
 			}
-			if p := c.Penalty(); p < pen {
-				best, pen, c.Bitmap = c.Bitmap, p, best
+			// Strict comparison: ties go to the lowest mask.
+			if p := c.Penalty(); p < info.Penalty {
+				info.Penalty, info.Mask = p, m
+				copy(bitmap, c.Bitmap)
 			}
 		}
-		c.Bitmap = best
 	}
-	return c, nil
+	*dst = Code{Bitmap: bitmap, Size: p.Code.Size, Stride: p.Code.Stride, Info: info}
+	if n == len(p.Code.Bitmap) {
+		info.Penalty = dst.Penalty()
+	}
+	return nil
+}
+
+// bufPool holds scratch bitmaps for Plan.EncodeToContext.
+var bufPool sync.Pool
+
+func getBuf(n int) *[]byte {
+	buf, _ := bufPool.Get().(*[]byte)
+	if buf == nil || cap(*buf) < n {
+		b := make([]byte, n)
+		buf = &b
+	}
+	*buf = (*buf)[:n]
+	return buf
+}
+
+func putBuf(buf *[]byte) {
+	bufPool.Put(buf)
+}
+
+// dataBits returns the number of bits needed to encode text at version v,
+// not counting the terminator.
+func dataBits(v Version, text []Encoding) int {
+	n := 0
+	for _, t := range text {
+		n += t.Bits(v)
+	}
+	return n
+}
+
+// TotalBits returns the number of bits used by segs in a code of
+// version v, including the 4-bit terminator and the zero bits that
+// pad the data to a whole number of bytes.
+//
+// The terminator is shortened or omitted when the code has no room
+// for it, so segs fit in a code with version v and level l if
+// TotalBits(v, segs...) <= v.DataBytes(l)*8, and also in the
+// rare case that they fill all but the last few bits of the code.
+func TotalBits(v Version, segs ...Encoding) int {
+	n := dataBits(v, segs) + 4
+	return n + -n&7
+}
+
+// Errors reporting invalid parameters.
+// Functions return them wrapped with the offending value,
+// so callers should test for them using errors.Is.
+var (
+	ErrBadVersion = errors.New("invalid QR version")
+	ErrBadLevel   = errors.New("invalid QR level")
+	ErrBadMask    = errors.New("invalid QR mask")
+)
+
+// An ErrDataTooLong reports that the encoded text is too long for a code.
+type ErrDataTooLong struct {
+	Bits     int // number of bits needed for the text
+	Capacity int // number of data bits in the code
+
+	// MinVersion and Level give the smallest version that can hold
+	// the text, at the requested level if possible or else at the
+	// highest level that can.  MinVersion is 0 if the text does not
+	// fit in any code.
+	MinVersion Version
+	Level      Level
+}
+
+func (e *ErrDataTooLong) Error() string {
+	s := fmt.Sprintf("cannot encode %d bits into %d-bit code", e.Bits, e.Capacity)
+	if e.MinVersion != 0 {
+		s += fmt.Sprintf(" (fits version %v level %v)", e.MinVersion, e.Level)
+	}
+	return s
+}
+
+// An ErrInvalidChar reports a character that cannot be encoded
+// in the given mode.  For invalid UTF-8, Rune is utf8.RuneError.
+type ErrInvalidChar struct {
+	Rune rune // invalid character
+	Pos  int  // byte offset of Rune in the text
+	Mode Mode // mode of the encoding
+}
+
+func (e *ErrInvalidChar) Error() string {
+	if e.Rune == utf8.RuneError {
+		return fmt.Sprintf("invalid UTF-8 in %v text at offset %d", e.Mode, e.Pos)
+	}
+	return fmt.Sprintf("invalid %v character %q at offset %d", e.Mode, e.Rune, e.Pos)
+}
+
+// invalidChar returns an *ErrInvalidChar for the first character of s
+// that ok rejects.  It is used only after s has been found invalid,
+// to report where.
+func invalidChar(s string, m Mode, ok func(c string) bool) error {
+	for i, r := range s {
+		if !ok(string(r)) {
+			return &ErrInvalidChar{Rune: r, Pos: i, Mode: m}
+		}
+	}
+	return &ErrInvalidChar{Rune: utf8.RuneError, Mode: m}
+}
+
+// fit returns the smallest version that can hold text at level l.
+// If there is none, it tries each lower level in turn.
+// It returns version 0 if text does not fit in any code.
+func fit(l Level, text []Encoding) (Version, Level) {
+	for ; l >= L; l-- {
+		if v := smallest(l, text); v != 0 {
+			return v, l
+		}
+	}
+	return 0, L
+}
+
+// smallest returns the smallest version that can hold text at level l,
+// or 0 if there is none.
+func smallest(l Level, text []Encoding) Version {
+	for v := Version(MinVersion); v <= MaxVersion; v++ {
+		if dataBits(v, text) <= v.DataBytes(l)*8 {
+			return v
+		}
+	}
+	return 0
+}
+
+// SmallestVersion returns the smallest version that can hold
+// text at level l.  If there is none, it returns an *ErrDataTooLong
+// describing the largest version.
+func SmallestVersion(l Level, text ...Encoding) (Version, error) {
+	if l < L || l > H {
+		return 0, fmt.Errorf("%w %d", ErrBadLevel, int(l))
+	}
+	if v := smallest(l, text); v != 0 {
+		return v, nil
+	}
+	const v = MaxVersion
+	err := &ErrDataTooLong{Bits: dataBits(v, text), Capacity: Version(v).DataBytes(l) * 8}
+	err.MinVersion, err.Level = fit(l, text)
+	return 0, err
 }
 
 // Encode encodes text using p with 8 masks, returning the QR
 // code with the smallest penalty.
 func (a AutoPlan) Encode(text ...Encoding) (*Code, error) {
+	return a.EncodeContext(context.Background(), text...)
+}
+
+// EncodeContext is like Encode but stops early if ctx is done.
+// See Plan.EncodeContext.
+func (a AutoPlan) EncodeContext(ctx context.Context, text ...Encoding) (*Code, error) {
+	p, err := makeAutoPlan(a.Version, a.Level)
+	if err != nil {
+		return nil, err
+	}
+	return p.EncodeContext(ctx, text...)
+}
+
+// EncodeTo is like Encode but stores the code in dst.
+// See Plan.EncodeTo.
+func (a AutoPlan) EncodeTo(dst *Code, text ...Encoding) error {
+	return a.EncodeToContext(context.Background(), dst, text...)
+}
+
+// EncodeToContext is like EncodeTo but stops early if ctx is done.
+// See Plan.EncodeContext.
+func (a AutoPlan) EncodeToContext(ctx context.Context, dst *Code, text ...Encoding) error {
 	p, err := makeAutoPlan(a.Version, a.Level)
+	if err != nil {
+		return err
+	}
+	return p.EncodeToContext(ctx, dst, text...)
+}
+
+// EncodeSmallest encodes text using an AutoPlan with the given level
+// and the smallest version that can hold the text.
+func EncodeSmallest(level Level, text ...Encoding) (*Code, error) {
+	v, err := SmallestVersion(level, text...)
 	if err != nil {
 		return nil, err
 	}
-	return p.Encode(text...)
+	return Encode(v, level, text...)
 }
 
 // Encode encodes text using an AutoPlan with the given version and level.
@@ -734,6 +1787,12 @@ func Encode(version Version, level Level, text ...Encoding) (*Code, error) {
 	return AutoPlan{version, level}.Encode(text...)
 }
 
+// EncodeContext is like Encode but stops early if ctx is done.
+// See Plan.EncodeContext.
+func EncodeContext(ctx context.Context, version Version, level Level, text ...Encoding) (*Code, error) {
+	return AutoPlan{version, level}.EncodeContext(ctx, text...)
+}
+
 // A version describes metadata associated with a version.
 type version struct {
 	apos    int
@@ -805,7 +1864,7 @@ func grid(siz int) [][]Pixel {
 func vplan(v Version, n int) (*Plan, error) {
 	p := &Plan{Version: v}
 	if v < 1 || v > 40 {
-		return nil, fmt.Errorf("invalid QR version %d", int(v))
+		return nil, fmt.Errorf("%w %d", ErrBadVersion, int(v))
 	}
 	siz := 17 + int(v)*4
 	m := grid(siz)
@@ -903,17 +1962,7 @@ func vplan(v Version, n int) (*Plan, error) {
 // fplan sets the format bits
 func fplan(l Level, m Mask, p *Plan, b []byte) error {
 	// Format pixels.
-	fb := uint32(l^1) << 13 // level: L=01, M=00, Q=11, H=10
-	fb |= uint32(m) << 10   // mask
-	const formatPoly = 0x537
-	rem := fb
-	for i := 14; i >= 10; i-- {
-		if rem&(1<<uint(i)) != 0 {
-			rem ^= formatPoly << uint(i-10)
-		}
-	}
-	fb |= rem
-	fb ^= uint32(0x5412)
+	fb := formatBits(l, m)
 	siz := len(p.Pixel)
 	for i := 0; i < 15; i++ {
 		if (fb>>i)&1 == 1 {
@@ -0,0 +1,101 @@
+// Copyright 2011 The Go Authors.  All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package coding
+
+import "strings"
+
+// Split splits text into segments for a code of version v.
+// It starts from a single 8-bit segment and moves each run of
+// digits or alphanumeric characters into its own Num or Alpha
+// segment if doing so makes the encoding shorter, taking the
+// extra segment headers into account.
+//
+// Split runs in linear time and handles the common case of a
+// long number or identifier embedded in other text, such as a URL.
+// The result is not always optimal: package qr's Encode finds
+// the shortest segmentation.
+func Split(text string, v Version) []Encoding {
+	var segs []Encoding
+	start := 0 // start of pending 8-bit data
+	for i := 0; i < len(text); {
+		nd := runLen(text[i:], isDigit)
+		na := runLen(text[i:], isAlpha)
+		before, after := i > start, i+nd < len(text)
+		sd := runSaving(Num(text[i:i+nd]), nd, before, after, v)
+		after = i+na < len(text)
+		sa := runSaving(Alpha(text[i:i+na]), na, before, after, v)
+		var e Encoding
+		n := 0
+		switch {
+		case sd > 0 && sd >= sa:
+			e, n = Num(text[i:i+nd]), nd
+		case sa > 0 && sa >= digitSaving(text, i, na, v):
+			e, n = Alpha(text[i:i+na]), na
+		default:
+			i++
+			continue
+		}
+		if before {
+			segs = append(segs, String(text[start:i]))
+		}
+		segs = append(segs, e)
+		i += n
+		start = i
+	}
+	if start < len(text) || len(segs) == 0 {
+		segs = append(segs, String(text[start:]))
+	}
+	return segs
+}
+
+// runSaving returns the number of bits saved by encoding the n bytes
+// of the run as e instead of including them in 8-bit data.
+// Before and after report whether there is text on either side of it.
+func runSaving(e Encoding, n int, before, after bool, v Version) int {
+	if n == 0 {
+		return 0
+	}
+	header := 4 + stringLen[v.sizeClass()]
+	keep := 8 * n
+	if !before && !after {
+		keep += header
+	}
+	split := e.Bits(v)
+	if before && after {
+		split += header
+	}
+	return keep - split
+}
+
+// digitSaving returns the number of bits saved by moving the
+// first run of digits in the alphanumeric run text[i:i+n]
+// into a Num segment.
+func digitSaving(text string, i, n int, v Version) int {
+	j := i
+	for j < i+n && !isDigit(text[j]) {
+		j++
+	}
+	if j == i || j == i+n {
+		return 0
+	}
+	nd := runLen(text[j:], isDigit)
+	return runSaving(Num(text[j:j+nd]), nd, true, j+nd < len(text), v)
+}
+
+func runLen(s string, f func(byte) bool) int {
+	n := 0
+	for n < len(s) && f(s[n]) {
+		n++
+	}
+	return n
+}
+
+func isDigit(c byte) bool {
+	return '0' <= c && c <= '9'
+}
+
+func isAlpha(c byte) bool {
+	return strings.IndexByte(alphabet, c) >= 0
+}
@@ -0,0 +1,84 @@
+// Copyright 2011 The Go Authors.  All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package qr
+
+// Color contrast checks for QR codes.
+
+import (
+	"errors"
+	"fmt"
+	"image/color"
+)
+
+// ErrLowContrast is returned when the foreground and background
+// colors of a rendering are too similar for the code to scan reliably.
+var ErrLowContrast = errors.New("low QR color contrast")
+
+// DefaultMinContrast is the default minimum contrast ratio
+// between the foreground and background colors.
+const DefaultMinContrast = 3.0
+
+// MinContrast sets the minimum contrast ratio, as computed by
+// ContrastRatio, between the foreground and background colors.
+// The default is DefaultMinContrast.  A ratio of 0 disables the check.
+//
+// Only CheckContrast, RenderFormat, DataURI, and Renderer.WriteTo
+// enforce the minimum, by returning an error wrapping ErrLowContrast.
+// The other renderers, such as PNG, SVG, Image, and Render, draw the
+// colors as given, so callers using them should call CheckContrast
+// first.  Gradient colors are adjusted to meet the minimum by every
+// renderer; see LinearGradient.
+func MinContrast(ratio float64) RenderOption {
+	return func(r *render) { r.minContrast = ratio }
+}
+
+// ContrastRatio returns the contrast ratio between two colors as
+// defined by WCAG 2: (L1+0.05)/(L2+0.05), where L1 and L2 are the
+// relative luminances of the lighter and darker color.
+// The ratio ranges from 1 for identical colors to 21 for black on white.
+// Transparent colors are treated as if drawn on white.
+func ContrastRatio(a, b color.Color) float64 {
+	la, lb := luminance(a), luminance(b)
+	if la < lb {
+		la, lb = lb, la
+	}
+	return (la + 0.05) / (lb + 0.05)
+}
+
+// luminance returns the relative luminance of col
+// composited over white.
+func luminance(col color.Color) float64 {
+	r, g, b, a := col.RGBA()
+	lin := func(v uint32) float64 {
+		return srgbToLinear(float64(v+0xffff-a) / 0xffff) // composite over white
+	}
+	return 0.2126*lin(r) + 0.7152*lin(g) + 0.0722*lin(b)
+}
+
+// checkContrast returns an error wrapping ErrLowContrast
+// if r's colors do not meet its minimum contrast.
+func (r *render) checkContrast() error {
+	if r.minContrast <= 0 {
+		return nil
+	}
+	fg := []color.Color{r.fg}
+	if r.grad != nil {
+		fg = []color.Color{r.grad.from, r.grad.to}
+	}
+	for _, col := range fg {
+		if cr := ContrastRatio(col, r.bg); cr < r.minContrast {
+			return fmt.Errorf("%w: ratio %.2f is below %.2f", ErrLowContrast, cr, r.minContrast)
+		}
+	}
+	return nil
+}
+
+// CheckContrast reports whether the foreground and background colors
+// set by opts contrast enough for the code to scan reliably.
+// It returns an error wrapping ErrLowContrast if not.
+func (c *Code) CheckContrast(opts ...RenderOption) error {
+	r := c.render(opts)
+	return r.checkContrast()
+}
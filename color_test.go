@@ -0,0 +1,58 @@
+// Copyright 2011 The Go Authors.  All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package qr
+
+import (
+	"errors"
+	"image/color"
+	"math"
+	"testing"
+)
+
+var contrastTests = []struct {
+	a, b color.Color
+	want float64
+}{
+	{color.Black, color.White, 21},
+	{color.White, color.Black, 21},
+	{color.Gray{0x77}, color.Gray{0x77}, 1},
+	{color.RGBA{0, 0, 0xff, 0xff}, color.White, 8.59},
+	{color.RGBA{0xff, 0, 0, 0xff}, color.White, 4.00},
+	{color.Transparent, color.White, 1},
+}
+
+func TestContrastRatio(t *testing.T) {
+	for _, tt := range contrastTests {
+		if got := ContrastRatio(tt.a, tt.b); math.Abs(got-tt.want) > 0.01 {
+			t.Errorf("ContrastRatio(%v, %v) = %.3f, want %.2f", tt.a, tt.b, got, tt.want)
+		}
+	}
+}
+
+func TestCheckContrast(t *testing.T) {
+	c, err := Encode("hello, world", L)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := c.CheckContrast(); err != nil {
+		t.Errorf("CheckContrast() = %v, want nil", err)
+	}
+	low := []RenderOption{Foreground(color.Gray{0x99}), Background(color.Gray{0xcc})}
+	if err := c.CheckContrast(low...); !errors.Is(err, ErrLowContrast) {
+		t.Errorf("CheckContrast(gray on gray) = %v, want ErrLowContrast", err)
+	}
+	if _, err := c.RenderFormat(FormatPNG, low...); !errors.Is(err, ErrLowContrast) {
+		t.Errorf("RenderFormat(gray on gray) = %v, want ErrLowContrast", err)
+	}
+	if _, err := c.DataURI(FormatSVG, low...); !errors.Is(err, ErrLowContrast) {
+		t.Errorf("DataURI(gray on gray) = %v, want ErrLowContrast", err)
+	}
+	if _, err := c.RenderFormat(FormatPNG, append(low, MinContrast(0))...); err != nil {
+		t.Errorf("RenderFormat(gray on gray, MinContrast(0)) = %v, want nil", err)
+	}
+	if err := c.CheckContrast(Foreground(color.RGBA{0xff, 0, 0, 0xff}), MinContrast(4.5)); err == nil {
+		t.Errorf("CheckContrast(red, MinContrast(4.5)) = nil, want error")
+	}
+}
@@ -0,0 +1,649 @@
+// Copyright 2011 The Go Authors.  All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package qr
+
+// Decoding of QR codes.
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"image"
+	_ "image/jpeg" // register JPEG for DecodeReader
+	"io"
+	"os"
+	"sort"
+	"strings"
+	"time"
+	"unicode/utf8"
+
+	"github.com/inkstray/rsc-qr/coding"
+	"golang.org/x/text/encoding"
+	"golang.org/x/text/encoding/charmap"
+	"golang.org/x/text/encoding/japanese"
+	"golang.org/x/text/encoding/simplifiedchinese"
+)
+
+// ErrNotFound is returned by DecodeImage for images
+// in which it finds no QR code.
+var ErrNotFound = errors.New("no QR code found")
+
+// A Result is a decoded QR code.
+type Result struct {
+	// Text is the data held in the code: the concatenation of its
+	// numeric, alphanumeric, byte, Kanji, and Hanzi segments,
+	// converted to UTF-8.  Byte segments are converted from the
+	// character set selected by the ECI designator before them, if it
+	// has one (see coding.ECICharset).  Byte segments with no
+	// designator, as in most codes, are taken as UTF-8 if valid and
+	// as ISO 8859-1, the standard's default, if not.  In codes in an
+	// FNC1 mode, alphanumeric % stands for GS and %% for %, as the
+	// standard specifies.
+	Text string
+
+	// Raw is the data held in the code as stored, before conversion
+	// to UTF-8: the bytes of byte segments, ASCII digits and letters
+	// for numeric and alphanumeric segments, Shift JIS for Kanji,
+	// and GB 2312 for Hanzi.
+	Raw []byte
+
+	// Code is the code's bitmap, as read, without a quiet zone.
+	// Its Info holds the version, level, mask, and segments.
+	Code *Code
+
+	// Version, Level, and Mask are the code's version,
+	// error correction level, and mask pattern.
+	Version Version
+	Level   Level
+	Mask    Mask
+
+	// Corrected is the number of bytes corrected in each error
+	// correction block.  Counts near half the block's check bytes
+	// mean the code is close to unreadable.
+	Corrected []int
+
+	// Segments describes the segments of the code, in order.
+	Segments []Segment
+
+	// ECIs lists the ECI designators in the code, in order.
+	ECIs []coding.ECI
+
+	// Append is the structured append header of a code
+	// that is part of a series, or nil.
+	Append *coding.StructuredAppend
+
+	// GS1 holds the elements of a code in GS1 mode, which starts
+	// with FNC1 in first position, as parsed by ParseGS1.  It is nil
+	// for other codes and for codes whose Text does not parse.
+	GS1 []GS1Element
+
+	// Corners holds the corners of the code in the image, not
+	// counting the quiet zone: top left, top right, bottom right,
+	// and bottom left, as seen in the code's normal orientation.
+	// It is set only by DecodeImage.
+	Corners [4]image.Point
+
+	// Rotation is how far the code is turned clockwise in the
+	// image, in degrees, rounded to 0, 90, 180, or 270: the
+	// direction of its top edge, from the top left corner to the
+	// top right one.  A code flipped left to right, for example,
+	// is Mirrored with Rotation 180.  Rotation is set only by
+	// DecodeImage, which reads codes at any angle by orienting
+	// them from their finder patterns.
+	Rotation int
+
+	// Quality grades how well the code read.  Decode, which reads
+	// a bitmap rather than an image, sets Margin and Alignment to 1.
+	Quality Quality
+
+	// QuietZone is the width, in modules, of the light border
+	// around the code in the image, up to the 4 modules the
+	// standard requires.  It is set only by DecodeImage.
+	QuietZone int
+
+	// Mirrored and Inverted report whether the code was read
+	// from a mirror image or with light modules on a dark
+	// background.
+	Mirrored bool
+	Inverted bool
+}
+
+// A Quality grades how well a code read, so that printed codes can
+// be checked before they fail to scan.  Each measure is better
+// when higher, except FormatErrors and ECCUsed.
+type Quality struct {
+	// Margin is the fraction of samples taken around the centers
+	// of the modules that agree with the centers, averaged over the
+	// modules.  It is 1 for crisp modules and lower for blurred,
+	// misregistered, or distorted ones.
+	Margin float64
+
+	// FormatErrors is the number of format information bits
+	// corrected, up to 3.
+	FormatErrors int
+
+	// ECCUsed is the largest fraction of error correction capacity
+	// used by any block: the bytes corrected over half the block's
+	// check bytes.
+	ECCUsed float64
+
+	// Alignment is the fraction of the alignment patterns found where
+	// expected.  It is 1 for version 1, which has none.
+	Alignment float64
+}
+
+// Score returns an overall grade from 0 to 1: the worst of the
+// measures, each scaled to that range, as print quality standards
+// grade a code by its worst parameter.
+func (q Quality) Score() float64 {
+	s := q.Margin
+	if f := 1 - float64(q.FormatErrors)/4; f < s {
+		s = f
+	}
+	if e := 1 - q.ECCUsed; e < s {
+		s = e
+	}
+	if q.Alignment < s {
+		s = q.Alignment
+	}
+	if s < 0 {
+		s = 0
+	}
+	return s
+}
+
+// A Segment describes one segment of a decoded code.
+type Segment struct {
+	Mode coding.Mode
+
+	// Start and End are the byte offsets in Result.Text of the
+	// segment's data.  They are equal for segments without data,
+	// such as ECI designators.
+	Start, End int
+}
+
+// Decode decodes the bitmap of c, which must be in its normal
+// orientation or its mirror image flipped about the diagonal from
+// top left to bottom right.  The bitmap may include a quiet zone as
+// recorded in c.QuietZone.  The Info of c, if any, is not consulted.
+// If some of the code's error correction blocks are too damaged to
+// correct, the error is a *coding.ErrDamagedBlocks describing what
+// can be recovered.
+func Decode(c *Code) (*Result, error) {
+	cc := c.coding()
+	if q := c.QuietZone; q > 0 {
+		cc = crop(cc, q, q, c.Size-2*q)
+	}
+	return decode(cc)
+}
+
+// decode decodes c, which has no quiet zone.
+// If c does not decode, decode tries its mirror image,
+// as seen through glass or from the back of film.
+func decode(c *coding.Code) (*Result, error) {
+	mirrored := false
+	info, err := coding.Decode(c)
+	if err != nil {
+		t := transpose(c)
+		info2, err2 := coding.Decode(t)
+		if err2 != nil {
+			return nil, err
+		}
+		c, info, mirrored = t, info2, true
+	}
+	code := &Code{Bitmap: c.Bitmap, Size: c.Size, Stride: c.Stride, Scale: 1, Level: Level(info.Level), Info: info}
+	res := newResult(code)
+	res.Mirrored = mirrored
+	return res, nil
+}
+
+// transpose returns c flipped about its main diagonal, which turns
+// the mirror image of a code sampled from its finder patterns
+// into the code.
+func transpose(c *coding.Code) *coding.Code {
+	t := &coding.Code{Size: c.Size, Stride: c.Stride, Bitmap: make([]byte, len(c.Bitmap))}
+	for y := 0; y < c.Size; y++ {
+		for x := 0; x < c.Size; x++ {
+			if c.Black(y, x) {
+				t.Bitmap[y*t.Stride+x/8] |= 0x80 >> uint(x&7)
+			}
+		}
+	}
+	return t
+}
+
+// newResult returns the Result describing the decoded code c.
+func newResult(c *Code) *Result {
+	info := c.Info
+	res := &Result{
+		Code:      c,
+		Version:   Version(info.Version),
+		Level:     Level(info.Level),
+		Mask:      Mask(info.Mask),
+		Corrected: info.Corrected,
+		Quality: Quality{
+			Margin:       1,
+			FormatErrors: info.FormatErrors,
+			Alignment:    1,
+		},
+	}
+	ne := float64(info.Version.BlockCheckBytes(info.Level))
+	for _, n := range info.Corrected {
+		if u := float64(n) / (ne / 2); u > res.Quality.ECCUsed {
+			res.Quality.ECCUsed = u
+		}
+	}
+	var b strings.Builder
+	var enc encoding.Encoding // charset of byte segments; nil for the default
+	var fnc1, gs1 bool        // FNC1 mode; FNC1 in first position
+	for _, s := range info.Segments {
+		start := b.Len()
+		var mode coding.Mode
+		switch s := s.(type) {
+		case coding.Num:
+			mode = coding.ModeNumeric
+			b.WriteString(string(s))
+			res.Raw = append(res.Raw, s...)
+		case coding.Alpha:
+			mode = coding.ModeAlphanumeric
+			if fnc1 {
+				// In FNC1 modes, % stands for FNC1 and %% for %.
+				s = coding.Alpha(fnc1Percent.Replace(string(s)))
+			}
+			b.WriteString(string(s))
+			res.Raw = append(res.Raw, s...)
+		case coding.Bytes:
+			mode = coding.ModeByte
+			b.WriteString(transcode(s, enc))
+			res.Raw = append(res.Raw, s...)
+		case coding.Kanji:
+			mode = coding.ModeKanji
+			b.WriteString(string(s))
+			k, _ := japanese.ShiftJIS.NewEncoder().String(string(s))
+			res.Raw = append(res.Raw, k...)
+		case coding.Hanzi:
+			mode = coding.ModeHanzi
+			b.WriteString(string(s))
+			k, _ := simplifiedchinese.GBK.NewEncoder().String(string(s))
+			res.Raw = append(res.Raw, k...)
+		case coding.ECI:
+			mode = coding.ModeECI
+			res.ECIs = append(res.ECIs, s)
+			enc, _ = coding.ECICharset(s)
+		case coding.StructuredAppend:
+			mode = coding.ModeStructuredAppend
+			a := s
+			res.Append = &a
+		case coding.Raw:
+			mode = coding.Mode(s.Mode)
+			switch mode {
+			case coding.ModeFNC1First:
+				fnc1, gs1 = true, true
+			case coding.ModeFNC1Second:
+				fnc1 = true
+			}
+		}
+		res.Segments = append(res.Segments, Segment{Mode: mode, Start: start, End: b.Len()})
+	}
+	res.Text = b.String()
+	if gs1 {
+		res.GS1, _ = ParseGS1(res.Text)
+	}
+	return res
+}
+
+// fnc1Percent rewrites the alphanumeric data of a code in an FNC1 mode.
+var fnc1Percent = strings.NewReplacer("%%", "%", "%", GS)
+
+// transcode converts data in the character set enc to UTF-8.
+// If enc is nil or data is not valid in enc, transcode returns data
+// itself if it is valid UTF-8 and converts it from ISO 8859-1 if not.
+func transcode(data []byte, enc encoding.Encoding) string {
+	if enc != nil {
+		if s, err := enc.NewDecoder().Bytes(data); err == nil {
+			return string(s)
+		}
+	}
+	if utf8.Valid(data) {
+		return string(data)
+	}
+	s, _ := charmap.ISO8859_1.NewDecoder().Bytes(data)
+	return string(s)
+}
+
+// crop returns the size×size square of c with top left corner (x, y).
+func crop(c *coding.Code, x, y, size int) *coding.Code {
+	d := &coding.Code{Size: size, Stride: (size + 7) / 8}
+	d.Bitmap = make([]byte, d.Stride*size)
+	for yy := 0; yy < size; yy++ {
+		for xx := 0; xx < size; xx++ {
+			if c.Black(x+xx, y+yy) {
+				d.Bitmap[yy*d.Stride+xx/8] |= 0x80 >> uint(xx&7)
+			}
+		}
+	}
+	return d
+}
+
+// An ErrIncompleteSeries reports that codes of a
+// Structured Append series are missing.
+type ErrIncompleteSeries struct {
+	Total   int   // number of codes in the series
+	Missing []int // indexes of the missing codes, in increasing order
+}
+
+func (e *ErrIncompleteSeries) Error() string {
+	return fmt.Sprintf("incomplete QR series: missing codes %v of %d", e.Missing, e.Total)
+}
+
+// ErrSeriesParity is returned by DecodeSeries when the parity of
+// the reassembled data does not match the codes' headers.
+var ErrSeriesParity = errors.New("QR series parity mismatch")
+
+// DecodeSeries reassembles the data of a Structured Append series,
+// such as one written by EncodeSeries, from its decoded codes, which
+// may be in any order and may repeat.  The data is the concatenation
+// of the codes' Raw bytes, so that a character split between two codes
+// survives.  DecodeSeries checks that the codes agree on the series
+// size and parity, that none are missing, and that the parity of the
+// data matches.  A single code that is not part of a series is
+// returned as is.
+func DecodeSeries(results []Result) ([]byte, error) {
+	if len(results) == 1 && results[0].Append == nil {
+		return results[0].Raw, nil
+	}
+	var head *coding.StructuredAppend
+	var parts []*Result
+	for i := range results {
+		r := &results[i]
+		a := r.Append
+		if a == nil {
+			return nil, fmt.Errorf("QR code %d is not part of a series", i)
+		}
+		if head == nil {
+			head = a
+			parts = make([]*Result, a.Total)
+		}
+		if a.Total != head.Total || a.Parity != head.Parity {
+			return nil, fmt.Errorf("QR code %d belongs to another series: code %d of %d with parity %#02x, want %d codes with parity %#02x",
+				i, a.Index, a.Total, a.Parity, head.Total, head.Parity)
+		}
+		if a.Index >= a.Total {
+			return nil, fmt.Errorf("QR code %d has invalid series position %d of %d", i, a.Index, a.Total)
+		}
+		if p := parts[a.Index]; p != nil && !bytes.Equal(p.Raw, r.Raw) {
+			return nil, fmt.Errorf("QR series has two different codes at index %d", a.Index)
+		}
+		parts[a.Index] = r
+	}
+	if head == nil {
+		return nil, ErrNotFound
+	}
+	var data []byte
+	var missing []int
+	for i, p := range parts {
+		if p == nil {
+			missing = append(missing, i)
+			continue
+		}
+		data = append(data, p.Raw...)
+	}
+	if missing != nil {
+		return nil, &ErrIncompleteSeries{Total: head.Total, Missing: missing}
+	}
+	if p := coding.Parity(data); p != head.Parity {
+		return nil, fmt.Errorf("%w: data has parity %#02x, want %#02x", ErrSeriesParity, p, head.Parity)
+	}
+	return data, nil
+}
+
+// DecodeImage locates and decodes the QR codes in img, which may be
+// a screenshot, a photograph, or a scanned sheet of labels.  It
+// returns the codes ordered by the position of their top left
+// corners, from top to bottom and then left to right.
+// Codes may be rotated, scaled, mirrored, or seen in perspective,
+// and they need a quiet zone at least one module wide, unless
+// RequireQuietZone asks for more.  Codes with light modules on a dark
+// background, as on dark-mode screens or etched metal, are decoded
+// too, if the image holds no dark-on-light codes.
+// If img holds no readable code, DecodeImage returns ErrNotFound,
+// or the error decoding the most likely candidate.
+// DecodeImage reads *image.Gray and *image.YCbCr images, such as
+// camera frames, directly, without converting each pixel's color.
+func DecodeImage(img image.Image, opts ...DecodeOption) ([]Result, error) {
+	return newDecoder(opts).decodeImage(img)
+}
+
+// DecodeLuma is like DecodeImage but reads a width×height gray image
+// stored in pix, one byte per pixel with rows stride bytes apart,
+// such as the Y plane of a webcam frame in a YUV format.  It does
+// not keep pix after it returns, so a frame buffer can be reused.
+func DecodeLuma(pix []byte, width, height, stride int, opts ...DecodeOption) ([]Result, error) {
+	if width < 0 || height < 0 || stride < width || height > 0 && len(pix) < (height-1)*stride+width {
+		return nil, fmt.Errorf("invalid %d×%d luma image with stride %d in %d bytes", width, height, stride, len(pix))
+	}
+	d := newDecoder(opts)
+	if err := d.checkSize(width, height); err != nil {
+		return nil, err
+	}
+	return d.decode(binarizeGray(pix, width, height, stride, image.Point{}))
+}
+
+// decodeImage checks the size of img and decodes it.
+func (d *decoder) decodeImage(img image.Image) ([]Result, error) {
+	if err := d.checkSize(img.Bounds().Dx(), img.Bounds().Dy()); err != nil {
+		return nil, err
+	}
+	return d.decode(binarize(img))
+}
+
+// decode decodes the codes in b, as DecodeImage does.
+func (d *decoder) decode(b *binImage) ([]Result, error) {
+	list, err := b.decodeAll(d)
+	if err != nil && !errors.Is(err, context.DeadlineExceeded) {
+		b.invert()
+		inv, err2 := b.decodeAll(d)
+		if err2 == nil {
+			for i := range inv {
+				inv[i].Inverted = true
+			}
+			return inv, nil
+		}
+		if errors.Is(err, ErrNotFound) || errors.Is(err2, context.DeadlineExceeded) {
+			err = err2
+		}
+	}
+	return list, err
+}
+
+// DecodeReader reads a PNG, JPEG, GIF, or netpbm (PBM or PGM) image
+// from r and decodes the QR codes in it, as DecodeImage does.
+func DecodeReader(r io.Reader, opts ...DecodeOption) ([]Result, error) {
+	d := newDecoder(opts)
+	br := bufio.NewReader(r)
+	var img image.Image
+	var err error
+	if magic, _ := br.Peek(2); isNetpbm(magic) {
+		img, err = readNetpbm(br, d.checkSize)
+	} else {
+		// Check the size in the header before
+		// allocating memory for the pixels.
+		var head bytes.Buffer
+		var cfg image.Config
+		cfg, _, err = image.DecodeConfig(io.TeeReader(br, &head))
+		if err == nil {
+			err = d.checkSize(cfg.Width, cfg.Height)
+		}
+		if err == nil {
+			img, _, err = image.Decode(io.MultiReader(&head, br))
+		}
+	}
+	if err != nil {
+		return nil, fmt.Errorf("reading image: %w", err)
+	}
+	return d.decodeImage(img)
+}
+
+// DecodeFile reads an image from the named file
+// and decodes the QR codes in it, as DecodeReader does.
+func DecodeFile(name string, opts ...DecodeOption) ([]Result, error) {
+	f, err := os.Open(name)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+	list, err := DecodeReader(f, opts...)
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", name, err)
+	}
+	return list, nil
+}
+
+// ErrQuietZone is returned by DecodeImage for codes whose quiet
+// zone is narrower than RequireQuietZone asks for.
+var ErrQuietZone = errors.New("QR quiet zone too narrow")
+
+// A DecodeOption configures DecodeImage, DecodeLuma, DecodeReader,
+// and DecodeFile.
+type DecodeOption func(*decoder)
+
+// A decoder holds the settings for DecodeImage.
+type decoder struct {
+	quiet         int       // minimum quiet zone, in modules
+	maxCandidates int       // finder triples to try, or 0 for no limit
+	maxCodes      int       // codes to return, or 0 for no limit
+	maxW, maxH    int       // largest image size, or 0 for no limit
+	deadline      time.Time // time to give up, or zero for no limit
+}
+
+func newDecoder(opts []DecodeOption) *decoder {
+	d := new(decoder)
+	for _, opt := range opts {
+		opt(d)
+	}
+	return d
+}
+
+// ErrImageTooLarge is returned by DecodeImage and the functions
+// like it for images larger than MaxImageSize allows.
+var ErrImageTooLarge = errors.New("image too large to decode")
+
+// checkSize checks a w×h image against MaxImageSize.
+func (d *decoder) checkSize(w, h int) error {
+	if d.maxW > 0 && w > d.maxW || d.maxH > 0 && h > d.maxH {
+		return fmt.Errorf("%w: %d×%d, limit %d×%d", ErrImageTooLarge, w, h, d.maxW, d.maxH)
+	}
+	return nil
+}
+
+// expired reports whether d's deadline has passed.
+// A nil decoder has no deadline.
+func (d *decoder) expired() bool {
+	return d != nil && !d.deadline.IsZero() && time.Now().After(d.deadline)
+}
+
+// errDeadline is returned when the deadline passes.
+var errDeadline = fmt.Errorf("decoding QR codes: %w", context.DeadlineExceeded)
+
+// RequireQuietZone makes DecodeImage reject codes whose quiet zone is
+// narrower than n modules, for checking printed codes against the
+// 4 modules the standard requires.  By default, DecodeImage reads
+// codes with quiet zones as narrow as one module, as found in
+// cramped print layouts.
+func RequireQuietZone(n int) DecodeOption {
+	return func(d *decoder) { d.quiet = n }
+}
+
+// The options below bound the work DecodeImage does, for services
+// that decode untrusted images, which may be made to be slow to
+// decode.  By default, there are no limits.
+
+// MaxImageSize makes DecodeImage, DecodeLuma, and DecodeReader
+// reject images wider or taller than the given size with
+// ErrImageTooLarge.  DecodeReader checks the size in the image
+// header before reading the pixels.  A limit of 0 means no limit.
+func MaxImageSize(width, height int) DecodeOption {
+	return func(d *decoder) { d.maxW, d.maxH = width, height }
+}
+
+// MaxCandidates limits the number of arrangements of finder patterns
+// that DecodeImage tries to decode, in each of the normal and inverted
+// passes.  It tries the most likely first.  Each candidate costs about
+// as much as decoding a code, and an image full of finder-like shapes
+// can have thousands.
+func MaxCandidates(n int) DecodeOption {
+	return func(d *decoder) { d.maxCandidates = n }
+}
+
+// MaxCodes makes DecodeImage stop after finding n codes.
+func MaxCodes(n int) DecodeOption {
+	return func(d *decoder) { d.maxCodes = n }
+}
+
+// Deadline makes DecodeImage give up at time t.  It returns an error
+// matching context.DeadlineExceeded in errors.Is, even if it has
+// found codes by then.
+func Deadline(t time.Time) DecodeOption {
+	return func(d *decoder) { d.deadline = t }
+}
+
+// decodeAll decodes the codes in b, ordered by the position of their
+// top left corners from top to bottom and then left to right.
+func (b *binImage) decodeAll(d *decoder) ([]Result, error) {
+	var list []Result
+	var used []FinderPattern
+	var firstErr error
+	finders, err := findFinders(b, d)
+	if err != nil {
+		return nil, err
+	}
+	tries := 0
+Triples:
+	for _, t := range triples(finders) {
+		if d.expired() {
+			return nil, errDeadline
+		}
+		if d.maxCodes > 0 && len(list) >= d.maxCodes || d.maxCandidates > 0 && tries >= d.maxCandidates {
+			break
+		}
+		// Each finder pattern belongs to only one code.
+		for _, u := range used {
+			if u == t.tl || u == t.tr || u == t.bl {
+				continue Triples
+			}
+		}
+		tries++
+		res, err := b.decode(t)
+		if err != nil {
+			if firstErr == nil {
+				firstErr = err
+			}
+			continue
+		}
+		used = append(used, t.tl, t.tr, t.bl)
+		if res.QuietZone < d.quiet {
+			// A code read but rejected explains the failure
+			// better than any candidate that did not read.
+			if !errors.Is(firstErr, ErrQuietZone) {
+				firstErr = fmt.Errorf("%w: %d modules, want %d", ErrQuietZone, res.QuietZone, d.quiet)
+			}
+			continue
+		}
+		list = append(list, *res)
+	}
+	if len(list) == 0 {
+		if firstErr == nil {
+			firstErr = ErrNotFound
+		}
+		return nil, firstErr
+	}
+	sort.SliceStable(list, func(i, j int) bool {
+		p, q := list[i].Corners[0], list[j].Corners[0]
+		return p.Y < q.Y || p.Y == q.Y && p.X < q.X
+	})
+	return list, nil
+}
@@ -0,0 +1,682 @@
+// Copyright 2011 The Go Authors.  All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package qr
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"image"
+	"image/color"
+	"image/draw"
+	"image/jpeg"
+	"image/png"
+	"math"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/inkstray/rsc-qr/coding"
+)
+
+var decodeTexts = []struct {
+	text  string
+	level Level
+}{
+	{"hello, world", L},
+	{"0123456789012345", H},
+	{"HTTPS://EXAMPLE.COM/QR", M},
+	{strings.Repeat("Lorem ipsum dolor sit amet. ", 8), Q},
+	{strings.Repeat("0123456789", 60), M},
+}
+
+func TestDecode(t *testing.T) {
+	for _, tt := range decodeTexts {
+		c, err := Encode(tt.text, tt.level)
+		if err != nil {
+			t.Fatal(err)
+		}
+		res, err := Decode(c)
+		if err != nil {
+			t.Errorf("Decode(%.20q): %v", tt.text, err)
+			continue
+		}
+		if res.Text != tt.text || res.Code.Level != tt.level || !res.Code.coding().Equal(c.coding()) {
+			t.Errorf("Decode(%.20q) = %.20q, level %v", tt.text, res.Text, res.Code.Level)
+		}
+	}
+}
+
+// warp returns the w×h image whose pixel (x, y) is the pixel of img
+// at f(x, y), or white outside img.
+func warp(img image.Image, w, h int, f func(x, y float64) (float64, float64)) image.Image {
+	dst := image.NewGray(image.Rect(0, 0, w, h))
+	b := img.Bounds()
+	for y := 0; y < h; y++ {
+		for x := 0; x < w; x++ {
+			sx, sy := f(float64(x)+0.5, float64(y)+0.5)
+			p := image.Pt(int(math.Floor(sx)), int(math.Floor(sy)))
+			c := color.Color(color.White)
+			if p.In(b) {
+				c = img.At(p.X, p.Y)
+			}
+			dst.Set(x, y, c)
+		}
+	}
+	return dst
+}
+
+func TestDecodeImage(t *testing.T) {
+	for _, tt := range decodeTexts {
+		c, err := Encode(tt.text, tt.level)
+		if err != nil {
+			t.Fatal(err)
+		}
+		img := c.Image(ModuleSize(4))
+		d := float64(img.Bounds().Dx())
+		images := map[string]image.Image{
+			"plain": img,
+			"offset": warp(img, int(d)+50, int(d)+30, func(x, y float64) (float64, float64) {
+				return x - 40, y - 10
+			}),
+			"scaled": warp(img, int(d*1.3), int(d*1.3), func(x, y float64) (float64, float64) {
+				return x / 1.3, y / 1.3
+			}),
+			"rotated 90": warp(img, int(d), int(d), func(x, y float64) (float64, float64) {
+				return y, d - x
+			}),
+			"rotated 180": warp(img, int(d), int(d), func(x, y float64) (float64, float64) {
+				return d - x, d - y
+			}),
+			"rotated 30": warp(img, int(d*1.5), int(d*1.5), func(x, y float64) (float64, float64) {
+				s, c := math.Sincos(30 * math.Pi / 180)
+				x, y = x-d*0.75, y-d*0.75
+				return c*x - s*y + d/2, s*x + c*y + d/2
+			}),
+		}
+		if c.Size > 21 {
+			// Version 1 has no alignment pattern to correct
+			// for perspective with.
+			images["perspective"] = warp(img, int(d), int(d), quadToQuad(
+				// Farther away at the bottom.
+				[4][2]float64{{0, 0}, {d, 0}, {d * 0.95, d * 0.95}, {d * 0.05, d * 0.95}},
+				[4][2]float64{{0, 0}, {d, 0}, {d, d}, {0, d}},
+			).apply)
+		}
+		for name, img := range images {
+			res, err := DecodeImage(img)
+			if err != nil {
+				t.Errorf("DecodeImage(%.20q, %s): %v", tt.text, name, err)
+				continue
+			}
+			if len(res) != 1 || res[0].Text != tt.text {
+				t.Errorf("DecodeImage(%.20q, %s) = %d results, %.20q", tt.text, name, len(res), res[0].Text)
+			}
+		}
+	}
+}
+
+func TestDecodeImageColor(t *testing.T) {
+	c, err := Encode("colors", M)
+	if err != nil {
+		t.Fatal(err)
+	}
+	img := c.Image(ModuleSize(3), Foreground(color.RGBA{0x20, 0x30, 0x90, 0xff}), Background(color.RGBA{0xff, 0xf0, 0xc0, 0xff}))
+	res, err := DecodeImage(img)
+	if err != nil || res[0].Text != "colors" {
+		t.Errorf("DecodeImage(colors) = %v, %v", res, err)
+	}
+}
+
+func TestDecodeImageNotFound(t *testing.T) {
+	img := image.NewGray(image.Rect(0, 0, 100, 100))
+	if _, err := DecodeImage(img); !errors.Is(err, ErrNotFound) {
+		t.Errorf("DecodeImage(blank) = %v, want ErrNotFound", err)
+	}
+}
+
+func TestDecodeMetadata(t *testing.T) {
+	cc, err := coding.Encode(3, coding.Q,
+		coding.StructuredAppend{Index: 1, Total: 2, Parity: 0x42},
+		coding.ECI(26),
+		coding.Num("123"),
+		coding.Bytes("héllo"),
+	)
+	if err != nil {
+		t.Fatal(err)
+	}
+	res, err := Decode(&Code{Bitmap: cc.Bitmap, Size: cc.Size, Stride: cc.Stride})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if res.Text != "123héllo" || res.Version != 3 || res.Level != Q || res.Mask != Mask(cc.Info.Mask) {
+		t.Errorf("Decode = %q, version %v, level %v, mask %d", res.Text, res.Version, res.Level, res.Mask)
+	}
+	wantSegs := []Segment{
+		{coding.ModeStructuredAppend, 0, 0},
+		{coding.ModeECI, 0, 0},
+		{coding.ModeNumeric, 0, 3},
+		{coding.ModeByte, 3, 9},
+	}
+	if !reflect.DeepEqual(res.Segments, wantSegs) {
+		t.Errorf("Segments = %v, want %v", res.Segments, wantSegs)
+	}
+	if !reflect.DeepEqual(res.ECIs, []coding.ECI{26}) {
+		t.Errorf("ECIs = %v, want [26]", res.ECIs)
+	}
+	if a := res.Append; a == nil || *a != (coding.StructuredAppend{Index: 1, Total: 2, Parity: 0x42}) {
+		t.Errorf("Append = %v", a)
+	}
+	if res.Mirrored || res.Inverted {
+		t.Errorf("Mirrored, Inverted = %v, %v, want false, false", res.Mirrored, res.Inverted)
+	}
+}
+
+func TestDecodeMirrored(t *testing.T) {
+	c, err := Encode("through the looking glass", M)
+	if err != nil {
+		t.Fatal(err)
+	}
+	res, err := Decode(&Code{Bitmap: transpose(c.coding()).Bitmap, Size: c.Size, Stride: c.Stride})
+	if err != nil || res.Text != "through the looking glass" || !res.Mirrored {
+		t.Fatalf("Decode(transposed) = %v, %v", res, err)
+	}
+	if !res.Code.coding().Equal(c.coding()) {
+		t.Errorf("Decode(transposed) did not restore bitmap")
+	}
+
+	img := c.Image(ModuleSize(4))
+	d := float64(img.Bounds().Dx())
+	mirror := warp(img, int(d), int(d), func(x, y float64) (float64, float64) { return d - x, y })
+	list, err := DecodeImage(mirror)
+	if err != nil || list[0].Text != "through the looking glass" || !list[0].Mirrored {
+		t.Errorf("DecodeImage(mirror) = %v, %v", list, err)
+	}
+
+	if res, err := Decode(c); err != nil || res.Mirrored {
+		t.Errorf("Decode(normal) = %v, %v, want not mirrored", res, err)
+	}
+}
+
+func TestDecodeImageInverted(t *testing.T) {
+	c, err := Encode("dark mode", M)
+	if err != nil {
+		t.Fatal(err)
+	}
+	img := c.Image(ModuleSize(4), Foreground(color.White), Background(color.Black))
+	list, err := DecodeImage(img)
+	if err != nil || list[0].Text != "dark mode" || !list[0].Inverted {
+		t.Fatalf("DecodeImage(inverted) = %v, %v", list, err)
+	}
+	list, err = DecodeImage(c.Image(ModuleSize(4)))
+	if err != nil || list[0].Inverted {
+		t.Errorf("DecodeImage(normal) = %v, %v, want not inverted", list, err)
+	}
+}
+
+func TestDecodeSeries(t *testing.T) {
+	data := make([]byte, 5000)
+	for i := range data {
+		data[i] = byte(i * 7)
+	}
+	codes, err := EncodeSeries(data, M)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var results []Result
+	for i := len(codes) - 1; i >= 0; i-- {
+		res, err := Decode(codes[i])
+		if err != nil {
+			t.Fatal(err)
+		}
+		results = append(results, *res)
+	}
+	results = append(results, results[0]) // a repeat
+	got, err := DecodeSeries(results)
+	if err != nil || !bytes.Equal(got, data) {
+		t.Fatalf("DecodeSeries = %d bytes, %v, want %d bytes", len(got), err, len(data))
+	}
+
+	_, err = DecodeSeries(results[1:2])
+	var inc *ErrIncompleteSeries
+	if !errors.As(err, &inc) || inc.Total != 3 || !reflect.DeepEqual(inc.Missing, []int{0, 2}) {
+		t.Errorf("DecodeSeries(one of three) = %v, want missing [0 2] of 3", err)
+	}
+
+	bad := append([]Result(nil), results[:3]...)
+	a := *bad[0].Append
+	a.Parity ^= 1
+	for i := range bad {
+		aa := *bad[i].Append
+		aa.Parity = a.Parity
+		bad[i].Append = &aa
+	}
+	if _, err := DecodeSeries(bad); !errors.Is(err, ErrSeriesParity) {
+		t.Errorf("DecodeSeries(bad parity) = %v, want ErrSeriesParity", err)
+	}
+
+	single, err := Encode("single", M)
+	if err != nil {
+		t.Fatal(err)
+	}
+	res, err := Decode(single)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got, err := DecodeSeries([]Result{*res}); err != nil || string(got) != "single" {
+		t.Errorf("DecodeSeries(single) = %q, %v", got, err)
+	}
+	if _, err := DecodeSeries([]Result{*res, results[0]}); err == nil {
+		t.Errorf("DecodeSeries(mixed) succeeded")
+	}
+}
+
+func TestDecodeImageMultiple(t *testing.T) {
+	texts := []string{"first label", "SECOND LABEL", "3333333333", "fourth label, a little longer than the rest"}
+	dst := image.NewGray(image.Rect(0, 0, 600, 500))
+	draw.Draw(dst, dst.Bounds(), image.White, image.Point{}, draw.Src)
+	at := []image.Point{{10, 10}, {320, 20}, {30, 250}, {300, 260}}
+	var want [][4]image.Point
+	for i, text := range texts {
+		c, err := Encode(text, M)
+		if err != nil {
+			t.Fatal(err)
+		}
+		c.DrawTo(dst, at[i], ModuleSize(4))
+		p := at[i].Add(image.Pt(16, 16)) // quiet zone
+		n := c.Size * 4
+		want = append(want, [4]image.Point{p, p.Add(image.Pt(n, 0)), p.Add(image.Pt(n, n)), p.Add(image.Pt(0, n))})
+	}
+
+	list, err := DecodeImage(dst)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(list) != len(texts) {
+		t.Fatalf("DecodeImage found %d codes, want %d", len(list), len(texts))
+	}
+	for i, res := range list {
+		if res.Text != texts[i] {
+			t.Errorf("code %d = %q, want %q", i, res.Text, texts[i])
+		}
+		for j, p := range res.Corners {
+			if d := p.Sub(want[i][j]); d.X < -1 || d.X > 1 || d.Y < -1 || d.Y > 1 {
+				t.Errorf("code %d corners = %v, want %v", i, res.Corners, want[i])
+				break
+			}
+		}
+	}
+}
+
+func TestDecodeCorrected(t *testing.T) {
+	c, err := Encode("damaged", H)
+	if err != nil {
+		t.Fatal(err)
+	}
+	c = c.Clone()
+	c.Bitmap[20*c.Stride+2] ^= 0xff // bottom right corner: first bytes
+	res, err := Decode(c)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(res.Corrected) != 1 || res.Corrected[0] == 0 {
+		t.Errorf("Decode(damaged).Corrected = %v, want one block with corrections", res.Corrected)
+	}
+}
+
+func TestDecodeKanji(t *testing.T) {
+	const text = "QRコードは日本で発明された"
+	c, err := Encode(text, M)
+	if err != nil {
+		t.Fatal(err)
+	}
+	res, err := Decode(c)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if res.Text != text {
+		t.Errorf("Decode = %q, want %q", res.Text, text)
+	}
+	kanji := false
+	for _, s := range res.Segments {
+		if s.Mode == coding.ModeKanji {
+			kanji = true
+		}
+	}
+	if !kanji {
+		t.Errorf("Decode segments = %v, want a Kanji segment", res.Segments)
+	}
+}
+
+func TestDecodeCharset(t *testing.T) {
+	for _, tt := range []struct {
+		segs []coding.Encoding
+		text string
+		raw  string
+	}{
+		{[]coding.Encoding{coding.Bytes("h\xc3\xa9")}, "hé", "h\xc3\xa9"},
+		{[]coding.Encoding{coding.Bytes("h\xe9")}, "hé", "h\xe9"},
+		{[]coding.Encoding{coding.ECI(26), coding.Bytes("h\xc3\xa9")}, "hé", "h\xc3\xa9"},
+		{[]coding.Encoding{coding.ECI(4), coding.Bytes("\xb3\xf3d\xbc")}, "łódź", "\xb3\xf3d\xbc"},
+		{[]coding.Encoding{coding.ECI(20), coding.Bytes("\x93\xfa\x96\x7b"), coding.Num("42")}, "日本42", "\x93\xfa\x96\x7b42"},
+		{[]coding.Encoding{coding.ECI(7), coding.Bytes("\xbc"), coding.ECI(3), coding.Bytes("\xbc")}, "М¼", "\xbc\xbc"},
+		{[]coding.Encoding{coding.Kanji("点"), coding.Alpha("A")}, "点A", "\x93\x5fA"},
+		{[]coding.Encoding{coding.Hanzi("中文"), coding.Num("1")}, "中文1", "\xd6\xd0\xce\xc41"},
+	} {
+		cc, err := coding.Encode(2, coding.L, tt.segs...)
+		if err != nil {
+			t.Fatal(err)
+		}
+		res, err := Decode(&Code{Bitmap: cc.Bitmap, Size: cc.Size, Stride: cc.Stride})
+		if err != nil {
+			t.Errorf("Decode(%v): %v", tt.segs, err)
+			continue
+		}
+		if res.Text != tt.text || string(res.Raw) != tt.raw {
+			t.Errorf("Decode(%v) = %q, raw %q, want %q, raw %q", tt.segs, res.Text, res.Raw, tt.text, tt.raw)
+		}
+	}
+}
+
+func TestDecodeImageQuietZone(t *testing.T) {
+	c, err := Encode("cramped label", M)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for q := 1; q <= 4; q++ {
+		// Draw the code with a q-module quiet zone on a dark page.
+		img := c.Image(ModuleSize(4), QuietZone(q))
+		d := img.Bounds().Dx()
+		dst := image.NewGray(image.Rect(0, 0, d+40, d+40))
+		draw.Draw(dst, image.Rect(20, 20, 20+d, 20+d), img, img.Bounds().Min, draw.Src)
+
+		res, err := DecodeImage(dst)
+		if err != nil {
+			t.Errorf("DecodeImage(quiet zone %d): %v", q, err)
+			continue
+		}
+		if res[0].Text != "cramped label" || res[0].QuietZone != q {
+			t.Errorf("DecodeImage(quiet zone %d) = %q, quiet zone %d", q, res[0].Text, res[0].QuietZone)
+		}
+
+		res, err = DecodeImage(dst, RequireQuietZone(4))
+		if q < 4 && !errors.Is(err, ErrQuietZone) {
+			t.Errorf("DecodeImage(quiet zone %d, RequireQuietZone(4)) = %v, want ErrQuietZone", q, err)
+		}
+		if q == 4 && (err != nil || res[0].Text != "cramped label") {
+			t.Errorf("DecodeImage(quiet zone 4, RequireQuietZone(4)) = %v", err)
+		}
+	}
+}
+
+func TestDecodeReader(t *testing.T) {
+	const text = "read me from a file"
+	c, err := Encode(text, M)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var jpg bytes.Buffer
+	if err := jpeg.Encode(&jpg, c.Image(ModuleSize(4)), nil); err != nil {
+		t.Fatal(err)
+	}
+	files := map[string][]byte{
+		"png":       c.PNG(ModuleSize(3)),
+		"jpeg":      jpg.Bytes(),
+		"pbm":       c.PBM(ModuleSize(3)),
+		"plain pbm": bytes.Replace(c.PlainPBM(ModuleSize(2)), []byte("P1\n"), []byte("P1\n# comment\n"), 1),
+		"pgm":       c.PGM(ModuleSize(3), Foreground(color.Gray{0x20}), Background(color.Gray{0xd0})),
+	}
+	dir := t.TempDir()
+	for name, data := range files {
+		res, err := DecodeReader(bytes.NewReader(data))
+		if err != nil || len(res) != 1 || res[0].Text != text {
+			t.Errorf("DecodeReader(%s) = %v, %v", name, res, err)
+		}
+		file := filepath.Join(dir, strings.ReplaceAll(name, " ", "_"))
+		if err := os.WriteFile(file, data, 0666); err != nil {
+			t.Fatal(err)
+		}
+		res, err = DecodeFile(file)
+		if err != nil || len(res) != 1 || res[0].Text != text {
+			t.Errorf("DecodeFile(%s) = %v, %v", name, res, err)
+		}
+	}
+
+	for _, bad := range []string{"", "not an image", "P4\n10", "P1\n2 2\n0 1 2 0\n"} {
+		if _, err := DecodeReader(strings.NewReader(bad)); err == nil || errors.Is(err, ErrNotFound) {
+			t.Errorf("DecodeReader(%q) = %v, want read error", bad, err)
+		}
+	}
+	if _, err := DecodeFile(filepath.Join(dir, "missing.png")); !errors.Is(err, os.ErrNotExist) {
+		t.Errorf("DecodeFile(missing) = %v, want ErrNotExist", err)
+	}
+}
+
+func TestDecodeImageRotation(t *testing.T) {
+	c, err := Encode("which way up", M)
+	if err != nil {
+		t.Fatal(err)
+	}
+	img := c.Image(ModuleSize(4))
+	d := float64(img.Bounds().Dx())
+	turns := []func(x, y float64) (float64, float64){
+		func(x, y float64) (float64, float64) { return x, y },
+		func(x, y float64) (float64, float64) { return y, d - x },
+		func(x, y float64) (float64, float64) { return d - x, d - y },
+		func(x, y float64) (float64, float64) { return d - y, x },
+	}
+	for i, f := range turns {
+		for _, mirror := range []bool{false, true} {
+			g := f
+			if mirror {
+				g = func(x, y float64) (float64, float64) { return f(d-x, y) }
+			}
+			res, err := DecodeImage(warp(img, int(d), int(d), g))
+			if err != nil {
+				t.Errorf("DecodeImage(turned %d, mirrored %v): %v", 90*i, mirror, err)
+				continue
+			}
+			want := 90 * i
+			if mirror {
+				// Flipped left to right, the top edge
+				// runs from right to left.
+				want = (540 - want) % 360
+			}
+			if r := res[0]; r.Text != "which way up" || r.Rotation != want || r.Mirrored != mirror {
+				t.Errorf("DecodeImage(turned %d, mirrored %v) = %q, rotation %d, mirrored %v, want rotation %d",
+					90*i, mirror, r.Text, r.Rotation, r.Mirrored, want)
+			}
+		}
+	}
+}
+
+func TestDecodeQuality(t *testing.T) {
+	c, err := Encode(strings.Repeat("grade me ", 14), M)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if c.Info.Version < 7 {
+		t.Fatalf("version %v has only one alignment pattern", c.Info.Version)
+	}
+	const scale = 4
+	src := c.Image(ModuleSize(scale))
+	img := image.NewGray(src.Bounds())
+	draw.Draw(img, img.Bounds(), src, src.Bounds().Min, draw.Src)
+	res, err := DecodeImage(img)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if q := res[0].Quality; q != (Quality{Margin: 1, Alignment: 1}) || q.Score() != 1 {
+		t.Errorf("Quality(clean) = %+v, score %v, want perfect", q, q.Score())
+	}
+
+	// Hide the center alignment pattern, which holds no data,
+	// and smear the bottom right data modules to the left.
+	mid := (4 + c.Size/2 - 2) * scale
+	draw.Draw(img, image.Rect(mid, mid, mid+5*scale, mid+5*scale), image.White, image.Point{}, draw.Src)
+	end := (4 + c.Size) * scale
+	for y := end - 8*scale; y < end; y++ {
+		for x := end - 8*scale; x < end; x++ {
+			img.Set(x-scale/2, y, img.At(x, y))
+		}
+	}
+	res, err = DecodeImage(img)
+	if err != nil {
+		t.Fatal(err)
+	}
+	q := res[0].Quality
+	if q.Alignment >= 1 || q.Margin >= 1 || q.ECCUsed <= 0 || q.Score() >= 1 {
+		t.Errorf("Quality(damaged) = %+v, score %v, want imperfect alignment, margin, and ECC", q, q.Score())
+	}
+
+	if q := (Quality{Margin: 1, FormatErrors: 3, Alignment: 1}); q.Score() != 0.25 {
+		t.Errorf("Quality(%+v).Score() = %v, want 0.25", q, q.Score())
+	}
+}
+
+// cameraFrame returns a 640×480 gray frame showing c near its middle.
+func cameraFrame(c *Code) *image.Gray {
+	frame := image.NewGray(image.Rect(0, 0, 640, 480))
+	draw.Draw(frame, frame.Bounds(), image.NewUniform(color.Gray{0xc0}), image.Point{}, draw.Src)
+	img := c.Image(ModuleSize(5))
+	draw.Draw(frame, img.Bounds().Add(image.Pt(200, 100)), img, img.Bounds().Min, draw.Src)
+	return frame
+}
+
+func TestDecodeFrame(t *testing.T) {
+	const text = "webcam frame"
+	c, err := Encode(text, M)
+	if err != nil {
+		t.Fatal(err)
+	}
+	frame := cameraFrame(c)
+	want, err := DecodeImage(image.Image(struct{ image.Image }{frame})) // generic path
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	ycc := image.NewYCbCr(frame.Bounds(), image.YCbCrSubsampleRatio420)
+	copy(ycc.Y, frame.Pix)
+	for i := range ycc.Cb {
+		ycc.Cb[i], ycc.Cr[i] = 128, 128
+	}
+	sub := frame.SubImage(image.Rect(100, 50, 600, 450)).(*image.Gray)
+	images := map[string]image.Image{"gray": frame, "ycbcr": ycc, "gray subimage": sub}
+	for name, img := range images {
+		res, err := DecodeImage(img)
+		if err != nil || len(res) != 1 || res[0].Text != text || res[0].Corners != want[0].Corners {
+			t.Errorf("DecodeImage(%s) = %v, %v, want corners %v", name, res, err, want[0].Corners)
+		}
+	}
+
+	// The frame in a buffer with padded rows.
+	const stride = 700
+	buf := make([]byte, 479*stride+640)
+	for y := 0; y < 480; y++ {
+		copy(buf[y*stride:], frame.Pix[y*frame.Stride:y*frame.Stride+640])
+	}
+	res, err := DecodeLuma(buf, 640, 480, stride)
+	if err != nil || len(res) != 1 || res[0].Text != text || res[0].Corners != want[0].Corners {
+		t.Errorf("DecodeLuma = %v, %v, want corners %v", res, err, want[0].Corners)
+	}
+	if _, err := DecodeLuma(buf, 640, 481, stride); err == nil {
+		t.Errorf("DecodeLuma(short buffer) succeeded")
+	}
+	if _, err := DecodeLuma(buf, 640, 480, 600); err == nil {
+		t.Errorf("DecodeLuma(stride < width) succeeded")
+	}
+}
+
+func BenchmarkDecodeFrame(b *testing.B) {
+	c, err := Encode("webcam frame", M)
+	if err != nil {
+		b.Fatal(err)
+	}
+	frame := cameraFrame(c)
+	b.Run("generic", func(b *testing.B) {
+		img := image.Image(struct{ image.Image }{frame})
+		for i := 0; i < b.N; i++ {
+			DecodeImage(img)
+		}
+	})
+	b.Run("gray", func(b *testing.B) {
+		for i := 0; i < b.N; i++ {
+			DecodeImage(frame)
+		}
+	})
+}
+
+func TestDecodeDamaged(t *testing.T) {
+	c, err := Encode("beyond repair", L)
+	if err != nil {
+		t.Fatal(err)
+	}
+	c = c.Clone()
+	// Scramble the bottom half, below the timing pattern row.
+	for y := c.Size / 2; y < c.Size-8; y++ {
+		for i := 0; i < c.Stride; i++ {
+			c.Bitmap[y*c.Stride+i] ^= 0x5a
+		}
+	}
+	_, err = Decode(c)
+	var e *coding.ErrDamagedBlocks
+	if !errors.As(err, &e) || len(e.Failed) == 0 {
+		t.Errorf("Decode(scrambled) = %v, want *coding.ErrDamagedBlocks", err)
+	}
+}
+
+func TestDecodeLimits(t *testing.T) {
+	dst := image.NewGray(image.Rect(0, 0, 400, 200))
+	draw.Draw(dst, dst.Bounds(), image.White, image.Point{}, draw.Src)
+	for i, text := range []string{"left", "right"} {
+		c, err := Encode(text, M)
+		if err != nil {
+			t.Fatal(err)
+		}
+		c.DrawTo(dst, image.Pt(10+200*i, 10), ModuleSize(4))
+	}
+	if list, err := DecodeImage(dst); err != nil || len(list) != 2 {
+		t.Fatalf("DecodeImage = %d codes, %v, want 2 codes", len(list), err)
+	}
+
+	if list, err := DecodeImage(dst, MaxCodes(1)); err != nil || len(list) != 1 {
+		t.Errorf("DecodeImage(MaxCodes(1)) = %d codes, %v, want 1 code", len(list), err)
+	}
+	if list, err := DecodeImage(dst, MaxCandidates(1)); len(list) > 1 {
+		t.Errorf("DecodeImage(MaxCandidates(1)) = %d codes, %v, want at most 1", len(list), err)
+	}
+	if _, err := DecodeImage(dst, Deadline(time.Now().Add(-time.Second))); !errors.Is(err, context.DeadlineExceeded) {
+		t.Errorf("DecodeImage(past deadline) = %v, want DeadlineExceeded", err)
+	}
+	if list, err := DecodeImage(dst, Deadline(time.Now().Add(time.Minute))); err != nil || len(list) != 2 {
+		t.Errorf("DecodeImage(future deadline) = %d codes, %v, want 2 codes", len(list), err)
+	}
+
+	big := MaxImageSize(300, 300)
+	if _, err := DecodeImage(dst, big); !errors.Is(err, ErrImageTooLarge) {
+		t.Errorf("DecodeImage(400×200, MaxImageSize(300, 300)) = %v, want ErrImageTooLarge", err)
+	}
+	if _, err := DecodeLuma(dst.Pix, 400, 200, dst.Stride, big); !errors.Is(err, ErrImageTooLarge) {
+		t.Errorf("DecodeLuma(400×200, MaxImageSize(300, 300)) = %v, want ErrImageTooLarge", err)
+	}
+	var buf bytes.Buffer
+	if err := png.Encode(&buf, dst); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := DecodeReader(bytes.NewReader(buf.Bytes()), big); !errors.Is(err, ErrImageTooLarge) {
+		t.Errorf("DecodeReader(PNG 400×200, MaxImageSize(300, 300)) = %v, want ErrImageTooLarge", err)
+	}
+	if list, err := DecodeReader(bytes.NewReader(buf.Bytes()), MaxImageSize(400, 200)); err != nil || len(list) != 2 {
+		t.Errorf("DecodeReader(PNG 400×200, MaxImageSize(400, 200)) = %d codes, %v, want 2 codes", len(list), err)
+	}
+	// A netpbm header claiming a huge image is rejected before
+	// the pixels are read.
+	if _, err := DecodeReader(strings.NewReader("P5\n60000 60000\n255\n"), big); !errors.Is(err, ErrImageTooLarge) {
+		t.Errorf("DecodeReader(PGM 60000×60000, MaxImageSize(300, 300)) = %v, want ErrImageTooLarge", err)
+	}
+}
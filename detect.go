@@ -0,0 +1,665 @@
+// Copyright 2011 The Go Authors.  All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package qr
+
+// Locating and sampling QR codes in images.
+
+import (
+	"image"
+	"image/color"
+	"math"
+	"sort"
+
+	"github.com/inkstray/rsc-qr/coding"
+)
+
+// A binImage is a black and white image.
+type binImage struct {
+	w, h int
+	pix  []byte      // 1 for black, 0 for white
+	min  image.Point // image coordinates of pixel (0, 0)
+}
+
+// color returns 1 if (x, y) is black, 0 if white,
+// and -1 if it is outside the image.
+func (b *binImage) color(x, y int) int {
+	if x < 0 || y < 0 || x >= b.w || y >= b.h {
+		return -1
+	}
+	return int(b.pix[y*b.w+x])
+}
+
+// invert swaps black and white in b.
+func (b *binImage) invert() {
+	for i, c := range b.pix {
+		b.pix[i] = 1 - c
+	}
+}
+
+// binarize converts img to black and white.
+// See binarizeGray.
+func binarize(img image.Image) *binImage {
+	bounds := img.Bounds()
+	w, h := bounds.Dx(), bounds.Dy()
+	switch img := img.(type) {
+	case *image.Gray:
+		// Use the pixels in place, with no color conversion.
+		return binarizeGray(img.Pix[img.PixOffset(bounds.Min.X, bounds.Min.Y):], w, h, img.Stride, bounds.Min)
+	case *image.YCbCr:
+		// The Y plane is the gray image.
+		return binarizeGray(img.Y[img.YOffset(bounds.Min.X, bounds.Min.Y):], w, h, img.YStride, bounds.Min)
+	}
+	gray := make([]uint8, w*h)
+	for y := 0; y < h; y++ {
+		for x := 0; x < w; x++ {
+			gray[y*w+x] = color.GrayModel.Convert(img.At(bounds.Min.X+x, bounds.Min.Y+y)).(color.Gray).Y
+		}
+	}
+	return binarizeGray(gray, w, h, w, bounds.Min)
+}
+
+// binarizeGray converts the w×h gray image with the given row
+// stride in gray to black and white.  The image has coordinates min
+// at its top left corner.  Each pixel is compared against the mean of
+// its neighborhood, so that shadows and uneven lighting do not hide
+// the code.  In flat neighborhoods, which have no edges to go by,
+// pixels are compared against a global threshold.
+func binarizeGray(gray []uint8, w, h, stride int, min image.Point) *binImage {
+	var hist [256]int
+	for y := 0; y < h; y++ {
+		for _, g := range gray[y*stride : y*stride+w] {
+			hist[g]++
+		}
+	}
+	global := otsu(&hist, w*h)
+
+	// Integral images of the gray levels and their squares.
+	sum := make([]int64, (w+1)*(h+1))
+	sq := make([]int64, (w+1)*(h+1))
+	for y := 0; y < h; y++ {
+		var rs, rq int64
+		for x := 0; x < w; x++ {
+			g := int64(gray[y*stride+x])
+			rs += g
+			rq += g * g
+			i := (y+1)*(w+1) + x + 1
+			sum[i] = sum[i-w-1] + rs
+			sq[i] = sq[i-w-1] + rq
+		}
+	}
+
+	r := w
+	if h < r {
+		r = h
+	}
+	r /= 16
+	if r < 8 {
+		r = 8
+	}
+	const minDev = 16 // smallest standard deviation of a region with edges
+	b := &binImage{w: w, h: h, pix: make([]byte, w*h), min: min}
+	for y := 0; y < h; y++ {
+		y0, y1 := clampInt(y-r, 0, h), clampInt(y+r+1, 0, h)
+		for x := 0; x < w; x++ {
+			x0, x1 := clampInt(x-r, 0, w), clampInt(x+r+1, 0, w)
+			n := int64((x1 - x0) * (y1 - y0))
+			s := sum[y1*(w+1)+x1] - sum[y0*(w+1)+x1] - sum[y1*(w+1)+x0] + sum[y0*(w+1)+x0]
+			q := sq[y1*(w+1)+x1] - sq[y0*(w+1)+x1] - sq[y1*(w+1)+x0] + sq[y0*(w+1)+x0]
+			g := int64(gray[y*stride+x])
+			black := g < int64(global)
+			if q*n-s*s >= minDev*minDev*n*n {
+				black = g*n < s
+			}
+			if black {
+				b.pix[y*w+x] = 1
+			}
+		}
+	}
+	return b
+}
+
+// otsu returns the gray level that best separates the n pixels
+// counted in hist into two classes, by Otsu's method.
+func otsu(hist *[256]int, n int) uint8 {
+	var total float64
+	for i, c := range hist {
+		total += float64(i * c)
+	}
+	var sumB, wB float64
+	best, t := -1.0, 128
+	for i, c := range hist {
+		wB += float64(c)
+		if wB == 0 {
+			continue
+		}
+		wF := float64(n) - wB
+		if wF == 0 {
+			break
+		}
+		sumB += float64(i * c)
+		mB, mF := sumB/wB, (total-sumB)/wF
+		if v := wB * wF * (mB - mF) * (mB - mF); v > best {
+			best, t = v, i+1
+		}
+	}
+	return uint8(clampInt(t, 0, 255))
+}
+
+// runs measures the five runs of alternating color along the line
+// through the black pixel (x, y) in direction (dx, dy): the black run
+// holding (x, y) and the white, black runs on either side of it.
+// The outer runs are cut off after max pixels.
+// It also returns the offset along the line, in pixels, from the
+// center of (x, y) to the center of the middle run.
+func (b *binImage) runs(x, y, dx, dy, max int) (r [5]int, center float64, ok bool) {
+	at := func(k int) int { return b.color(x+k*dx, y+k*dy) }
+	if at(0) != 1 {
+		return r, 0, false
+	}
+	lo, hi := 0, 0
+	for at(lo-1) == 1 {
+		lo--
+	}
+	for at(hi+1) == 1 {
+		hi++
+	}
+	r[2] = hi - lo + 1
+	k := lo
+	for i, want := range []int{0, 1} {
+		for at(k-1) == want && r[1-i] <= max {
+			k--
+			r[1-i]++
+		}
+		if r[1-i] == 0 {
+			return r, 0, false
+		}
+	}
+	k = hi
+	for i, want := range []int{0, 1} {
+		for at(k+1) == want && r[3+i] <= max {
+			k++
+			r[3+i]++
+		}
+		if r[3+i] == 0 {
+			return r, 0, false
+		}
+	}
+	return r, float64(lo+hi) / 2, true
+}
+
+// finderRatio reports whether r has the 1:1:3:1:1 proportions of a
+// finder pattern, and returns the estimated module size.
+func finderRatio(r [5]int) (float64, bool) {
+	total := 0
+	for _, n := range r {
+		total += n
+	}
+	if total < 7 {
+		return 0, false
+	}
+	m := float64(total) / 7
+	v := m / 2
+	return m, math.Abs(m-float64(r[0])) < v && math.Abs(m-float64(r[1])) < v &&
+		math.Abs(3*m-float64(r[2])) < 3*v &&
+		math.Abs(m-float64(r[3])) < v && math.Abs(m-float64(r[4])) < v
+}
+
+// A FinderPattern is a candidate for one of the three position
+// patterns, the nested squares in the corners of a code.
+type FinderPattern struct {
+	X, Y   float64 // center, in image coordinates
+	Module float64 // estimated module size, in pixels
+	Count  int     // number of scan lines that found it
+}
+
+// FindFinderPatterns locates candidate finder patterns in img,
+// scanning each row for the 1:1:3:1:1 black-white-black-white-black
+// runs through the center of a pattern and checking each one along
+// the column and row through its center.  It returns the candidates
+// confirmed by the most scan lines first.  DecodeImage groups
+// candidates into threes to find codes.
+//
+// The module size of a pattern in a rotated code is measured
+// along the image rows and columns, so it is larger than the
+// distance between modules by up to a factor of √2.
+func FindFinderPatterns(img image.Image) []FinderPattern {
+	list, _ := findFinders(binarize(img), nil)
+	min := img.Bounds().Min
+	for i := range list {
+		list[i].X += float64(min.X)
+		list[i].Y += float64(min.Y)
+	}
+	return list
+}
+
+// findFinders is FindFinderPatterns for a binarized image,
+// with coordinates relative to its top left corner.
+// It gives up if the deadline of d passes.
+func findFinders(b *binImage, d *decoder) ([]FinderPattern, error) {
+	var found []FinderPattern
+	var runs []int
+	for y := 0; y < b.h; y++ {
+		if d.expired() {
+			return nil, errDeadline
+		}
+		// Run lengths of the row, starting with black.
+		runs = runs[:0]
+		start := 0
+		for start < b.w && b.pix[y*b.w+start] == 0 {
+			start++
+		}
+		for x := start; x < b.w; {
+			c := b.pix[y*b.w+x]
+			n := 0
+			for x < b.w && b.pix[y*b.w+x] == c {
+				x++
+				n++
+			}
+			runs = append(runs, n)
+		}
+
+		x := start
+		for i := 0; i+5 <= len(runs); i += 2 {
+			var r [5]int
+			copy(r[:], runs[i:])
+			if _, ok := finderRatio(r); ok {
+				cx := x + r[0] + r[1] + r[2]/2
+				if f, ok := b.checkFinder(cx, y); ok {
+					found = addFinder(found, f)
+				}
+			}
+			x += runs[i]
+			if i+1 < len(runs) {
+				x += runs[i+1]
+			}
+		}
+	}
+	sort.SliceStable(found, func(i, j int) bool { return found[i].Count > found[j].Count })
+	return found, nil
+}
+
+// checkFinder checks for a finder pattern centered near (x, y),
+// measuring it vertically and then horizontally.
+func (b *binImage) checkFinder(x, y int) (FinderPattern, bool) {
+	max := b.w
+	if b.h > max {
+		max = b.h
+	}
+	rv, cy, ok := b.runs(x, y, 0, 1, max)
+	if !ok {
+		return FinderPattern{}, false
+	}
+	mv, ok := finderRatio(rv)
+	if !ok {
+		return FinderPattern{}, false
+	}
+	fy := float64(y) + cy + 0.5
+	rh, cx, ok := b.runs(x, int(fy), 1, 0, max)
+	if !ok {
+		return FinderPattern{}, false
+	}
+	mh, ok := finderRatio(rh)
+	if !ok || mh > 2*mv || mv > 2*mh {
+		return FinderPattern{}, false
+	}
+	return FinderPattern{X: float64(x) + cx + 0.5, Y: fy, Module: (mh + mv) / 2, Count: 1}, true
+}
+
+// addFinder adds f to list, merging it with a nearby candidate
+// of about the same size, if there is one.
+func addFinder(list []FinderPattern, f FinderPattern) []FinderPattern {
+	for i := range list {
+		g := &list[i]
+		if math.Abs(g.X-f.X) <= g.Module && math.Abs(g.Y-f.Y) <= g.Module &&
+			math.Abs(g.Module-f.Module) <= math.Max(1, g.Module/2) {
+			n := float64(g.Count)
+			g.X = (g.X*n + f.X) / (n + 1)
+			g.Y = (g.Y*n + f.Y) / (n + 1)
+			g.Module = (g.Module*n + f.Module) / (n + 1)
+			g.Count++
+			return list
+		}
+	}
+	return append(list, f)
+}
+
+// A triple is three finder patterns that may belong to one code:
+// the top left, top right, and bottom left patterns.
+type triple struct {
+	tl, tr, bl FinderPattern
+	score      float64 // lower is more likely
+}
+
+// maxFinders is the number of the best finder candidates
+// considered for triples, enough for a dozen codes.
+const maxFinders = 36
+
+// triples returns the combinations of finders that are arranged
+// like the finder patterns of a code, most likely first.
+func triples(finders []FinderPattern) []triple {
+	// Prefer candidates confirmed by more than one scan line.
+	var good []FinderPattern
+	for _, f := range finders {
+		if f.Count >= 2 {
+			good = append(good, f)
+		}
+	}
+	if len(good) < 3 {
+		good = finders
+	}
+	if len(good) > maxFinders {
+		good = good[:maxFinders]
+	}
+
+	var list []triple
+	for i := range good {
+		for j := i + 1; j < len(good); j++ {
+			for k := j + 1; k < len(good); k++ {
+				if t, ok := makeTriple(good[i], good[j], good[k]); ok {
+					list = append(list, t)
+				}
+			}
+		}
+	}
+	sort.SliceStable(list, func(i, j int) bool { return list[i].score < list[j].score })
+	return list
+}
+
+func dist2(a, b FinderPattern) float64 {
+	dx, dy := a.X-b.X, a.Y-b.Y
+	return dx*dx + dy*dy
+}
+
+// makeTriple orders a, b, and c as the finders of a code,
+// and reports whether they are shaped like one.
+func makeTriple(a, b, c FinderPattern) (triple, bool) {
+	// The longest side joins the top right and bottom left.
+	ab, bc, ca := dist2(a, b), dist2(b, c), dist2(c, a)
+	t := triple{tl: c, tr: a, bl: b}
+	hyp := ab
+	if bc > hyp {
+		t = triple{tl: a, tr: b, bl: c}
+		hyp = bc
+	}
+	if ca > hyp {
+		t = triple{tl: b, tr: c, bl: a}
+		hyp = ca
+	}
+	// In image coordinates, with y down, the top right finder is
+	// clockwise from the bottom left one.
+	if (t.tr.X-t.tl.X)*(t.bl.Y-t.tl.Y)-(t.tr.Y-t.tl.Y)*(t.bl.X-t.tl.X) < 0 {
+		t.tr, t.bl = t.bl, t.tr
+	}
+
+	m := (t.tl.Module + t.tr.Module + t.bl.Module) / 3
+	for _, f := range []FinderPattern{t.tl, t.tr, t.bl} {
+		if f.Module < m/1.5 || f.Module > m*1.5 {
+			return t, false
+		}
+	}
+	d1, d2 := math.Sqrt(dist2(t.tl, t.tr)), math.Sqrt(dist2(t.tl, t.bl))
+	legs := math.Abs(d1-d2) / math.Max(d1, d2)
+	right := math.Abs(hyp-d1*d1-d2*d2) / hyp
+	if legs > 0.25 || right > 0.3 {
+		return t, false
+	}
+	if n := (d1+d2)/(2*m) + 7; n < 21-4 || n > 177+4 {
+		return t, false
+	}
+	t.score = legs + right
+	return t, true
+}
+
+// A perspective is a projective transformation of the plane.
+// It maps (x, y) to ((a x + b y + c)/(g x + h y + i), (d x + e y + f)/(g x + h y + i)).
+type perspective [9]float64
+
+// squareToQuad returns the transformation mapping the unit square
+// corners (0,0), (1,0), (1,1), (0,1) to the points of q, in order.
+func squareToQuad(q [4][2]float64) perspective {
+	x0, y0, x1, y1 := q[0][0], q[0][1], q[1][0], q[1][1]
+	x2, y2, x3, y3 := q[2][0], q[2][1], q[3][0], q[3][1]
+	dx3, dy3 := x0-x1+x2-x3, y0-y1+y2-y3
+	if dx3 == 0 && dy3 == 0 {
+		return perspective{x1 - x0, x3 - x0, x0, y1 - y0, y3 - y0, y0, 0, 0, 1}
+	}
+	dx1, dx2, dy1, dy2 := x1-x2, x3-x2, y1-y2, y3-y2
+	den := dx1*dy2 - dx2*dy1
+	g := (dx3*dy2 - dx2*dy3) / den
+	h := (dx1*dy3 - dx3*dy1) / den
+	return perspective{
+		x1 - x0 + g*x1, x3 - x0 + h*x3, x0,
+		y1 - y0 + g*y1, y3 - y0 + h*y3, y0,
+		g, h, 1,
+	}
+}
+
+// adjoint returns the adjoint of p, which is the inverse
+// transformation.
+func (p perspective) adjoint() perspective {
+	a, b, c, d, e, f, g, h, i := p[0], p[1], p[2], p[3], p[4], p[5], p[6], p[7], p[8]
+	return perspective{
+		e*i - f*h, c*h - b*i, b*f - c*e,
+		f*g - d*i, a*i - c*g, c*d - a*f,
+		d*h - e*g, b*g - a*h, a*e - b*d,
+	}
+}
+
+// then returns the transformation applying p and then q.
+func (p perspective) then(q perspective) perspective {
+	var r perspective
+	for i := 0; i < 3; i++ {
+		for j := 0; j < 3; j++ {
+			for k := 0; k < 3; k++ {
+				r[3*i+j] += q[3*i+k] * p[3*k+j]
+			}
+		}
+	}
+	return r
+}
+
+// apply returns the image of (x, y) under p.
+func (p perspective) apply(x, y float64) (float64, float64) {
+	w := p[6]*x + p[7]*y + p[8]
+	return (p[0]*x + p[1]*y + p[2]) / w, (p[3]*x + p[4]*y + p[5]) / w
+}
+
+// quadToQuad returns the transformation mapping the points of src
+// to those of dst.
+func quadToQuad(src, dst [4][2]float64) perspective {
+	return squareToQuad(src).adjoint().then(squareToQuad(dst))
+}
+
+// decode samples and decodes the code whose finders are t.
+// It tries the version estimated from the finder spacing
+// and the versions on either side of it.
+func (b *binImage) decode(t triple) (*Result, error) {
+	m := (t.tl.Module + t.tr.Module + t.bl.Module) / 3
+	d1, d2 := math.Sqrt(dist2(t.tl, t.tr)), math.Sqrt(dist2(t.tl, t.bl))
+
+	// The finder module sizes were measured along rows and columns,
+	// which cross a tilted code at an angle and so see longer runs.
+	a := math.Mod(math.Atan2(t.tr.Y-t.tl.Y, t.tr.X-t.tl.X)+2*math.Pi, math.Pi/2)
+	if a > math.Pi/4 {
+		a = math.Pi/2 - a
+	}
+	v := int(math.Floor(((d1+d2)/(2*m*math.Cos(a))+7-17)/4 + 0.5))
+	var lastErr error
+	for _, dv := range []int{0, -1, 1} {
+		vv := v + dv
+		if vv < 1 || vv > 40 {
+			continue
+		}
+		c, p := b.sample(t, 17+4*vv, m)
+		res, err := decode(c)
+		if err == nil {
+			n := float64(c.Size)
+			for i, pt := range [4][2]float64{{0, 0}, {n, 0}, {n, n}, {0, n}} {
+				x, y := p.apply(pt[0], pt[1])
+				res.Corners[i] = image.Pt(int(math.Round(x)), int(math.Round(y))).Add(b.min)
+			}
+			res.QuietZone = b.quietZone(p, c.Size)
+			res.Quality.Margin = b.margin(p, c)
+			res.Quality.Alignment = b.alignment(p, vv, m)
+			if res.Mirrored {
+				res.Corners[1], res.Corners[3] = res.Corners[3], res.Corners[1]
+			}
+			top := res.Corners[1].Sub(res.Corners[0])
+			deg := math.Atan2(float64(top.Y), float64(top.X)) * 180 / math.Pi
+			res.Rotation = (int(math.Round(deg/90))*90 + 360) % 360
+			return res, nil
+		}
+		if lastErr == nil {
+			lastErr = err
+		}
+	}
+	if lastErr == nil {
+		lastErr = ErrNotFound
+	}
+	return nil, lastErr
+}
+
+// quietZone returns the number of rings of light modules, up to 4,
+// around the size×size code that p maps into the image.
+// Modules outside the image count as light.
+func (b *binImage) quietZone(p perspective, size int) int {
+	for k := 1; k <= 4; k++ {
+		lo, hi := -k, size+k-1
+		for i := lo; i <= hi; i++ {
+			for _, m := range [4][2]int{{i, lo}, {i, hi}, {lo, i}, {hi, i}} {
+				x, y := p.apply(float64(m[0])+0.5, float64(m[1])+0.5)
+				if b.color(int(math.Floor(x)), int(math.Floor(y))) == 1 {
+					return k - 1
+				}
+			}
+		}
+	}
+	return 4
+}
+
+// margin returns the fraction of points around the centers of the
+// modules of c, which p maps into the image, that have the same color
+// as the centers, averaged over the modules.
+func (b *binImage) margin(p perspective, c *coding.Code) float64 {
+	const d = 0.3 // offset of the points, in modules
+	agree, total := 0, 0
+	for y := 0; y < c.Size; y++ {
+		for x := 0; x < c.Size; x++ {
+			want := 0
+			if c.Black(x, y) {
+				want = 1
+			}
+			for _, o := range [8][2]float64{{-d, -d}, {0, -d}, {d, -d}, {-d, 0}, {d, 0}, {-d, d}, {0, d}, {d, d}} {
+				ix, iy := p.apply(float64(x)+0.5+o[0], float64(y)+0.5+o[1])
+				if b.color(int(math.Floor(ix)), int(math.Floor(iy))) == want {
+					agree++
+				}
+				total++
+			}
+		}
+	}
+	return float64(agree) / float64(total)
+}
+
+// alignment returns the fraction of the alignment patterns of a
+// version v code, which p maps into the image, that are found within
+// two modules of where p puts them.  The modules are about m pixels.
+func (b *binImage) alignment(p perspective, v int, m float64) float64 {
+	pix, err := coding.PixelMap(coding.Version(v), coding.L)
+	if err != nil {
+		return 0
+	}
+	found, total := 0, 0
+	for y, row := range pix {
+		for x, px := range row {
+			// Visit each pattern from its top left pixel.
+			if px.Role() != coding.Alignment || x > 0 && row[x-1].Role() == coding.Alignment ||
+				y > 0 && pix[y-1][x].Role() == coding.Alignment {
+				continue
+			}
+			total++
+			ix, iy := p.apply(float64(x)+2.5, float64(y)+2.5)
+			if ax, ay, ok := b.findAlignment(ix, iy, m, 2*m); ok && math.Hypot(ax-ix, ay-iy) <= 2*m {
+				found++
+			}
+		}
+	}
+	if total == 0 {
+		return 1
+	}
+	return float64(found) / float64(total)
+}
+
+// sample reads the size×size pixels of the code whose finders are t
+// and whose modules are about m image pixels wide.  It also returns
+// the transformation from module coordinates to pixel coordinates.
+func (b *binImage) sample(t triple, size int, m float64) (*coding.Code, perspective) {
+	n := float64(size)
+	src := [4][2]float64{{3.5, 3.5}, {n - 3.5, 3.5}, {n - 3.5, n - 3.5}, {3.5, n - 3.5}}
+	dst := [4][2]float64{
+		{t.tl.X, t.tl.Y},
+		{t.tr.X, t.tr.Y},
+		{t.tr.X + t.bl.X - t.tl.X, t.tr.Y + t.bl.Y - t.tl.Y},
+		{t.bl.X, t.bl.Y},
+	}
+	p := quadToQuad(src, dst)
+	if size > 21 {
+		// Refine the bottom right corner using the alignment
+		// pattern nearest to it, looking farther afield if needed,
+		// since perspective moves it away from the estimate.
+		ax, ay := p.apply(n-6.5, n-6.5)
+		for _, r := range []float64{4, 8, 16} {
+			if x, y, ok := b.findAlignment(ax, ay, m, r*m); ok {
+				src[2] = [2]float64{n - 6.5, n - 6.5}
+				dst[2] = [2]float64{x, y}
+				p = quadToQuad(src, dst)
+				break
+			}
+		}
+	}
+
+	c := &coding.Code{Size: size, Stride: (size + 7) / 8}
+	c.Bitmap = make([]byte, c.Stride*size)
+	for y := 0; y < size; y++ {
+		for x := 0; x < size; x++ {
+			ix, iy := p.apply(float64(x)+0.5, float64(y)+0.5)
+			if b.color(int(math.Floor(ix)), int(math.Floor(iy))) == 1 {
+				c.Bitmap[y*c.Stride+x/8] |= 0x80 >> uint(x&7)
+			}
+		}
+	}
+	return c, p
+}
+
+// findAlignment looks for an alignment pattern with modules about
+// m pixels wide within dist pixels of (x, y), and returns the center
+// of the one nearest to (x, y).
+func (b *binImage) findAlignment(x, y, m, dist float64) (float64, float64, bool) {
+	near := func(n int) bool { return math.Abs(float64(n)-m) <= math.Max(1, m/2) }
+	r := int(dist) + 2
+	best, bx, by := math.Inf(1), 0.0, 0.0
+	for yy := int(y) - r; yy <= int(y)+r; yy++ {
+		for xx := int(x) - r; xx <= int(x)+r; xx++ {
+			// Consider each black run once, from its left end.
+			if b.color(xx, yy) != 1 || b.color(xx-1, yy) == 1 {
+				continue
+			}
+			rh, cx, ok := b.runs(xx, yy, 1, 0, int(2*m)+1)
+			if !ok || !near(rh[1]) || !near(rh[2]) || !near(rh[3]) {
+				continue
+			}
+			px := float64(xx) + cx + 0.5
+			rv, cy, ok := b.runs(int(px), yy, 0, 1, int(2*m)+1)
+			if !ok || !near(rv[1]) || !near(rv[2]) || !near(rv[3]) {
+				continue
+			}
+			py := float64(yy) + cy + 0.5
+			if d := (px-x)*(px-x) + (py-y)*(py-y); d < best {
+				best, bx, by = d, px, py
+			}
+		}
+	}
+	return bx, by, !math.IsInf(best, 1)
+}
@@ -0,0 +1,46 @@
+// Copyright 2011 The Go Authors.  All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package qr
+
+import (
+	"image"
+	"image/draw"
+	"math"
+	"testing"
+)
+
+func TestFindFinderPatterns(t *testing.T) {
+	c, err := Encode("finder patterns", M)
+	if err != nil {
+		t.Fatal(err)
+	}
+	const scale = 5
+	dst := image.NewRGBA(image.Rect(-20, -10, 300, 300))
+	draw.Draw(dst, dst.Bounds(), image.White, image.Point{}, draw.Src)
+	c.DrawTo(dst, image.Pt(7, 3), ModuleSize(scale))
+
+	list := FindFinderPatterns(dst)
+	if len(list) < 3 {
+		t.Fatalf("FindFinderPatterns found %d patterns, want 3: %v", len(list), list)
+	}
+	// The code starts after a 4-pixel quiet zone.
+	far := float64(c.Size) - 3.5
+	want := [][2]float64{{3.5, 3.5}, {far, 3.5}, {3.5, far}}
+	for _, w := range want {
+		x, y := 7+(4+w[0])*scale, 3+(4+w[1])*scale
+		found := false
+		for _, f := range list[:3] {
+			if math.Abs(f.X-x) <= 1 && math.Abs(f.Y-y) <= 1 {
+				found = true
+				if math.Abs(f.Module-scale) > 0.5 || f.Count < scale {
+					t.Errorf("pattern at (%v, %v) has module %v, count %d, want %v, ≥%d", x, y, f.Module, f.Count, scale, scale)
+				}
+			}
+		}
+		if !found {
+			t.Errorf("no pattern at (%v, %v): %v", x, y, list)
+		}
+	}
+}
@@ -0,0 +1,542 @@
+// Copyright 2011 The Go Authors.  All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package qr
+
+import (
+	"context"
+	"encoding"
+	"fmt"
+	"strings"
+	"sync/atomic"
+	"unicode/utf8"
+
+	"github.com/inkstray/rsc-qr/coding"
+)
+
+// A Segmentation selects how an Encoder splits text into segments
+// encoded in different modes.
+type Segmentation int
+
+const (
+	// SegmentOptimal uses the mix of numeric, alphanumeric, kanji,
+	// and 8-bit segments that gives the shortest encoding.
+	SegmentOptimal Segmentation = iota
+
+	// SegmentSplit uses the faster heuristic of coding.Split.
+	// The heuristic may change between releases.
+	SegmentSplit
+
+	// SegmentByte encodes all text as a single 8-bit segment.
+	SegmentByte
+)
+
+// An Encoder encodes text as QR codes using a fixed set of options.
+// The zero Encoder is not valid; use NewEncoder.
+// An Encoder is safe for concurrent use by multiple goroutines.
+type Encoder struct {
+	level      Level
+	minVersion coding.Version
+	maxVersion coding.Version
+	mask       coding.Mask // -1 to choose the best mask
+	seg        Segmentation
+	boost      bool
+	downgrade  bool
+	fold       bool
+	scale      int
+	quiet      int
+	stable     bool
+	newlines   Newlines
+	eci        bool
+	noEmpty    bool
+}
+
+// A Newlines selects how an Encoder handles line breaks in text.
+type Newlines int
+
+const (
+	// NewlinesKeep encodes line breaks as they appear in the text.
+	NewlinesKeep Newlines = iota
+
+	// NewlinesCRLF converts each line break (CR, LF, or CR LF)
+	// to CR LF, as required by payloads such as vCard and iCalendar.
+	NewlinesCRLF
+
+	// NewlinesLF converts each line break (CR, LF, or CR LF) to LF.
+	NewlinesLF
+
+	// NewlinesReject rejects text containing CR or LF.
+	NewlinesReject
+)
+
+// apply returns text with line breaks handled according to n.
+func (n Newlines) apply(text string) (string, error) {
+	i := strings.IndexAny(text, "\r\n")
+	if i < 0 || n == NewlinesKeep {
+		return text, nil
+	}
+	if n == NewlinesReject {
+		return "", fmt.Errorf("line break %q at offset %d", text[i], i)
+	}
+	eol := "\n"
+	if n == NewlinesCRLF {
+		eol = "\r\n"
+	}
+	var b strings.Builder
+	b.Grow(len(text) + len(text)/8)
+	for ; i >= 0; i = strings.IndexAny(text, "\r\n") {
+		b.WriteString(text[:i])
+		b.WriteString(eol)
+		if strings.HasPrefix(text[i:], "\r\n") {
+			i++
+		}
+		text = text[i+1:]
+	}
+	b.WriteString(text)
+	return b.String(), nil
+}
+
+// An Option configures an Encoder.
+type Option func(*Encoder)
+
+// NewEncoder returns an Encoder with the given options.
+// By default, an Encoder uses level L, the smallest version
+// that holds the text, the best mask, optimal segmentation,
+// and a scale of 8 image pixels per QR pixel.
+// NewEncoder returns an error if the options are invalid,
+// such as an unknown level or an empty version range.
+func NewEncoder(opts ...Option) (*Encoder, error) {
+	e := &Encoder{
+		level:      L,
+		minVersion: coding.MinVersion,
+		maxVersion: coding.MaxVersion,
+		mask:       -1,
+		scale:      8,
+	}
+	for _, opt := range opts {
+		opt(e)
+	}
+	if err := e.check(); err != nil {
+		return nil, err
+	}
+	return e, nil
+}
+
+// check reports whether e's options are valid.
+func (e *Encoder) check() error {
+	if e.level < L || e.level > H {
+		return fmt.Errorf("%w %d", ErrBadLevel, int(e.level))
+	}
+	if e.minVersion < coding.MinVersion || e.maxVersion > coding.MaxVersion || e.minVersion > e.maxVersion {
+		return fmt.Errorf("%w range %d to %d", ErrBadVersion, int(e.minVersion), int(e.maxVersion))
+	}
+	if e.mask < -1 || e.mask > 7 {
+		return fmt.Errorf("%w %d", ErrBadMask, int(e.mask))
+	}
+	if e.seg < SegmentOptimal || e.seg > SegmentByte {
+		return fmt.Errorf("invalid QR segmentation %d", int(e.seg))
+	}
+	if e.scale < 1 {
+		return fmt.Errorf("invalid QR scale %d", e.scale)
+	}
+	if e.quiet < 0 {
+		return fmt.Errorf("invalid QR quiet zone %d", e.quiet)
+	}
+	if e.newlines < NewlinesKeep || e.newlines > NewlinesReject {
+		return fmt.Errorf("invalid newline policy %d", int(e.newlines))
+	}
+	return nil
+}
+
+// defaultEncoder holds the *Encoder used by Must.
+var defaultEncoder atomic.Value
+
+func init() {
+	e, _ := NewEncoder()
+	defaultEncoder.Store(e)
+}
+
+// DefaultEncoder returns the Encoder used by Must.
+// Initially it uses the default options described at NewEncoder.
+func DefaultEncoder() *Encoder {
+	return defaultEncoder.Load().(*Encoder)
+}
+
+// SetDefaultEncoder sets the Encoder used by Must.
+// Applications typically call it once during initialization,
+// but it is safe to call concurrently with Must.
+func SetDefaultEncoder(e *Encoder) {
+	if e == nil {
+		panic("qr: SetDefaultEncoder(nil)")
+	}
+	defaultEncoder.Store(e)
+}
+
+// Must encodes text using the default Encoder.
+// It panics if the text cannot be encoded.
+func Must(text string) *Code {
+	c, err := DefaultEncoder().Encode(text)
+	if err != nil {
+		panic(fmt.Sprintf("qr: Must(%q): %v", text, err))
+	}
+	return c
+}
+
+// WithLevel sets the error correction level.
+func WithLevel(l Level) Option {
+	return func(e *Encoder) { e.level = l }
+}
+
+// WithVersionRange limits the versions that the Encoder may use
+// to those from min to max, inclusive.
+func WithVersionRange(min, max int) Option {
+	return func(e *Encoder) {
+		e.minVersion = coding.Version(min)
+		e.maxVersion = coding.Version(max)
+	}
+}
+
+// WithMaxVersion limits the versions that the Encoder may use
+// to max and below, for codes that must fit in a fixed space.
+// Text that does not fit fails with an *ErrDataTooLong
+// instead of producing a larger code.
+func WithMaxVersion(max int) Option {
+	return func(e *Encoder) { e.maxVersion = coding.Version(max) }
+}
+
+// WithMask sets the mask, from 0 to 7, applied to every code.
+// A mask of -1, the default, chooses the mask with the lowest
+// penalty for each code.  Pinning the mask keeps the output
+// identical across releases even if the mask selection
+// heuristics change.
+func WithMask(mask Mask) Option {
+	return func(e *Encoder) { e.mask = coding.Mask(mask) }
+}
+
+// WithSegmentation sets how text is split into segments.
+func WithSegmentation(s Segmentation) Option {
+	return func(e *Encoder) { e.seg = s }
+}
+
+// WithBoost sets whether to raise the error correction level
+// as far as possible without increasing the version.
+func WithBoost(boost bool) Option {
+	return func(e *Encoder) { e.boost = boost }
+}
+
+// WithDowngrade sets whether to lower the error correction level,
+// one step at a time, when the text does not fit in the allowed
+// versions at the requested level.  The level used is recorded
+// in the Level field of the returned Code.
+func WithDowngrade(downgrade bool) Option {
+	return func(e *Encoder) { e.downgrade = downgrade }
+}
+
+// WithCaseFolding sets whether to upper-case ASCII letters in text
+// when that allows the whole text to be encoded as alphanumeric data.
+// It is appropriate only for case-insensitive payloads.
+func WithCaseFolding(fold bool) Option {
+	return func(e *Encoder) { e.fold = fold }
+}
+
+// WithScale sets the number of image pixels per QR pixel
+// in the codes returned by the Encoder.
+func WithScale(scale int) Option {
+	return func(e *Encoder) { e.scale = scale }
+}
+
+// WithQuietZone sets the width, in QR pixels, of the white border
+// to include in the bitmap of each code.  By default, codes have
+// no border in the bitmap, and images of the code add a 4-pixel border.
+// See Code.QuietZone.
+func WithQuietZone(n int) Option {
+	return func(e *Encoder) { e.quiet = n }
+}
+
+// WithDeterministic sets whether the Encoder guarantees that identical
+// text and options produce identical codes in all future releases,
+// as needed for golden tests.  In deterministic mode:
+//
+//   - The mask, unless set by WithMask, is the one with the lowest
+//     penalty, with ties going to the lowest-numbered mask.
+//   - The segmentation is exactly the one selected by WithSegmentation,
+//     except that SegmentSplit, whose heuristic may change, is
+//     replaced by SegmentOptimal.  SegmentOptimal always chooses
+//     the shortest encoding, breaking ties by preferring, from the
+//     start of the text, numeric, alphanumeric, kanji, and 8-bit
+//     modes, in that order.
+//
+// Other options behave the same in either mode.
+func WithDeterministic(stable bool) Option {
+	return func(e *Encoder) { e.stable = stable }
+}
+
+// WithUTF8ECI sets whether to mark text containing non-ASCII
+// characters as UTF-8, using an ECI header.  Without the header,
+// readers must guess the character set of 8-bit data; most guess
+// UTF-8, but some assume ISO 8859-1 as the standard specifies.
+// The header costs 12 bits and is omitted for ASCII text.
+func WithUTF8ECI(eci bool) Option {
+	return func(e *Encoder) { e.eci = eci }
+}
+
+// WithRejectEmpty sets whether the Encoder rejects empty text
+// or data, returning ErrEmptyPayload.  By default, empty text is
+// encoded as the smallest allowed version holding no data segments,
+// only the terminator and padding.  Readers decode such a code
+// as empty text, but some treat it as unreadable.
+func WithRejectEmpty(reject bool) Option {
+	return func(e *Encoder) { e.noEmpty = reject }
+}
+
+// WithNewlines sets how line breaks in text are handled
+// before it is encoded.  The default is NewlinesKeep.
+// The policy applies to text, not to data passed to EncodeBytes.
+// The policy used is recorded in the Newlines field of the returned Code.
+func WithNewlines(n Newlines) Option {
+	return func(e *Encoder) { e.newlines = n }
+}
+
+// Encode returns an encoding of text.
+func (e *Encoder) Encode(text string) (*Code, error) {
+	return e.EncodeContext(context.Background(), text)
+}
+
+// EncodeContext is like Encode but stops early, returning ctx.Err(),
+// if ctx is done before the code is complete.
+func (e *Encoder) EncodeContext(ctx context.Context, text string) (*Code, error) {
+	c, _, err := e.encodeText(ctx, nil, text)
+	return c, err
+}
+
+// EncodeTo is like Encode but stores the code in dst, reusing
+// dst.Bitmap if it has enough capacity.  Encoding many texts
+// into the same Code avoids allocating a new bitmap for each.
+func (e *Encoder) EncodeTo(dst *Code, text string) error {
+	_, _, err := e.encodeText(context.Background(), dst, text)
+	return err
+}
+
+// encodeText encodes text, after applying the newline policy.
+// If dst is not nil, encodeText stores the code in dst.
+func (e *Encoder) encodeText(ctx context.Context, dst *Code, text string) (*Code, coding.Level, error) {
+	text, err := e.newlines.apply(text)
+	if err != nil {
+		return nil, coding.Level(e.level), err
+	}
+	if text == "" && e.noEmpty {
+		return nil, coding.Level(e.level), ErrEmptyPayload
+	}
+	c, l, err := e.encode(ctx, dst, e.textSegments(text))
+	if err != nil {
+		return nil, l, err
+	}
+	c.Newlines = e.newlines
+	return c, l, nil
+}
+
+// EncodeBytes returns an encoding of data as 8-bit data.
+func (e *Encoder) EncodeBytes(data []byte) (*Code, error) {
+	return e.EncodeBytesContext(context.Background(), data)
+}
+
+// EncodeBytesContext is like EncodeBytes but stops early,
+// returning ctx.Err(), if ctx is done before the code is complete.
+func (e *Encoder) EncodeBytesContext(ctx context.Context, data []byte) (*Code, error) {
+	if len(data) == 0 && e.noEmpty {
+		return nil, ErrEmptyPayload
+	}
+	c, _, err := e.encode(ctx, nil, func(int) []coding.Encoding {
+		return []coding.Encoding{coding.Bytes(data)}
+	})
+	return c, err
+}
+
+// EncodeValue returns an encoding of the text form of v.
+// If v implements encoding.TextMarshaler, EncodeValue encodes
+// the result of v.MarshalText.  Otherwise, if v implements
+// fmt.Stringer, it encodes v.String().  A string is encoded as by
+// Encode, and a []byte as by EncodeBytes.
+func (e *Encoder) EncodeValue(v any) (*Code, error) {
+	switch v := v.(type) {
+	case string:
+		return e.Encode(v)
+	case []byte:
+		return e.EncodeBytes(v)
+	case encoding.TextMarshaler:
+		text, err := v.MarshalText()
+		if err != nil {
+			return nil, fmt.Errorf("marshaling %T: %w", v, err)
+		}
+		return e.Encode(string(text))
+	case fmt.Stringer:
+		return e.Encode(v.String())
+	}
+	return nil, fmt.Errorf("cannot encode value of type %T", v)
+}
+
+// textSegments returns a function that splits text
+// into segments for a given version size class.
+func (e *Encoder) textSegments(text string) func(class int) []coding.Encoding {
+	segs := e.segments(text)
+	if !e.eci || !needsECI(text) {
+		return segs
+	}
+	return func(class int) []coding.Encoding {
+		return append([]coding.Encoding{coding.ECI(utf8ECI)}, segs(class)...)
+	}
+}
+
+// utf8ECI is the ECI designator for UTF-8.
+const utf8ECI = 26
+
+// needsECI reports whether text is UTF-8 that is not plain ASCII.
+func needsECI(text string) bool {
+	for i := 0; i < len(text); i++ {
+		if text[i] >= utf8.RuneSelf {
+			return utf8.ValidString(text)
+		}
+	}
+	return false
+}
+
+// segments is like textSegments but ignores e.eci.
+func (e *Encoder) segments(text string) func(class int) []coding.Encoding {
+	if e.fold {
+		if a, _ := coding.FoldAlpha(text); a.Check() == nil {
+			return func(int) []coding.Encoding { return []coding.Encoding{a} }
+		}
+	}
+	seg := e.seg
+	if e.stable && seg == SegmentSplit {
+		seg = SegmentOptimal
+	}
+	switch seg {
+	case SegmentSplit:
+		return func(class int) []coding.Encoding {
+			return coding.Split(text, sizeClass[class].min)
+		}
+	case SegmentByte:
+		return func(int) []coding.Encoding {
+			return []coding.Encoding{coding.String(text)}
+		}
+	}
+	sp := classify(text)
+	return func(class int) []coding.Encoding {
+		return encodings(text, split(sp, class))
+	}
+}
+
+// encode encodes the segments returned by segs for the smallest
+// version allowed by e.  It returns the code and the level used.
+// If dst is not nil, encode stores the code in dst and returns dst.
+func (e *Encoder) encode(ctx context.Context, dst *Code, segs func(class int) []coding.Encoding) (*Code, coding.Level, error) {
+	l := coding.Level(e.level)
+	for {
+		v, enc := e.version(l, segs)
+		if v != 0 {
+			return e.plan(ctx, dst, v, l, enc)
+		}
+		if !e.downgrade || l == coding.L {
+			break
+		}
+		l--
+	}
+	return nil, l, e.tooLong(segs)
+}
+
+// tooLong returns the error for segments that do not fit
+// in the largest version allowed by e.
+func (e *Encoder) tooLong(segs func(class int) []coding.Encoding) *ErrDataTooLong {
+	l := coding.Level(e.level)
+	v := e.maxVersion
+	class := 0
+	for v > sizeClass[class].max {
+		class++
+	}
+	err := &ErrDataTooLong{Capacity: v.DataBytes(l) * 8, Level: l}
+	for _, t := range segs(class) {
+		err.Bits += t.Bits(v)
+	}
+
+	// Find the smallest version that would hold the text
+	// without the version limits, lowering the level if needed.
+	all := *e
+	all.minVersion, all.maxVersion = coding.MinVersion, coding.MaxVersion
+	for ; l >= coding.L; l-- {
+		if v, _ := all.version(l, segs); v != 0 {
+			err.MinVersion, err.Level = v, l
+			break
+		}
+	}
+	return err
+}
+
+// version returns the smallest version allowed by e that holds
+// the text at level l, along with the segments for that version.
+// It returns version 0 if there is none.
+func (e *Encoder) version(l coding.Level, segs func(class int) []coding.Encoding) (coding.Version, []coding.Encoding) {
+	// Find the smallest version in each size class,
+	// splitting the text anew for each.
+	for class := range sizeClass {
+		v, max := sizeClass[class].min, sizeClass[class].max
+		if v < e.minVersion {
+			v = e.minVersion
+		}
+		if max > e.maxVersion {
+			max = e.maxVersion
+		}
+		if v > max {
+			continue
+		}
+		enc := segs(class)
+		n := 0
+		for _, t := range enc {
+			n += t.Bits(v)
+		}
+		for ; v <= max; v++ {
+			if n <= v.DataBytes(l)*8 {
+				return v, enc
+			}
+		}
+	}
+	return 0, nil
+}
+
+// plan encodes enc as a code with version v and level l,
+// raising the level first if e.boost is set.
+// If dst is not nil, plan stores the code in dst.
+func (e *Encoder) plan(ctx context.Context, dst *Code, v coding.Version, l coding.Level, enc []coding.Encoding) (*Code, coding.Level, error) {
+	n := 0
+	for _, t := range enc {
+		n += t.Bits(v)
+	}
+	for e.boost && l < coding.H && n <= v.DataBytes(l+1)*8 {
+		l++
+	}
+	if dst == nil {
+		dst = new(Code)
+	}
+
+	// Build and execute plan.
+	cc := new(coding.Code)
+	if e.quiet <= 0 {
+		cc.Bitmap = dst.Bitmap
+	}
+	var err error
+	if e.mask == -1 {
+		err = coding.AutoPlan{Version: v, Level: l}.EncodeToContext(ctx, cc, enc...)
+	} else {
+		var p *coding.Plan
+		if p, err = coding.NewPlan(v, l, e.mask); err == nil {
+			err = p.EncodeToContext(ctx, cc, enc...)
+		}
+	}
+	if err != nil {
+		return nil, l, err
+	}
+	c := &Code{Bitmap: cc.Bitmap, Size: cc.Size, Stride: cc.Stride, Scale: e.scale, Level: Level(l), Info: cc.Info}
+	*dst = *c.withQuietZone(e.quiet, dst.Bitmap)
+	return dst, l, nil
+}
@@ -0,0 +1,56 @@
+// Copyright 2011 The Go Authors.  All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package qr
+
+// EPL2 writer for QR codes.
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+)
+
+// EPL2 returns an EPL2 label printing the code at the top-left corner
+// of the label, using a GW (direct graphic write) command, for label
+// printers that do not understand ZPL.  Each QR pixel is ModuleSize
+// printer dots wide; the margin is included in the graphic.
+// If Darkness is set, the label includes a D command setting it.
+// Colors are ignored.
+//
+// The GW data is binary: each row is packed most significant bit
+// first, with 0 bits printing black as EPL2 requires.
+func (c *Code) EPL2(opts ...RenderOption) []byte {
+	r := c.render(opts)
+	d := (c.Size + 2*r.margin) * r.scale
+	n := (d + 7) / 8
+	var b bytes.Buffer
+	b.WriteString("\nN\n")
+	if r.darkness >= 0 {
+		fmt.Fprintf(&b, "D%d\n", clampInt(r.darkness, 0, 15))
+	}
+	fmt.Fprintf(&b, "GW0,0,%d,%d,", n, d)
+	pad := byte(0xff >> uint(d&7)) // white padding bits in the last byte
+	if d&7 == 0 {
+		pad = 0
+	}
+	c.packedRows(r, func(row []byte) {
+		for i, v := range row {
+			v = ^v
+			if i == len(row)-1 {
+				v |= pad
+			}
+			b.WriteByte(v)
+		}
+	})
+	b.WriteString("\nP1\n")
+	return b.Bytes()
+}
+
+// WriteEPL2 writes an EPL2 label printing the code to w.
+// See EPL2.
+func (c *Code) WriteEPL2(w io.Writer, opts ...RenderOption) error {
+	_, err := w.Write(c.EPL2(opts...))
+	return err
+}
@@ -0,0 +1,49 @@
+// Copyright 2011 The Go Authors.  All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package qr
+
+import (
+	"bytes"
+	"fmt"
+	"testing"
+)
+
+func TestEPL2(t *testing.T) {
+	c, err := Encode("hello, world", L)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, opts := range [][]RenderOption{
+		{ModuleSize(3)},
+		{ModuleSize(2), Margin(1), Darkness(8)},
+	} {
+		p := c.EPL2(opts...)
+		head := "\nN\n"
+		if c.render(opts).darkness >= 0 {
+			head += "D8\n"
+		}
+		if !bytes.HasPrefix(p, []byte(head+"GW0,0,")) {
+			t.Fatalf("bad EPL2 header %q", p[:20])
+		}
+		p = p[len(head):]
+		var n, d int
+		if _, err := fmt.Sscanf(string(p), "GW0,0,%d,%d,", &n, &d); err != nil {
+			t.Fatalf("bad GW command %q: %v", p[:20], err)
+		}
+		p = p[bytes.IndexByte(p, ',')+1:]
+		for i := 0; i < 3; i++ {
+			p = p[bytes.IndexByte(p, ',')+1:]
+		}
+		if n != (d+7)/8 || len(p) != n*d+len("\nP1\n") || string(p[n*d:]) != "\nP1\n" {
+			t.Fatalf("GW size %d, %d does not match %d data bytes", n, d, len(p))
+		}
+		if d&7 != 0 && p[n-1]&(0xff>>uint(d&7)) != 0xff>>uint(d&7) {
+			t.Errorf("padding bits of %#x are not white", p[n-1])
+		}
+		checkPixels(t, "EPL2", c, opts, d, func(x, y int) bool {
+			return p[y*n+x/8]&(0x80>>uint(x&7)) == 0
+		})
+	}
+}
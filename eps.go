@@ -0,0 +1,64 @@
+// Copyright 2011 The Go Authors.  All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package qr
+
+// EPS writer for QR codes.
+
+import (
+	"bufio"
+	"bytes"
+	"fmt"
+	"image/color"
+	"io"
+)
+
+// EPS returns an Encapsulated PostScript image displaying the code.
+// ModuleSize sets the size of each QR pixel in points (1/72 inch),
+// and the bounding box includes the margin.
+// Black pixels are drawn as merged rectangles, as in SVG.
+// PostScript has no transparency, so colors are drawn opaque.
+func (c *Code) EPS(opts ...RenderOption) []byte {
+	var b bytes.Buffer
+	c.writeEPS(&b, c.render(opts))
+	return b.Bytes()
+}
+
+// WriteEPS writes an Encapsulated PostScript image displaying the code to w.
+// See EPS.
+func (c *Code) WriteEPS(w io.Writer, opts ...RenderOption) error {
+	b := bufio.NewWriter(w)
+	c.writeEPS(b, c.render(opts))
+	return b.Flush()
+}
+
+// writeEPS writes the EPS image of c drawn with r to b.
+func (c *Code) writeEPS(b io.Writer, r render) {
+	d := c.Size + 2*r.margin
+	fmt.Fprintf(b, "%%!PS-Adobe-3.0 EPSF-3.0\n")
+	fmt.Fprintf(b, "%%%%BoundingBox: 0 0 %d %d\n", d*r.scale, d*r.scale)
+	fmt.Fprintf(b, "%%%%Title: QR code\n")
+	fmt.Fprintf(b, "%%%%EndComments\n")
+	fmt.Fprintf(b, "gsave\n")
+	fmt.Fprintf(b, "%d %d scale\n", r.scale, r.scale)
+	fmt.Fprintf(b, "%s 0 0 %d %d rectfill\n", epsColor(r.bg), d, d)
+	fmt.Fprintf(b, "%s\n", epsColor(r.fg))
+	for i, rc := range c.rects() {
+		// PostScript y grows up.
+		x, y := rc.x+r.margin, d-(rc.y+r.margin)-rc.h
+		sep := " "
+		if i%8 == 7 {
+			sep = "\n"
+		}
+		fmt.Fprintf(b, "%d %d %d %d rectfill%s", x, y, rc.w, rc.h, sep)
+	}
+	fmt.Fprintf(b, "\ngrestore\n")
+	fmt.Fprintf(b, "%%%%EOF\n")
+}
+
+// epsColor returns the PostScript commands to set the color col.
+func epsColor(col color.Color) string {
+	n := color.NRGBAModel.Convert(col).(color.NRGBA)
+	return fmt.Sprintf("%.3g %.3g %.3g setrgbcolor", float64(n.R)/0xff, float64(n.G)/0xff, float64(n.B)/0xff)
+}
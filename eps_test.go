@@ -0,0 +1,57 @@
+// Copyright 2011 The Go Authors.  All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package qr
+
+import (
+	"fmt"
+	"strings"
+	"testing"
+)
+
+func TestEPS(t *testing.T) {
+	c, err := Encode("hello, world", L)
+	if err != nil {
+		t.Fatal(err)
+	}
+	eps := string(c.EPS(ModuleSize(2)))
+	d := c.Size + 8
+	if !strings.HasPrefix(eps, "%!PS-Adobe-3.0 EPSF-3.0\n") || !strings.HasSuffix(eps, "%%EOF\n") {
+		t.Errorf("EPS missing header or trailer:\n%s", eps)
+	}
+	if bbox := fmt.Sprintf("%%%%BoundingBox: 0 0 %d %d\n", 2*d, 2*d); !strings.Contains(eps, bbox) {
+		t.Errorf("EPS missing %q", bbox)
+	}
+
+	// The rectangles after the background cover exactly the black pixels.
+	count := make([]int, c.Size*c.Size)
+	body := eps[strings.LastIndex(eps, "setrgbcolor\n")+len("setrgbcolor\n") : strings.Index(eps, "grestore")]
+	f := strings.Fields(body)
+	if len(f)%5 != 0 {
+		t.Fatalf("malformed rectangles: %q", body)
+	}
+	for ; len(f) > 0; f = f[5:] {
+		var x, y, w, h int
+		if _, err := fmt.Sscanf(strings.Join(f[:4], " "), "%d %d %d %d", &x, &y, &w, &h); err != nil || f[4] != "rectfill" {
+			t.Fatalf("malformed rectangle %q", f[:5])
+		}
+		for i := 0; i < h; i++ {
+			yy := d - 4 - (y + i) - 1 // flip back to top-down rows
+			for j := x - 4; j < x-4+w; j++ {
+				count[yy*c.Size+j]++
+			}
+		}
+	}
+	for y := 0; y < c.Size; y++ {
+		for x := 0; x < c.Size; x++ {
+			want := 0
+			if c.Black(x, y) {
+				want = 1
+			}
+			if count[y*c.Size+x] != want {
+				t.Errorf("pixel %d,%d covered %d times, want %d", x, y, count[y*c.Size+x], want)
+			}
+		}
+	}
+}
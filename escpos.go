@@ -0,0 +1,63 @@
+// Copyright 2011 The Go Authors.  All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package qr
+
+// ESC/POS writers for QR codes.
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+)
+
+// ESCPOS returns an ESC/POS GS v 0 raster bit image command
+// printing the code, for receipt printers.  Each QR pixel is
+// ModuleSize printer dots wide; the margin is included in the image.
+// Colors are ignored.
+func (c *Code) ESCPOS(opts ...RenderOption) []byte {
+	r := c.render(opts)
+	d := (c.Size + 2*r.margin) * r.scale
+	n := (d + 7) / 8
+	var b bytes.Buffer
+	b.Write([]byte{0x1d, 'v', '0', 0, byte(n), byte(n >> 8), byte(d), byte(d >> 8)})
+	c.packedRows(r, func(row []byte) { b.Write(row) })
+	return b.Bytes()
+}
+
+// WriteESCPOS writes an ESC/POS command printing the code to w.
+// See ESCPOS.
+func (c *Code) WriteESCPOS(w io.Writer, opts ...RenderOption) error {
+	_, err := w.Write(c.ESCPOS(opts...))
+	return err
+}
+
+// ESCPOSNative returns the ESC/POS GS ( k commands that have a receipt
+// printer with a built-in QR encoder encode and print text itself,
+// as a model 2 code at the given level with moduleSize dots per
+// QR pixel (1 to 16).  The output is much smaller than ESCPOS,
+// but the printer chooses the version, mask, and segmentation.
+func ESCPOSNative(text string, level Level, moduleSize int) ([]byte, error) {
+	if level < L || level > H {
+		return nil, fmt.Errorf("%w %d", ErrBadLevel, int(level))
+	}
+	if len(text) == 0 {
+		return nil, ErrEmptyPayload
+	}
+	if len(text) > 7089 {
+		return nil, fmt.Errorf("text too long for ESC/POS QR: %d bytes", len(text))
+	}
+	gsk := func(b *bytes.Buffer, fn byte, data ...byte) {
+		n := len(data) + 2
+		b.Write([]byte{0x1d, '(', 'k', byte(n), byte(n >> 8), '1', fn})
+		b.Write(data)
+	}
+	var b bytes.Buffer
+	gsk(&b, 'A', '2', 0)                            // model 2
+	gsk(&b, 'C', byte(clampInt(moduleSize, 1, 16))) // module size
+	gsk(&b, 'E', '0'+byte(level))                   // error correction level
+	gsk(&b, 'P', append([]byte{'0'}, text...)...)   // store data
+	gsk(&b, 'Q', '0')                               // print
+	return b.Bytes(), nil
+}
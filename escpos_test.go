@@ -0,0 +1,54 @@
+// Copyright 2011 The Go Authors.  All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package qr
+
+import (
+	"bytes"
+	"errors"
+	"testing"
+)
+
+func TestESCPOS(t *testing.T) {
+	c, err := Encode("hello, world", L)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, opts := range netpbmTests {
+		p := c.ESCPOS(opts...)
+		if !bytes.HasPrefix(p, []byte{0x1d, 'v', '0', 0}) || len(p) < 8 {
+			t.Fatalf("bad GS v 0 header % x", p[:8])
+		}
+		n := int(p[4]) | int(p[5])<<8
+		d := int(p[6]) | int(p[7])<<8
+		p = p[8:]
+		if n != (d+7)/8 || len(p) != n*d {
+			t.Fatalf("GS v 0 size %d, %d does not match %d data bytes", n, d, len(p))
+		}
+		checkPixels(t, "ESCPOS", c, opts, d, func(x, y int) bool {
+			return p[y*n+x/8]&(0x80>>uint(x&7)) != 0
+		})
+	}
+}
+
+func TestESCPOSNative(t *testing.T) {
+	p, err := ESCPOSNative("hi", Q, 6)
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := "\x1d(k\x04\x001A2\x00" +
+		"\x1d(k\x03\x001C\x06" +
+		"\x1d(k\x03\x001E2" +
+		"\x1d(k\x05\x001P0hi" +
+		"\x1d(k\x03\x001Q0"
+	if string(p) != want {
+		t.Errorf("ESCPOSNative = %q, want %q", p, want)
+	}
+	if _, err := ESCPOSNative("", L, 6); err != ErrEmptyPayload {
+		t.Errorf("ESCPOSNative(\"\") = %v, want ErrEmptyPayload", err)
+	}
+	if _, err := ESCPOSNative("hi", Level(7), 6); !errors.Is(err, ErrBadLevel) {
+		t.Errorf("ESCPOSNative(level 7) = %v, want ErrBadLevel", err)
+	}
+}
@@ -0,0 +1,132 @@
+// Copyright 2011 The Go Authors.  All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package qr
+
+// Image formats and data URIs for QR codes.
+
+import (
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"io"
+	"strings"
+)
+
+// A Format names an image file format that a code can be rendered in.
+type Format string
+
+const (
+	FormatPNG  Format = "png"
+	FormatSVG  Format = "svg"
+	FormatTIFF Format = "tiff"
+	FormatEPS  Format = "eps"
+	FormatPBM  Format = "pbm"
+	FormatPGM  Format = "pgm"
+)
+
+// A formatInfo describes how to render a Format.
+type formatInfo struct {
+	mediaType string
+	encode    func(c *Code, opts ...RenderOption) []byte
+	write     func(c *Code, w io.Writer, opts ...RenderOption) error
+}
+
+var formats = map[Format]formatInfo{
+	FormatPNG:  {"image/png", (*Code).PNG, (*Code).WritePNG},
+	FormatSVG:  {"image/svg+xml", (*Code).SVG, (*Code).WriteSVG},
+	FormatTIFF: {"image/tiff", (*Code).TIFF, (*Code).WriteTIFF},
+	FormatEPS:  {"application/postscript", (*Code).EPS, (*Code).WriteEPS},
+	FormatPBM:  {"image/x-portable-bitmap", (*Code).PBM, (*Code).WritePBM},
+	FormatPGM:  {"image/x-portable-graymap", (*Code).PGM, (*Code).WritePGM},
+}
+
+// lookup returns the formatInfo for f.  Format names are not case-sensitive.
+func (f Format) lookup() (formatInfo, error) {
+	fi, ok := formats[Format(strings.ToLower(string(f)))]
+	if !ok {
+		return formatInfo{}, fmt.Errorf("unknown image format %q", string(f))
+	}
+	return fi, nil
+}
+
+// MediaType returns the MIME media type of f, such as "image/png",
+// or the empty string if f is not a known format.
+func (f Format) MediaType() string {
+	fi, _ := f.lookup()
+	return fi.mediaType
+}
+
+// RenderFormat returns the code rendered in the given format.
+// It returns an error wrapping ErrLowContrast if the colors set by opts
+// fail CheckContrast.
+func (c *Code) RenderFormat(format Format, opts ...RenderOption) ([]byte, error) {
+	fi, err := format.lookup()
+	if err != nil {
+		return nil, err
+	}
+	if err := c.CheckContrast(opts...); err != nil {
+		return nil, err
+	}
+	return fi.encode(c, opts...), nil
+}
+
+// DataURI returns a data URI holding the code rendered in the given
+// format, such as "data:image/png;base64,...", for embedding directly
+// in HTML, CSS, or JSON.  It fails in the same cases as RenderFormat.
+func (c *Code) DataURI(format Format, opts ...RenderOption) (string, error) {
+	data, err := c.RenderFormat(format, opts...)
+	if err != nil {
+		return "", err
+	}
+	return "data:" + format.MediaType() + ";base64," + base64.StdEncoding.EncodeToString(data), nil
+}
+
+// A Renderer renders a code in a given format.
+// It implements io.WriterTo, so that a code can be written directly
+// to an HTTP response, file, or archive:
+//
+//	w.Header().Set("Content-Type", qr.FormatPNG.MediaType())
+//	qr.Renderer{Code: c, Format: qr.FormatPNG}.WriteTo(w)
+type Renderer struct {
+	Code    *Code
+	Format  Format
+	Options []RenderOption
+}
+
+// errNoCode is returned by Renderer.WriteTo when Code is nil.
+var errNoCode = errors.New("no QR code to render")
+
+// WriteTo writes the rendered code to w, streaming it as it is
+// generated rather than building it in memory first, except for PNG,
+// whose encoder works on the whole image.
+// It returns the number of bytes written and any error.
+// It fails in the same cases as RenderFormat, and if Code is nil.
+func (r Renderer) WriteTo(w io.Writer) (int64, error) {
+	if r.Code == nil {
+		return 0, errNoCode
+	}
+	fi, err := r.Format.lookup()
+	if err != nil {
+		return 0, err
+	}
+	if err := r.Code.CheckContrast(r.Options...); err != nil {
+		return 0, err
+	}
+	cw := &countingWriter{w: w}
+	err = fi.write(r.Code, cw, r.Options...)
+	return cw.n, err
+}
+
+// A countingWriter counts the bytes written to an underlying writer.
+type countingWriter struct {
+	w io.Writer
+	n int64
+}
+
+func (w *countingWriter) Write(p []byte) (int, error) {
+	n, err := w.w.Write(p)
+	w.n += int64(n)
+	return n, err
+}
@@ -0,0 +1,112 @@
+// Copyright 2011 The Go Authors.  All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package qr
+
+import (
+	"bytes"
+	"encoding/base64"
+	"errors"
+	"image/color"
+	"io"
+	"strings"
+	"testing"
+)
+
+func TestDataURI(t *testing.T) {
+	c, err := Encode("hello, world", L)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, tt := range []struct {
+		format    Format
+		mediaType string
+		data      []byte
+	}{
+		{FormatPNG, "image/png", c.PNG(Margin(1))},
+		{"SVG", "image/svg+xml", c.SVG(Margin(1))},
+		{FormatTIFF, "image/tiff", c.TIFF(Margin(1))},
+	} {
+		uri, err := c.DataURI(tt.format, Margin(1))
+		if err != nil {
+			t.Errorf("DataURI(%q): %v", tt.format, err)
+			continue
+		}
+		prefix := "data:" + tt.mediaType + ";base64,"
+		if !strings.HasPrefix(uri, prefix) {
+			t.Errorf("DataURI(%q) = %.40q..., want prefix %q", tt.format, uri, prefix)
+			continue
+		}
+		data, err := base64.StdEncoding.DecodeString(uri[len(prefix):])
+		if err != nil || !bytes.Equal(data, tt.data) {
+			t.Errorf("DataURI(%q) data does not match renderer output (err=%v)", tt.format, err)
+		}
+		if mt := tt.format.MediaType(); mt != tt.mediaType {
+			t.Errorf("Format(%q).MediaType() = %q, want %q", tt.format, mt, tt.mediaType)
+		}
+	}
+
+	if _, err := c.DataURI("bmp"); err == nil {
+		t.Errorf("DataURI(\"bmp\") succeeded, want error")
+	}
+	if mt := Format("bmp").MediaType(); mt != "" {
+		t.Errorf("Format(\"bmp\").MediaType() = %q, want \"\"", mt)
+	}
+}
+
+func TestRenderer(t *testing.T) {
+	c, err := Encode("hello, world", L)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var _ io.WriterTo = Renderer{}
+
+	var buf bytes.Buffer
+	n, err := Renderer{Code: c, Format: FormatSVG, Options: []RenderOption{ModuleSize(3)}}.WriteTo(&buf)
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := c.SVG(ModuleSize(3))
+	if n != int64(len(want)) || !bytes.Equal(buf.Bytes(), want) {
+		t.Errorf("WriteTo wrote %d bytes, not the %d bytes of SVG", n, len(want))
+	}
+
+	for f := range formats {
+		opts := []RenderOption{ModuleSize(3), Foreground(color.NRGBA{0x20, 0x20, 0x80, 0xff})}
+		buf.Reset()
+		n, err := Renderer{Code: c, Format: f, Options: opts}.WriteTo(&buf)
+		want, _ := c.RenderFormat(f, opts...)
+		if err != nil || n != int64(len(want)) || !bytes.Equal(buf.Bytes(), want) {
+			t.Errorf("WriteTo(%s) = %d, %v, want the %d bytes of RenderFormat", f, n, err, len(want))
+		}
+	}
+
+	if n, err := (Renderer{Code: c, Format: "bmp"}).WriteTo(&buf); n != 0 || err == nil {
+		t.Errorf("WriteTo with bad format = %d, %v, want 0, error", n, err)
+	}
+	if n, err := (Renderer{Format: FormatSVG}).WriteTo(&buf); n != 0 || err == nil {
+		t.Errorf("WriteTo with nil Code = %d, %v, want 0, error", n, err)
+	}
+	w := &shortWriter{n: 100}
+	if n, err := (Renderer{Code: c, Format: FormatSVG}).WriteTo(w); n != 100 || err != errShortWrite {
+		t.Errorf("WriteTo failing writer = %d, %v, want 100, %v", n, err, errShortWrite)
+	}
+}
+
+var errShortWrite = errors.New("short write")
+
+// A shortWriter accepts n bytes and then fails.
+type shortWriter struct {
+	n int
+}
+
+func (w *shortWriter) Write(p []byte) (int, error) {
+	if len(p) > w.n {
+		n := w.n
+		w.n = 0
+		return n, errShortWrite
+	}
+	w.n -= len(p)
+	return len(p), nil
+}
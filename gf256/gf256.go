@@ -5,7 +5,10 @@
 // Package gf256 implements arithmetic over the Galois Field GF(256).
 package gf256 // import "rsc.io/qr/gf256"
 
-import "strconv"
+import (
+	"errors"
+	"strconv"
+)
 
 // A Field represents an instance of GF(256) defined by a specific polynomial.
 type Field struct {
@@ -239,3 +242,200 @@ func (rs *RSEncoder) ECC(data []byte, check []byte) {
 	copy(check, p[len(data):])
 	rs.p = p
 }
+
+// Syndromes returns the n syndromes of msg, a Reed-Solomon codeword
+// as written by an RSEncoder with n check bytes: the message polynomial
+// evaluated at the roots α^0, ..., α^(n-1) of the generator,
+// where msg[i] is the coefficient of x^(len(msg)-1-i).
+// The syndromes are all zero if and only if msg is a valid codeword.
+func (f *Field) Syndromes(msg []byte, n int) []byte {
+	synd := make([]byte, n)
+	for j := range synd {
+		a := f.Exp(j)
+		var s byte
+		for _, v := range msg {
+			s = f.Mul(s, a) ^ v
+		}
+		synd[j] = s
+	}
+	return synd
+}
+
+// ErrTooManyErrors is returned by CorrectRS and RSDecoder.Correct for a codeword
+// with more errors than its check bytes can correct.
+var ErrTooManyErrors = errors.New("gf256: too many errors")
+
+// CorrectRS corrects msg in place, a Reed-Solomon codeword as written
+// by an RSEncoder with c check bytes: the data bytes followed by the
+// check bytes.  The erasures are the indexes in msg of bytes known
+// to be unreliable.  CorrectRS can correct e erasures and t other
+// errors as long as e + 2t ≤ c.  It returns the number of bytes
+// it changed, or ErrTooManyErrors if msg cannot be corrected.
+func (f *Field) CorrectRS(msg []byte, c int, erasures []int) (int, error) {
+	n := len(msg)
+	if c > n || n > 255 {
+		panic("gf256: invalid codeword length")
+	}
+	if len(erasures) > c {
+		return 0, ErrTooManyErrors
+	}
+
+	synd := f.Syndromes(msg, c)
+	ok := true
+	for _, s := range synd {
+		if s != 0 {
+			ok = false
+			break
+		}
+	}
+	if ok {
+		return 0, nil
+	}
+
+	// Erasure locator Γ = Π (1 + X x), where X = α^(n-1-i) for erasure i.
+	// Polynomials here have coefficients in increasing order of degree.
+	lam := make([]byte, 1, c+1)
+	lam[0] = 1
+	for _, i := range erasures {
+		if i < 0 || i >= n {
+			panic("gf256: invalid erasure position")
+		}
+		x := f.Exp(n - 1 - i)
+		lam = append(lam, 0)
+		for j := len(lam) - 1; j > 0; j-- {
+			lam[j] ^= f.Mul(lam[j-1], x)
+		}
+	}
+
+	// Berlekamp-Massey, started from the erasure locator,
+	// extends it to the locator Λ of errors and erasures.
+	ne := len(erasures)
+	prev := append([]byte(nil), lam...)
+	l, shift, pd := ne, 1, byte(1)
+	for k := ne; k < c; k++ {
+		d := byte(0)
+		for i := 0; i < len(lam) && i <= k; i++ {
+			d ^= f.Mul(lam[i], synd[k-i])
+		}
+		if d == 0 {
+			shift++
+			continue
+		}
+		coef := f.Mul(d, f.Inv(pd))
+		t := append([]byte(nil), lam...)
+		for len(lam) < len(prev)+shift {
+			lam = append(lam, 0)
+		}
+		for i, p := range prev {
+			lam[i+shift] ^= f.Mul(coef, p)
+		}
+		if 2*l <= k+ne {
+			l = k + 1 + ne - l
+			prev, pd, shift = t, d, 1
+		} else {
+			shift++
+		}
+	}
+	for len(lam) > 1 && lam[len(lam)-1] == 0 {
+		lam = lam[:len(lam)-1]
+	}
+	if len(lam)-1 != l || 2*(l-ne)+ne > c {
+		return 0, ErrTooManyErrors
+	}
+
+	// Evaluator Ω = SΛ mod x^c.
+	omega := make([]byte, c)
+	for i := range omega {
+		for j := 0; j <= i && j < len(lam); j++ {
+			omega[i] ^= f.Mul(lam[j], synd[i-j])
+		}
+	}
+	eval := func(p []byte, x byte) byte {
+		var s byte
+		for i := len(p) - 1; i >= 0; i-- {
+			s = f.Mul(s, x) ^ p[i]
+		}
+		return s
+	}
+
+	// Chien search for the roots X⁻¹ of Λ, and Forney's formula
+	// for the error values: X Ω(X⁻¹) / Λ'(X⁻¹).
+	type fix struct {
+		i int
+		v byte
+	}
+	fixes := make([]fix, 0, l)
+	for i := range msg {
+		e := (n - 1 - i) % 255
+		xinv := f.Exp(255 - e)
+		if eval(lam, xinv) != 0 {
+			continue
+		}
+		var dlam byte // Λ'(X⁻¹): the odd terms of Λ, lowered one degree
+		for j := 1; j < len(lam); j += 2 {
+			dlam ^= f.Mul(lam[j], f.Exp((255-e)*(j-1)))
+		}
+		if dlam == 0 {
+			return 0, ErrTooManyErrors
+		}
+		fixes = append(fixes, fix{i, f.Mul(f.Exp(e), f.Mul(eval(omega, xinv), f.Inv(dlam)))})
+	}
+	if len(fixes) != l {
+		return 0, ErrTooManyErrors
+	}
+	changed := 0
+	for _, x := range fixes {
+		if x.v != 0 {
+			msg[x.i] ^= x.v
+			changed++
+		}
+	}
+	return changed, nil
+}
+
+// An RSDecoder implements Reed-Solomon error correction
+// over a given field using a given number of error correction bytes.
+type RSDecoder struct {
+	f   *Field
+	c   int
+	msg []byte
+}
+
+// NewRSDecoder returns a new Reed-Solomon decoder
+// over the given field and number of error correction bytes.
+func NewRSDecoder(f *Field, c int) *RSDecoder {
+	return &RSDecoder{f: f, c: c}
+}
+
+// Correct corrects data and check in place, the data bytes and the
+// check bytes written for them by an RSEncoder with the same parameters.
+// It can correct up to c/2 errors in data and check together.
+// It returns the number of bytes it changed, or ErrTooManyErrors
+// if the codeword cannot be corrected, in which case data and check
+// are left unchanged.
+func (rs *RSDecoder) Correct(data, check []byte) (corrected int, err error) {
+	if len(check) != rs.c {
+		panic("gf256: invalid check byte length")
+	}
+	n := len(data) + rs.c
+	if n > 255 {
+		panic("gf256: invalid codeword length")
+	}
+	var msg []byte
+	if len(rs.msg) >= n {
+		msg = rs.msg[:n]
+	} else {
+		msg = make([]byte, n)
+	}
+	copy(msg, data)
+	copy(msg[len(data):], check)
+	rs.msg = msg
+
+	corrected, err = rs.f.CorrectRS(msg, rs.c, nil)
+	if err != nil || corrected == 0 {
+		return 0, err
+	}
+	copy(data, msg)
+	copy(check, msg[len(data):])
+	return corrected, nil
+}
@@ -7,6 +7,7 @@ package gf256
 import (
 	"bytes"
 	"fmt"
+	"math/rand"
 	"testing"
 )
 
@@ -192,3 +193,116 @@ func generates(α, poly int) bool {
 	}
 	return true
 }
+
+func TestCorrectRS(t *testing.T) {
+	r := rand.New(rand.NewSource(1))
+	for _, c := range []int{2, 7, 10, 30} {
+		rs := NewRSEncoder(f, c)
+		for iter := 0; iter < 200; iter++ {
+			n := c + 1 + r.Intn(255-c)
+			msg := make([]byte, n)
+			r.Read(msg[:n-c])
+			rs.ECC(msg[:n-c], msg[n-c:])
+			want := append([]byte(nil), msg...)
+
+			// Some erasures, some errors, within the bound.
+			ne := r.Intn(c + 1)
+			nt := (c - ne) / 2
+			pos := r.Perm(n)
+			erasures := pos[:ne]
+			for _, i := range erasures {
+				msg[i] = byte(r.Intn(256)) // may be left unchanged
+			}
+			for _, i := range pos[ne : ne+nt] {
+				msg[i] ^= byte(1 + r.Intn(255))
+			}
+			changed, err := f.CorrectRS(msg, c, erasures)
+			if err != nil || !bytes.Equal(msg, want) {
+				t.Fatalf("c=%d n=%d: CorrectRS with %d erasures, %d errors = %d, %v\nhave %x\nwant %x", c, n, ne, nt, changed, err, msg, want)
+			}
+			if changed < nt || changed > nt+ne {
+				t.Errorf("c=%d: CorrectRS with %d erasures, %d errors changed %d bytes", c, ne, nt, changed)
+			}
+		}
+	}
+
+	msg := make([]byte, 10)
+	if _, err := f.CorrectRS(msg, 4, []int{0, 1, 2, 3, 4}); err != ErrTooManyErrors {
+		t.Errorf("CorrectRS with 5 erasures, 4 check bytes = %v, want ErrTooManyErrors", err)
+	}
+	msg[0], msg[3], msg[7] = 1, 2, 3
+	if _, err := f.CorrectRS(msg, 4, nil); err != ErrTooManyErrors {
+		t.Errorf("CorrectRS with 3 errors, 4 check bytes = %v, want ErrTooManyErrors", err)
+	}
+}
+
+func TestRSDecoder(t *testing.T) {
+	r := rand.New(rand.NewSource(1))
+	for _, c := range []int{2, 7, 10, 30} {
+		enc := NewRSEncoder(f, c)
+		dec := NewRSDecoder(f, c)
+		for iter := 0; iter < 100; iter++ {
+			data := make([]byte, 1+r.Intn(255-c))
+			check := make([]byte, c)
+			r.Read(data)
+			enc.ECC(data, check)
+			wantData := append([]byte(nil), data...)
+			wantCheck := append([]byte(nil), check...)
+
+			nt := r.Intn(c/2 + 1)
+			for _, i := range r.Perm(len(data) + c)[:nt] {
+				if i < len(data) {
+					data[i] ^= byte(1 + r.Intn(255))
+				} else {
+					check[i-len(data)] ^= byte(1 + r.Intn(255))
+				}
+			}
+			n, err := dec.Correct(data, check)
+			if err != nil || n != nt || !bytes.Equal(data, wantData) || !bytes.Equal(check, wantCheck) {
+				t.Fatalf("c=%d: Correct with %d errors = %d, %v\nhave %x %x\nwant %x %x", c, nt, n, err, data, check, wantData, wantCheck)
+			}
+		}
+	}
+
+	dec := NewRSDecoder(f, 4)
+	data, check := make([]byte, 6), make([]byte, 4)
+	data[0], data[3], check[1] = 1, 2, 3
+	if _, err := dec.Correct(data, check); err != ErrTooManyErrors {
+		t.Errorf("Correct with 3 errors, 4 check bytes = %v, want ErrTooManyErrors", err)
+	}
+	if data[0] != 1 || data[3] != 2 || check[1] != 3 {
+		t.Errorf("Correct modified uncorrectable codeword: %x %x", data, check)
+	}
+}
+
+func TestSyndromes(t *testing.T) {
+	r := rand.New(rand.NewSource(1))
+	for _, c := range []int{2, 7, 10, 30} {
+		rs := NewRSEncoder(f, c)
+		for iter := 0; iter < 100; iter++ {
+			n := c + 1 + r.Intn(255-c)
+			msg := make([]byte, n)
+			r.Read(msg[:n-c])
+			rs.ECC(msg[:n-c], msg[n-c:])
+			synd := f.Syndromes(msg, c)
+			if len(synd) != c {
+				t.Fatalf("c=%d: len(Syndromes) = %d", c, len(synd))
+			}
+			for j, s := range synd {
+				if s != 0 {
+					t.Fatalf("c=%d: Syndromes of valid codeword: synd[%d] = %#x", c, j, s)
+				}
+			}
+
+			// A single error e at position i makes synd[j] = e α^(j(n-1-i)).
+			i := r.Intn(n)
+			e := byte(1 + r.Intn(255))
+			msg[i] ^= e
+			for j, s := range f.Syndromes(msg, c) {
+				if want := f.Mul(e, f.Exp(j*(n-1-i))); s != want {
+					t.Fatalf("c=%d n=%d: error %#x at %d: synd[%d] = %#x, want %#x", c, n, e, i, j, s, want)
+				}
+			}
+		}
+	}
+}
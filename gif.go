@@ -0,0 +1,73 @@
+// Copyright 2011 The Go Authors.  All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package qr
+
+// Animated GIF writer for QR code series.
+
+import (
+	"bytes"
+	"errors"
+	"image"
+	"image/color"
+	"image/gif"
+	"io"
+	"time"
+)
+
+// AnimatedGIF returns an animated GIF that shows each of the codes in
+// turn, for delay each, looping forever.  It is meant for a Structured
+// Append series from EncodeSeries: pointing a phone camera at the
+// animation transfers the whole message without a network.
+// Codes of different sizes are centered in frames sized for the largest.
+// Gradient fills are not supported; the codes use the Foreground color.
+func AnimatedGIF(codes []*Code, delay time.Duration, opts ...RenderOption) ([]byte, error) {
+	var b bytes.Buffer
+	if err := WriteAnimatedGIF(&b, codes, delay, opts...); err != nil {
+		return nil, err
+	}
+	return b.Bytes(), nil
+}
+
+// WriteAnimatedGIF writes an animated GIF showing the codes to w.
+// See AnimatedGIF.
+func WriteAnimatedGIF(w io.Writer, codes []*Code, delay time.Duration, opts ...RenderOption) error {
+	if len(codes) == 0 {
+		return errors.New("no QR codes to animate")
+	}
+	renders := make([]render, len(codes))
+	d := 0
+	for i, c := range codes {
+		r := c.render(opts)
+		r.grad = nil
+		renders[i] = r
+		if n := (c.Size + 2*r.margin) * r.scale; n > d {
+			d = n
+		}
+	}
+	cs := int(delay / (10 * time.Millisecond)) // GIF delays are in 1/100 s
+	if cs < 1 {
+		cs = 1
+	}
+	anim := &gif.GIF{Config: image.Config{Width: d, Height: d}}
+	for i, c := range codes {
+		r := &renders[i]
+		pal := color.Palette{r.bg, r.fg}
+		img := image.NewPaletted(image.Rect(0, 0, d, d), pal)
+		off := (d - (c.Size+2*r.margin)*r.scale) / 2
+		n := d - 2*off
+		for y := 0; y < n; y++ {
+			row := img.Pix[(y+off)*img.Stride+off:]
+			for x := 0; x < n; x++ {
+				if c.imageBlack(r, x, y) {
+					row[x] = 1
+				}
+			}
+		}
+		anim.Image = append(anim.Image, img)
+		anim.Delay = append(anim.Delay, cs)
+	}
+	anim.Config.ColorModel = anim.Image[0].Palette
+	return gif.EncodeAll(w, anim)
+}
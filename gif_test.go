@@ -0,0 +1,59 @@
+// Copyright 2011 The Go Authors.  All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package qr
+
+import (
+	"bytes"
+	"image/gif"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestAnimatedGIF(t *testing.T) {
+	codes, err := EncodeSeries([]byte(strings.Repeat("hello, world. ", 300)), M)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(codes) < 2 {
+		t.Fatalf("EncodeSeries returned %d codes, want several", len(codes))
+	}
+	// A small extra code exercises centering.
+	small, err := Encode("hi", L)
+	if err != nil {
+		t.Fatal(err)
+	}
+	codes = append(codes, small)
+
+	data, err := AnimatedGIF(codes, 250*time.Millisecond, ModuleSize(2))
+	if err != nil {
+		t.Fatal(err)
+	}
+	anim, err := gif.DecodeAll(bytes.NewReader(data))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(anim.Image) != len(codes) || anim.LoopCount != 0 {
+		t.Fatalf("GIF has %d frames, loop count %d, want %d, 0", len(anim.Image), anim.LoopCount, len(codes))
+	}
+	d := (codes[0].Size + 8) * 2
+	for i, c := range codes {
+		if anim.Delay[i] != 25 {
+			t.Errorf("frame %d delay = %d, want 25", i, anim.Delay[i])
+		}
+		frame := anim.Image[i]
+		if frame.Bounds().Dx() != d || frame.Bounds().Dy() != d {
+			t.Fatalf("frame %d is %v, want %dx%d", i, frame.Bounds(), d, d)
+		}
+		off := (d - (c.Size+8)*2) / 2
+		checkPixels(t, "GIF", c, []RenderOption{ModuleSize(2)}, d-2*off, func(x, y int) bool {
+			return isBlack(frame, x+off, y+off)
+		})
+	}
+
+	if _, err := AnimatedGIF(nil, time.Second); err == nil {
+		t.Errorf("AnimatedGIF(nil) succeeded, want error")
+	}
+}
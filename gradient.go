@@ -0,0 +1,164 @@
+// Copyright 2011 The Go Authors.  All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package qr
+
+// Gradient fills for QR codes.
+
+import (
+	"fmt"
+	"hash/fnv"
+	"image/color"
+	"io"
+	"math"
+)
+
+// A gradient is a foreground fill that varies across the code.
+type gradient struct {
+	radial   bool
+	from, to color.NRGBA
+	angle    float64 // direction of a linear gradient, in degrees
+}
+
+// LinearGradient fills the black pixels of the code with a gradient
+// from one color to another across the code, in the direction given
+// by angle in degrees: 0 runs left to right, 90 top to bottom.
+//
+// If MinContrast is in effect, as it is by default, each color is
+// darkened (or, on a dark background, lightened) as needed to keep
+// the required contrast against the background.
+// Only Image, PNG, SVG, and HTML output with HTMLSVG draw gradients;
+// the other formats use the Foreground color.
+func LinearGradient(from, to color.Color, angle float64) RenderOption {
+	return func(r *render) { r.grad = newGradient(false, from, to, angle) }
+}
+
+// RadialGradient is like LinearGradient but varies the color from
+// the center of the code out to its corners.
+func RadialGradient(center, corner color.Color) RenderOption {
+	return func(r *render) { r.grad = newGradient(true, center, corner, 0) }
+}
+
+func newGradient(radial bool, from, to color.Color, angle float64) *gradient {
+	nrgba := func(c color.Color) color.NRGBA {
+		if c == nil {
+			c = blackColor
+		}
+		return color.NRGBAModel.Convert(c).(color.NRGBA)
+	}
+	return &gradient{radial: radial, from: nrgba(from), to: nrgba(to), angle: angle}
+}
+
+// clamp returns a copy of g with each color adjusted to have
+// at least the given contrast ratio against bg.
+func (g *gradient) clamp(bg color.Color, min float64) *gradient {
+	gg := *g
+	gg.from = clampContrast(g.from, bg, min)
+	gg.to = clampContrast(g.to, bg, min)
+	return &gg
+}
+
+// clampContrast returns the color closest to col that has a contrast
+// ratio of at least min against bg, found by moving col toward black,
+// or toward white if bg is dark.
+func clampContrast(col color.NRGBA, bg color.Color, min float64) color.NRGBA {
+	if ContrastRatio(col, bg) >= min {
+		return col
+	}
+	var target uint8
+	if luminance(bg) < 0.18 {
+		target = 0xff
+	}
+	mix := func(t float64) color.NRGBA {
+		m := func(v uint8) uint8 {
+			return uint8(float64(v) + (float64(target)-float64(v))*t + 0.5)
+		}
+		return color.NRGBA{m(col.R), m(col.G), m(col.B), col.A}
+	}
+	lo, hi := 0.0, 1.0
+	for i := 0; i < 20; i++ {
+		mid := (lo + hi) / 2
+		if ContrastRatio(mix(mid), bg) >= min {
+			hi = mid
+		} else {
+			lo = mid
+		}
+	}
+	return mix(hi)
+}
+
+// at returns the color of g at position t from 0 to 1.
+func (g *gradient) at(t float64) color.NRGBA {
+	if t < 0 {
+		t = 0
+	}
+	if t > 1 {
+		t = 1
+	}
+	m := func(a, b uint8) uint8 {
+		return uint8(float64(a) + (float64(b)-float64(a))*t + 0.5)
+	}
+	return color.NRGBA{m(g.from.R, g.to.R), m(g.from.G, g.to.G), m(g.from.B, g.to.B), m(g.from.A, g.to.A)}
+}
+
+// geometry returns the gradient's extent in a code of d QR pixels
+// (including margin), in QR pixel units: the start and end points
+// of a linear gradient, or the center and radius of a radial one
+// as (x0, y0) and (r, 0).
+func (g *gradient) geometry(d int) (x0, y0, x1, y1 float64) {
+	c := float64(d) / 2
+	if g.radial {
+		return c, c, c * math.Sqrt2, 0
+	}
+	s, co := math.Sincos(g.angle * math.Pi / 180)
+	ext := c * (math.Abs(co) + math.Abs(s))
+	return c - co*ext, c - s*ext, c + co*ext, c + s*ext
+}
+
+// colorAt returns the gradient color at the point (x, y), in QR pixel
+// units, of a code of d QR pixels.
+func (g *gradient) colorAt(d int, x, y float64) color.NRGBA {
+	x0, y0, x1, y1 := g.geometry(d)
+	if g.radial {
+		return g.at(math.Hypot(x-x0, y-y0) / x1)
+	}
+	dx, dy := x1-x0, y1-y0
+	return g.at(((x-x0)*dx + (y-y0)*dy) / (dx*dx + dy*dy))
+}
+
+// id returns the SVG element ID of the gradient for a code of d QR pixels.
+// The ID is derived from the gradient's colors and geometry, so that
+// several codes inlined in one HTML page refer to their own gradients:
+// codes sharing an ID also share an identical definition.
+func (g *gradient) id(d int) string {
+	x0, y0, x1, y1 := g.geometry(d)
+	h := fnv.New32a()
+	fmt.Fprintf(h, "%v %v %v %.5g %.5g %.5g %.5g", g.radial, g.from, g.to, x0, y0, x1, y1)
+	return fmt.Sprintf("qr-gradient-%08x", h.Sum32())
+}
+
+// writeSVG writes an SVG <defs> element defining the gradient
+// for a code of d QR pixels, with the ID returned by id.
+func (g *gradient) writeSVG(b io.Writer, d int) {
+	x0, y0, x1, y1 := g.geometry(d)
+	if g.radial {
+		fmt.Fprintf(b, `<defs><radialGradient id="%s" gradientUnits="userSpaceOnUse" cx="%.5g" cy="%.5g" r="%.5g">`,
+			g.id(d), x0, y0, x1)
+	} else {
+		fmt.Fprintf(b, `<defs><linearGradient id="%s" gradientUnits="userSpaceOnUse" x1="%.5g" y1="%.5g" x2="%.5g" y2="%.5g">`,
+			g.id(d), x0, y0, x1, y1)
+	}
+	for i, col := range []color.NRGBA{g.from, g.to} {
+		fmt.Fprintf(b, `<stop offset="%d" stop-color="#%02x%02x%02x"`, i, col.R, col.G, col.B)
+		if col.A != 0xff {
+			fmt.Fprintf(b, ` stop-opacity="%.3g"`, float64(col.A)/0xff)
+		}
+		io.WriteString(b, "/>")
+	}
+	if g.radial {
+		io.WriteString(b, "</radialGradient></defs>\n")
+	} else {
+		io.WriteString(b, "</linearGradient></defs>\n")
+	}
+}
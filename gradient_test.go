@@ -0,0 +1,158 @@
+// Copyright 2011 The Go Authors.  All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package qr
+
+import (
+	"bytes"
+	"encoding/xml"
+	"image/color"
+	"image/png"
+	"testing"
+)
+
+func TestGradient(t *testing.T) {
+	c, err := Encode("hello, world", L)
+	if err != nil {
+		t.Fatal(err)
+	}
+	red := color.NRGBA{0xc0, 0, 0, 0xff}
+	yellow := color.NRGBA{0xff, 0xff, 0, 0xff} // far too light on white
+	for _, opt := range []RenderOption{
+		LinearGradient(red, yellow, 0),
+		LinearGradient(red, yellow, 45),
+		RadialGradient(red, yellow),
+	} {
+		opts := []RenderOption{ModuleSize(4), PhysicalModuleSize(0.5), opt}
+		if err := c.CheckContrast(opts...); err != nil {
+			t.Errorf("CheckContrast: %v", err)
+		}
+
+		// Every black pixel keeps enough contrast, and the colors vary.
+		img := c.Image(opts...)
+		p, err := png.Decode(bytes.NewReader(c.PNG(opts...)))
+		if err != nil {
+			t.Fatalf("png.Decode: %v", err)
+		}
+		colors := make(map[color.Color]bool)
+		b := img.Bounds()
+		for y := b.Min.Y; y < b.Max.Y; y++ {
+			for x := b.Min.X; x < b.Max.X; x++ {
+				col := img.At(x, y)
+				if !sameColor(p.At(x, y), col) {
+					t.Fatalf("pixel (%d, %d) differs between PNG and Image", x, y)
+				}
+				if col == whiteColor {
+					continue
+				}
+				if cr := ContrastRatio(col, color.White); cr < DefaultMinContrast-0.01 {
+					t.Fatalf("pixel (%d, %d) = %v has contrast %.2f", x, y, col, cr)
+				}
+				colors[col] = true
+			}
+		}
+		if len(colors) < 10 {
+			t.Errorf("gradient image has only %d foreground colors", len(colors))
+		}
+		if pngChunk(c.PNG(opts...), "pHYs") == nil {
+			t.Errorf("gradient PNG lacks pHYs chunk")
+		}
+
+		var doc svgGradientDoc
+		if err := xml.Unmarshal(c.SVG(opts...), &doc); err != nil {
+			t.Fatalf("xml.Unmarshal: %v", err)
+		}
+		stops := doc.stops()
+		if len(stops) != 2 || stops[0].Color != "#c00000" || stops[1].Color == "#ffff00" {
+			t.Errorf("SVG stops = %v, want #c00000 and a darkened yellow", stops)
+		}
+		if id := doc.id(); id == "" || doc.Path.Fill != "url(#"+id+")" {
+			t.Errorf("SVG path fill = %q, gradient id = %q", doc.Path.Fill, id)
+		}
+	}
+}
+
+// An svgGradientDoc is the part of an SVG image with a gradient fill
+// checked by the tests.
+type svgGradientDoc struct {
+	Linear []svgGradient `xml:"defs>linearGradient"`
+	Radial []svgGradient `xml:"defs>radialGradient"`
+	Path   struct {
+		Fill string `xml:"fill,attr"`
+	} `xml:"path"`
+}
+
+type svgGradient struct {
+	ID   string    `xml:"id,attr"`
+	Stop []svgStop `xml:"stop"`
+}
+
+type svgStop struct {
+	Color string `xml:"stop-color,attr"`
+}
+
+func (doc *svgGradientDoc) gradients() []svgGradient {
+	return append(doc.Linear, doc.Radial...)
+}
+
+func (doc *svgGradientDoc) id() string {
+	if g := doc.gradients(); len(g) == 1 {
+		return g[0].ID
+	}
+	return ""
+}
+
+func (doc *svgGradientDoc) stops() []svgStop {
+	if g := doc.gradients(); len(g) == 1 {
+		return g[0].Stop
+	}
+	return nil
+}
+
+func TestGradientIDs(t *testing.T) {
+	c, err := Encode("hello, world", L)
+	if err != nil {
+		t.Fatal(err)
+	}
+	red := color.NRGBA{0xc0, 0, 0, 0xff}
+	blue := color.NRGBA{0, 0, 0xc0, 0xff}
+
+	// Several codes inlined in one page each use their own gradient.
+	var page bytes.Buffer
+	page.WriteString("<html>")
+	for _, opt := range []RenderOption{
+		LinearGradient(red, blue, 0),
+		LinearGradient(blue, red, 0),
+		LinearGradient(red, blue, 90),
+		RadialGradient(red, blue),
+	} {
+		page.Write(c.HTML(HTMLSVG(true), opt))
+	}
+	page.WriteString("</html>")
+	var doc struct {
+		SVG []svgGradientDoc `xml:"svg"`
+	}
+	if err := xml.Unmarshal(page.Bytes(), &doc); err != nil {
+		t.Fatalf("xml.Unmarshal: %v", err)
+	}
+	if len(doc.SVG) != 4 {
+		t.Fatalf("page has %d <svg> elements, want 4", len(doc.SVG))
+	}
+	seen := make(map[string]bool)
+	for i, svg := range doc.SVG {
+		id := svg.id()
+		if id == "" || seen[id] {
+			t.Errorf("svg %d: gradient id %q is missing or reused", i, id)
+		}
+		seen[id] = true
+		if svg.Path.Fill != "url(#"+id+")" {
+			t.Errorf("svg %d: path fill = %q, want url(#%s)", i, svg.Path.Fill, id)
+		}
+	}
+
+	// The same gradient on the same code has the same id.
+	if a, b := c.SVG(RadialGradient(red, blue)), c.SVG(RadialGradient(red, blue)); !bytes.Equal(a, b) {
+		t.Errorf("SVG with identical gradients differs")
+	}
+}
@@ -0,0 +1,105 @@
+// Copyright 2011 The Go Authors.  All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package qr
+
+// GS1 element strings.
+
+import (
+	"fmt"
+	"strings"
+)
+
+// GS is the group separator that ends a variable-length
+// GS1 element, written as FNC1 in the code.
+const GS = "\x1d"
+
+// A GS1Element is one element of a GS1 element string:
+// an Application Identifier, such as "01" for a GTIN or
+// "17" for an expiry date, and its value.
+type GS1Element struct {
+	AI    string
+	Value string
+}
+
+func (e GS1Element) String() string {
+	return "(" + e.AI + ")" + e.Value
+}
+
+// gs1AILen gives the number of digits in the Application Identifiers
+// starting with each two-digit prefix.  Prefixes not listed are not
+// assigned.
+var gs1AILen = map[string]int{
+	"00": 2, "01": 2, "02": 2,
+	"10": 2, "11": 2, "12": 2, "13": 2, "15": 2, "16": 2, "17": 2,
+	"20": 2, "21": 2, "22": 2, "23": 3, "24": 3, "25": 3,
+	"30": 2, "31": 4, "32": 4, "33": 4, "34": 4, "35": 4, "36": 4, "37": 2, "39": 4,
+	"40": 3, "41": 3, "42": 3, "43": 4,
+	"70": 4, "71": 3, "72": 4,
+	"80": 4, "81": 4, "82": 4,
+	"90": 2, "91": 2, "92": 2, "93": 2, "94": 2, "95": 2, "96": 2, "97": 2, "98": 2, "99": 2,
+}
+
+// gs1FixedLen gives the total length, AI included, of the elements
+// whose AIs start with each two-digit prefix and that GS1 predefines
+// as fixed-length.  These elements are not followed by GS.
+var gs1FixedLen = map[string]int{
+	"00": 20, "01": 16, "02": 16, "03": 16, "04": 18,
+	"11": 8, "12": 8, "13": 8, "14": 8, "15": 8, "16": 8, "17": 8, "18": 8, "19": 8,
+	"20": 4,
+	"31": 10, "32": 10, "33": 10, "34": 10, "35": 10, "36": 10,
+	"41": 16,
+}
+
+// ParseGS1 parses s, the Text of a code in GS1 mode, into its elements.
+// Elements of the lengths predefined by the GS1 General Specifications,
+// such as GTINs and dates, are split by length; the others end at GS
+// or at the end of s.  A GS after a fixed-length element is allowed.
+// ParseGS1 checks that every AI is assigned and every value is present,
+// but not the syntax of the values.
+func ParseGS1(s string) ([]GS1Element, error) {
+	var list []GS1Element
+	for pos := 0; pos < len(s); {
+		if len(s)-pos < 2 {
+			return nil, fmt.Errorf("truncated GS1 element at offset %d", pos)
+		}
+		prefix := s[pos : pos+2]
+		n, ok := gs1AILen[prefix]
+		if !ok || len(s)-pos < n {
+			return nil, fmt.Errorf("invalid GS1 application identifier at offset %d: %q", pos, s[pos:])
+		}
+		ai := s[pos : pos+n]
+		for i := 0; i < n; i++ {
+			if ai[i] < '0' || '9' < ai[i] {
+				return nil, fmt.Errorf("invalid GS1 application identifier at offset %d: %q", pos, ai)
+			}
+		}
+		var end, next int
+		if fixed, ok := gs1FixedLen[prefix]; ok {
+			end = pos + fixed
+			if end > len(s) || strings.Contains(s[pos+n:end], GS) {
+				return nil, fmt.Errorf("short value for GS1 AI (%s) at offset %d, want %d characters", ai, pos, fixed-n)
+			}
+			next = end
+			if strings.HasPrefix(s[end:], GS) {
+				next++
+			}
+		} else {
+			end = strings.Index(s[pos+n:], GS)
+			if end < 0 {
+				end = len(s)
+				next = end
+			} else {
+				end += pos + n
+				next = end + 1
+			}
+			if end == pos+n {
+				return nil, fmt.Errorf("empty value for GS1 AI (%s) at offset %d", ai, pos)
+			}
+		}
+		list = append(list, GS1Element{AI: ai, Value: s[pos+n : end]})
+		pos = next
+	}
+	return list, nil
+}
@@ -0,0 +1,76 @@
+// Copyright 2011 The Go Authors.  All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package qr
+
+import (
+	"reflect"
+	"testing"
+
+	"github.com/inkstray/rsc-qr/coding"
+)
+
+var parseGS1Tests = []struct {
+	in   string
+	want []GS1Element
+}{
+	{"0109501101530003", []GS1Element{{"01", "09501101530003"}}},
+	{"01095011015300031725010110AB-123", []GS1Element{{"01", "09501101530003"}, {"17", "250101"}, {"10", "AB-123"}}},
+	{"10AB1\x1d21X\x1d3103000250", []GS1Element{{"10", "AB1"}, {"21", "X"}, {"3103", "000250"}}},
+	{"0109501101530003\x1d400PO42", []GS1Element{{"01", "09501101530003"}, {"400", "PO42"}}},
+	{"8200https://example.com", []GS1Element{{"8200", "https://example.com"}}},
+	{"", nil},
+}
+
+func TestParseGS1(t *testing.T) {
+	for _, tt := range parseGS1Tests {
+		list, err := ParseGS1(tt.in)
+		if err != nil || !reflect.DeepEqual(list, tt.want) {
+			t.Errorf("ParseGS1(%q) = %v, %v, want %v", tt.in, list, err, tt.want)
+		}
+	}
+	for _, bad := range []string{
+		"0",             // truncated AI
+		"0512",          // unassigned AI
+		"01123",         // short GTIN
+		"0112345\x1d67", // GS inside fixed-length value
+		"10\x1d21X",     // empty value
+		"4A0X",          // non-digit AI
+	} {
+		if list, err := ParseGS1(bad); err == nil {
+			t.Errorf("ParseGS1(%q) = %v, want error", bad, list)
+		}
+	}
+}
+
+func TestDecodeGS1(t *testing.T) {
+	cc, err := coding.Encode(3, coding.M,
+		coding.Raw{Mode: uint(coding.ModeFNC1First)},
+		coding.Num("010950110153000317250101"),
+		coding.Alpha("10AB-1%21X%%"),
+	)
+	if err != nil {
+		t.Fatal(err)
+	}
+	res, err := Decode(&Code{Bitmap: cc.Bitmap, Size: cc.Size, Stride: cc.Stride})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if want := "010950110153000317250101" + "10AB-1\x1d21X%"; res.Text != want {
+		t.Errorf("Decode = %q, want %q", res.Text, want)
+	}
+	want := []GS1Element{{"01", "09501101530003"}, {"17", "250101"}, {"10", "AB-1"}, {"21", "X%"}}
+	if !reflect.DeepEqual(res.GS1, want) {
+		t.Errorf("Decode GS1 = %v, want %v", res.GS1, want)
+	}
+
+	// Without FNC1, % is itself and there are no elements.
+	c, err := Encode("10AB-1%21X", M)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if res, err := Decode(c); err != nil || res.Text != "10AB-1%21X" || res.GS1 != nil {
+		t.Errorf("Decode(plain) = %+v, %v", res, err)
+	}
+}
@@ -0,0 +1,85 @@
+// Copyright 2011 The Go Authors.  All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package qr
+
+// HTML writer for QR codes.
+
+import (
+	"bytes"
+	"fmt"
+	"image/color"
+	"io"
+	"strconv"
+)
+
+// HTML returns an HTML fragment displaying the code, for embedding
+// in a page without a separate image request.  By default the fragment
+// is a <table> whose cells are styled inline, with each horizontal run
+// of same-colored pixels drawn as a single cell; with HTMLSVG(true)
+// it is an inline <svg> element.
+//
+// Each QR pixel is ModuleSize CSS pixels wide, or PhysicalModuleSize
+// millimeters if that option is set.  The fragment contains only
+// generated markup and numbers, so it is safe to insert into a page
+// without further escaping.
+func (c *Code) HTML(opts ...RenderOption) []byte {
+	r := c.render(opts)
+	var b bytes.Buffer
+	d := c.Size + 2*r.margin
+	if r.htmlSVG {
+		c.writeSVG(&b, &r, cssLength(&r, d))
+		return b.Bytes()
+	}
+	fmt.Fprintf(&b, `<table style="border-collapse:collapse;border-spacing:0;border:0;margin:0;padding:0;background:%s">`+"\n",
+		cssColor(r.bg))
+	for y := 0; y < d; y++ {
+		fmt.Fprintf(&b, `<tr style="height:%s">`, cssLength(&r, 1))
+		for x := 0; x < d; {
+			black := c.Black(x-r.margin, y-r.margin)
+			w := 1
+			for x+w < d && c.Black(x+w-r.margin, y-r.margin) == black {
+				w++
+			}
+			col := r.bg
+			if black {
+				col = r.fg
+			}
+			b.WriteString("<td")
+			if w > 1 {
+				fmt.Fprintf(&b, ` colspan="%d"`, w)
+			}
+			fmt.Fprintf(&b, ` style="width:%s;height:%s;padding:0;border:0;background:%s"></td>`,
+				cssLength(&r, w), cssLength(&r, 1), cssColor(col))
+			x += w
+		}
+		b.WriteString("</tr>\n")
+	}
+	b.WriteString("</table>\n")
+	return b.Bytes()
+}
+
+// WriteHTML writes an HTML fragment displaying the code to w.
+// See HTML.
+func (c *Code) WriteHTML(w io.Writer, opts ...RenderOption) error {
+	_, err := w.Write(c.HTML(opts...))
+	return err
+}
+
+// cssLength returns the CSS length of n QR pixels.
+func cssLength(r *render, n int) string {
+	if r.mm > 0 {
+		return strconv.FormatFloat(float64(n)*r.mm, 'f', -1, 64) + "mm"
+	}
+	return strconv.Itoa(n*r.scale) + "px"
+}
+
+// cssColor returns the CSS color value for col.
+func cssColor(col color.Color) string {
+	n := color.NRGBAModel.Convert(col).(color.NRGBA)
+	if n.A != 0xff {
+		return fmt.Sprintf("rgba(%d,%d,%d,%.3g)", n.R, n.G, n.B, float64(n.A)/0xff)
+	}
+	return fmt.Sprintf("#%02x%02x%02x", n.R, n.G, n.B)
+}
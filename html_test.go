@@ -0,0 +1,80 @@
+// Copyright 2011 The Go Authors.  All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package qr
+
+import (
+	"encoding/xml"
+	"image/color"
+	"strings"
+	"testing"
+)
+
+func TestHTML(t *testing.T) {
+	c, err := Encode("hello, world", L)
+	if err != nil {
+		t.Fatal(err)
+	}
+	html := c.HTML(ModuleSize(3), Margin(1), Foreground(color.NRGBA{0x11, 0x22, 0x33, 0xff}))
+
+	// The table is well-formed XML; its cells reproduce the bitmap.
+	var doc struct {
+		Rows []struct {
+			Cells []struct {
+				Colspan int    `xml:"colspan,attr"`
+				Style   string `xml:"style,attr"`
+			} `xml:"td"`
+		} `xml:"tr"`
+	}
+	if err := xml.Unmarshal(html, &doc); err != nil {
+		t.Fatalf("xml.Unmarshal: %v\n%s", err, html)
+	}
+	d := c.Size + 2
+	if len(doc.Rows) != d {
+		t.Fatalf("table has %d rows, want %d", len(doc.Rows), d)
+	}
+	for y, row := range doc.Rows {
+		x := 0
+		for _, td := range row.Cells {
+			w := td.Colspan
+			if w == 0 {
+				w = 1
+			}
+			black := strings.HasSuffix(td.Style, "background:#112233")
+			if !black && !strings.HasSuffix(td.Style, "background:#ffffff") {
+				t.Fatalf("row %d: bad cell style %q", y, td.Style)
+			}
+			if !strings.Contains(td.Style, "height:3px") {
+				t.Errorf("row %d: cell style %q lacks height:3px", y, td.Style)
+			}
+			for i := 0; i < w; i++ {
+				if got := c.Black(x-1, y-1); got != black {
+					t.Fatalf("pixel (%d, %d) = %v, want %v", x-1, y-1, black, got)
+				}
+				x++
+			}
+		}
+		if x != d {
+			t.Fatalf("row %d is %d pixels wide, want %d", y, x, d)
+		}
+	}
+}
+
+func TestHTMLSVG(t *testing.T) {
+	c, err := Encode("hello, world", L)
+	if err != nil {
+		t.Fatal(err)
+	}
+	html := string(c.HTML(HTMLSVG(true), PhysicalModuleSize(0.5), Margin(0)))
+	if !strings.HasPrefix(html, "<svg ") {
+		t.Fatalf("HTML(HTMLSVG(true)) does not start with <svg>:\n%s", html)
+	}
+	want := ` width="10.5mm" height="10.5mm" viewBox="0 0 21 21"`
+	if !strings.Contains(html, want) {
+		t.Errorf("HTML(HTMLSVG(true)) lacks %q:\n%s", want, html)
+	}
+	if svg := string(c.SVG(Margin(0))); html[strings.Index(html, "<path"):] != svg[strings.Index(svg, "<path"):] {
+		t.Errorf("HTML(HTMLSVG(true)) path differs from SVG")
+	}
+}
@@ -0,0 +1,86 @@
+// Copyright 2011 The Go Authors.  All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package qr
+
+// Inline image writers for terminals.
+
+import (
+	"bufio"
+	"encoding/base64"
+	"fmt"
+	"io"
+	"os"
+)
+
+// ITerm writes the code to w as a PNG image wrapped in the
+// iTerm2 inline image escape sequence (OSC 1337), which iTerm2,
+// WezTerm, and some other terminals display as an image.
+func (c *Code) ITerm(w io.Writer, opts ...RenderOption) error {
+	png := c.PNG(opts...)
+	bw := bufio.NewWriter(w)
+	fmt.Fprintf(bw, "\x1b]1337;File=inline=1;size=%d;preserveAspectRatio=1:", len(png))
+	enc := base64.NewEncoder(base64.StdEncoding, bw)
+	enc.Write(png)
+	enc.Close()
+	bw.WriteString("\a\n")
+	return bw.Flush()
+}
+
+// Kitty writes the code to w as a PNG image using the Kitty
+// terminal graphics protocol, which Kitty and some other
+// terminals display as an image.
+func (c *Code) Kitty(w io.Writer, opts ...RenderOption) error {
+	data := base64.StdEncoding.EncodeToString(c.PNG(opts...))
+	bw := bufio.NewWriter(w)
+	// The protocol limits each escape sequence to 4096 bytes of data.
+	const chunk = 4096
+	for first := true; first || len(data) > 0; first = false {
+		n := len(data)
+		if n > chunk {
+			n = chunk
+		}
+		more := 0
+		if n < len(data) {
+			more = 1
+		}
+		if first {
+			fmt.Fprintf(bw, "\x1b_Gf=100,a=T,m=%d;", more)
+		} else {
+			fmt.Fprintf(bw, "\x1b_Gm=%d;", more)
+		}
+		bw.WriteString(data[:n])
+		bw.WriteString("\x1b\\")
+		data = data[n:]
+	}
+	bw.WriteString("\n")
+	return bw.Flush()
+}
+
+// Inline writes the code to w for display in the terminal, choosing
+// the format based on the environment variables that terminals set:
+// Kitty if the terminal is Kitty, ITerm if it is iTerm2 or WezTerm,
+// and otherwise Terminal, which works everywhere.
+func (c *Code) Inline(w io.Writer, opts ...RenderOption) error {
+	switch detectTerminal(os.Getenv) {
+	case "kitty":
+		return c.Kitty(w, opts...)
+	case "iterm":
+		return c.ITerm(w, opts...)
+	}
+	return c.Terminal(w, opts...)
+}
+
+// detectTerminal returns the inline image protocol supported by the
+// terminal described by the environment: "kitty", "iterm", or "".
+func detectTerminal(getenv func(string) string) string {
+	switch {
+	case getenv("KITTY_WINDOW_ID") != "", getenv("TERM") == "xterm-kitty":
+		return "kitty"
+	case getenv("TERM_PROGRAM") == "iTerm.app", getenv("LC_TERMINAL") == "iTerm2",
+		getenv("TERM_PROGRAM") == "WezTerm":
+		return "iterm"
+	}
+	return ""
+}
@@ -0,0 +1,83 @@
+// Copyright 2011 The Go Authors.  All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package qr
+
+import (
+	"bytes"
+	"encoding/base64"
+	"fmt"
+	"strings"
+	"testing"
+)
+
+func TestITerm(t *testing.T) {
+	c, err := Encode("hello, world", L)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var buf bytes.Buffer
+	if err := c.ITerm(&buf); err != nil {
+		t.Fatal(err)
+	}
+	png := c.PNG()
+	want := fmt.Sprintf("\x1b]1337;File=inline=1;size=%d;preserveAspectRatio=1:%s\a\n", len(png), base64.StdEncoding.EncodeToString(png))
+	if buf.String() != want {
+		t.Errorf("ITerm = %q, want %q", buf.String(), want)
+	}
+}
+
+func TestKitty(t *testing.T) {
+	// A large code needs several chunks.
+	c, err := Encode(strings.Repeat("hello, world ", 100), L)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var buf bytes.Buffer
+	if err := c.Kitty(&buf, ModuleSize(4)); err != nil {
+		t.Fatal(err)
+	}
+	seqs := strings.Split(strings.TrimSuffix(buf.String(), "\x1b\\\n"), "\x1b\\")
+	if len(seqs) < 2 {
+		t.Fatalf("Kitty wrote %d sequences, want several", len(seqs))
+	}
+	var data strings.Builder
+	for i, s := range seqs {
+		prefix := "\x1b_Gm=1;"
+		switch {
+		case i == 0:
+			prefix = "\x1b_Gf=100,a=T,m=1;"
+		case i == len(seqs)-1:
+			prefix = "\x1b_Gm=0;"
+		}
+		if !strings.HasPrefix(s, prefix) || len(s)-len(prefix) > 4096 {
+			t.Fatalf("sequence %d = %.40q..., want prefix %q and at most 4096 bytes", i, s, prefix)
+		}
+		data.WriteString(s[len(prefix):])
+	}
+	png, err := base64.StdEncoding.DecodeString(data.String())
+	if err != nil || !bytes.Equal(png, c.PNG(ModuleSize(4))) {
+		t.Errorf("Kitty data does not decode to PNG: %v", err)
+	}
+}
+
+func TestDetectTerminal(t *testing.T) {
+	for _, tt := range []struct {
+		env  map[string]string
+		want string
+	}{
+		{map[string]string{}, ""},
+		{map[string]string{"TERM": "xterm-256color"}, ""},
+		{map[string]string{"TERM": "xterm-kitty"}, "kitty"},
+		{map[string]string{"KITTY_WINDOW_ID": "1"}, "kitty"},
+		{map[string]string{"TERM_PROGRAM": "iTerm.app"}, "iterm"},
+		{map[string]string{"LC_TERMINAL": "iTerm2"}, "iterm"},
+		{map[string]string{"TERM_PROGRAM": "WezTerm"}, "iterm"},
+	} {
+		getenv := func(k string) string { return tt.env[k] }
+		if got := detectTerminal(getenv); got != tt.want {
+			t.Errorf("detectTerminal(%v) = %q, want %q", tt.env, got, tt.want)
+		}
+	}
+}
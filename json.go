@@ -0,0 +1,91 @@
+// Copyright 2011 The Go Authors.  All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package qr
+
+// JSON encoding of QR codes.
+
+import (
+	"encoding/json"
+	"fmt"
+
+	"github.com/inkstray/rsc-qr/coding"
+)
+
+// A jsonCode is the JSON form of a Code.
+type jsonCode struct {
+	Size      int          `json:"size"`
+	Bitmap    []byte       `json:"bitmap"`
+	Scale     int          `json:"scale"`
+	Level     coding.Level `json:"level"`
+	QuietZone int          `json:"quietZone,omitempty"`
+	Newlines  Newlines     `json:"newlines,omitempty"`
+	Info      *coding.Info `json:"info,omitempty"`
+}
+
+// MarshalJSON encodes c as a JSON object holding its size, its bitmap
+// as base64 with (size+7)/8 bytes per row, its other fields, and its
+// encode metadata, so that codes can be passed between programs
+// without re-encoding their payloads.
+func (c *Code) MarshalJSON() ([]byte, error) {
+	stride := (c.Size + 7) / 8
+	bitmap := make([]byte, 0, stride*c.Size)
+	for y := 0; y < c.Size; y++ {
+		bitmap = append(bitmap, c.Bitmap[y*c.Stride:y*c.Stride+stride]...)
+	}
+	return json.Marshal(jsonCode{
+		Size:      c.Size,
+		Bitmap:    bitmap,
+		Scale:     c.Scale,
+		Level:     coding.Level(c.Level),
+		QuietZone: c.QuietZone,
+		Newlines:  c.Newlines,
+		Info:      c.Info,
+	})
+}
+
+// maxJSONScale is the largest Scale accepted by UnmarshalJSON,
+// so that untrusted input cannot request enormous images.
+const maxJSONScale = 128
+
+// UnmarshalJSON decodes a Code encoded by MarshalJSON.
+// It rejects codes with an invalid level or newline policy,
+// a scale outside 0 to 128, a quiet zone wider than the bitmap allows,
+// or an Info whose version does not match the size of the bitmap.
+func (c *Code) UnmarshalJSON(data []byte) error {
+	var j jsonCode
+	if err := json.Unmarshal(data, &j); err != nil {
+		return err
+	}
+	stride := (j.Size + 7) / 8
+	if j.Size <= 0 || len(j.Bitmap) != stride*j.Size {
+		return fmt.Errorf("QR bitmap has %d bytes, want %d for size %d", len(j.Bitmap), stride*j.Size, j.Size)
+	}
+	if j.Level < coding.L || j.Level > coding.H {
+		return fmt.Errorf("%w %d", coding.ErrBadLevel, int(j.Level))
+	}
+	if j.Scale < 0 || j.Scale > maxJSONScale {
+		return fmt.Errorf("invalid QR scale %d", j.Scale)
+	}
+	if j.QuietZone < 0 || 2*j.QuietZone > j.Size {
+		return fmt.Errorf("invalid QR quiet zone %d for size %d", j.QuietZone, j.Size)
+	}
+	if j.Newlines < NewlinesKeep || j.Newlines > NewlinesReject {
+		return fmt.Errorf("invalid newline policy %d", int(j.Newlines))
+	}
+	if j.Info != nil && 4*int(j.Info.Version)+17 != j.Size-2*j.QuietZone {
+		return fmt.Errorf("%w %d for size %d with quiet zone %d", ErrBadVersion, int(j.Info.Version), j.Size, j.QuietZone)
+	}
+	*c = Code{
+		Bitmap:    j.Bitmap,
+		Size:      j.Size,
+		Stride:    stride,
+		Scale:     j.Scale,
+		Level:     Level(j.Level),
+		QuietZone: j.QuietZone,
+		Newlines:  j.Newlines,
+		Info:      j.Info,
+	}
+	return nil
+}
@@ -0,0 +1,55 @@
+// Copyright 2011 The Go Authors.  All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package qr
+
+import (
+	"encoding/json"
+	"fmt"
+	"strings"
+	"testing"
+)
+
+func TestJSON(t *testing.T) {
+	c, err := newEncoder(t, WithLevel(Q), WithQuietZone(1), WithScale(4), WithUTF8ECI(true)).Encode("héllo, 12345678")
+	if err != nil {
+		t.Fatal(err)
+	}
+	data, err := json.Marshal(c)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !strings.Contains(string(data), `"level":"Q"`) {
+		t.Errorf("JSON lacks level Q: %s", data)
+	}
+	var d Code
+	if err := json.Unmarshal(data, &d); err != nil {
+		t.Fatal(err)
+	}
+	if !c.Equal(&d) || d.Stride != (d.Size+7)/8 {
+		t.Errorf("Unmarshal(Marshal(c)) differs from c")
+	}
+	if d.Info == nil || d.Info.Version != c.Info.Version || d.Info.Mask != c.Info.Mask ||
+		d.Info.DataBits != c.Info.DataBits || fmt.Sprint(d.Info.Segments) != fmt.Sprint(c.Info.Segments) {
+		t.Errorf("Info = %+v, want %+v", d.Info, c.Info)
+	}
+
+	for _, bad := range []string{
+		`{"size":21,"bitmap":"AAAA","level":"L"}`,
+		`{"size":1,"bitmap":"AA==","level":"X"}`,
+		`{"size":0,"bitmap":"","level":"L"}`,
+		`{"size":1,"bitmap":"AA==","level":"L","scale":-1}`,
+		`{"size":1,"bitmap":"AA==","level":"L","scale":1000000}`,
+		`{"size":1,"bitmap":"AA==","level":"L","quietZone":-1}`,
+		`{"size":1,"bitmap":"AA==","level":"L","quietZone":100000000}`,
+		`{"size":1,"bitmap":"AA==","level":"L","newlines":99}`,
+		`{"size":1,"bitmap":"AA==","level":"L","info":{"version":2,"level":"L","mask":0}}`,
+		`{"size":1,"bitmap":"AA==","level":"L","info":{"version":99,"level":"L","mask":0}}`,
+		`{"size":1,"bitmap":"AA==","level":"L","info":{"version":1,"level":"L","mask":9}}`,
+	} {
+		if err := json.Unmarshal([]byte(bad), &d); err == nil {
+			t.Errorf("Unmarshal(%s) succeeded, want error", bad)
+		}
+	}
+}
@@ -0,0 +1,326 @@
+// Copyright 2011 The Go Authors.  All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package qr
+
+// Netpbm and XBM writers for QR codes, and a netpbm reader.
+// These formats are trivial to parse, which makes them
+// convenient for embedded systems and C programs.
+
+import (
+	"bufio"
+	"bytes"
+	"errors"
+	"fmt"
+	"image"
+	"image/color"
+	"io"
+)
+
+// packedRows calls f for each row of the image of c drawn with r,
+// passing the row as packed bits, most significant bit first,
+// with 1 for black and 0 for white.  Unused bits at the end of
+// each row are 0.  The row slice is reused between calls.
+func (c *Code) packedRows(r render, f func(row []byte)) {
+	for it := c.rows(r); it.Next(); {
+		f(it.Bits())
+	}
+}
+
+// PBM returns a binary (P4) netpbm bitmap displaying the code.
+// Colors are ignored.
+func (c *Code) PBM(opts ...RenderOption) []byte {
+	var b bytes.Buffer
+	c.writePBM(&b, c.render(opts))
+	return b.Bytes()
+}
+
+// writePBM writes the P4 bitmap of c drawn with r to b.
+func (c *Code) writePBM(b io.Writer, r render) {
+	d := (c.Size + 2*r.margin) * r.scale
+	fmt.Fprintf(b, "P4\n%d %d\n", d, d)
+	c.packedRows(r, func(row []byte) { b.Write(row) })
+}
+
+// PlainPBM returns a plain text (P1) netpbm bitmap displaying the code.
+// Colors are ignored.
+func (c *Code) PlainPBM(opts ...RenderOption) []byte {
+	r := c.render(opts)
+	d := (c.Size + 2*r.margin) * r.scale
+	var b bytes.Buffer
+	fmt.Fprintf(&b, "P1\n%d %d\n", d, d)
+	line := make([]byte, 0, 2*d)
+	c.packedRows(r, func(row []byte) {
+		line = line[:0]
+		for x := 0; x < d; x++ {
+			// Lines must be at most 70 characters.
+			if x > 0 && x%35 == 0 {
+				line = append(line, '\n')
+			}
+			line = append(line, '0'+row[x/8]>>uint(7-x&7)&1)
+		}
+		line = append(line, '\n')
+		b.Write(line)
+	})
+	return b.Bytes()
+}
+
+// PGM returns a binary (P5) netpbm graymap displaying the code,
+// using the gray levels of the foreground and background colors.
+func (c *Code) PGM(opts ...RenderOption) []byte {
+	var b bytes.Buffer
+	c.writePGM(&b, c.render(opts))
+	return b.Bytes()
+}
+
+// writePGM writes the P5 graymap of c drawn with r to b.
+func (c *Code) writePGM(b io.Writer, r render) {
+	d := (c.Size + 2*r.margin) * r.scale
+	fg := color.GrayModel.Convert(r.fg).(color.Gray).Y
+	bg := color.GrayModel.Convert(r.bg).(color.Gray).Y
+	fmt.Fprintf(b, "P5\n%d %d\n255\n", d, d)
+	line := make([]byte, d)
+	c.packedRows(r, func(row []byte) {
+		for x := range line {
+			line[x] = bg
+			if row[x/8]&(0x80>>uint(x&7)) != 0 {
+				line[x] = fg
+			}
+		}
+		b.Write(line)
+	})
+}
+
+// XBM returns an X bitmap displaying the code, as C source code
+// declaring name_width, name_height, and name_bits.
+// Colors are ignored.
+func (c *Code) XBM(name string, opts ...RenderOption) []byte {
+	r := c.render(opts)
+	d := (c.Size + 2*r.margin) * r.scale
+	var b bytes.Buffer
+	fmt.Fprintf(&b, "#define %s_width %d\n#define %s_height %d\n", name, d, name, d)
+	fmt.Fprintf(&b, "static unsigned char %s_bits[] = {", name)
+	n := 0
+	c.packedRows(r, func(row []byte) {
+		for _, v := range row {
+			if n > 0 {
+				b.WriteString(",")
+			}
+			if n%12 == 0 {
+				b.WriteString("\n  ")
+			} else {
+				b.WriteString(" ")
+			}
+			// XBM stores the leftmost pixel in the low bit.
+			fmt.Fprintf(&b, "0x%02x", reverseBits(v))
+			n++
+		}
+	})
+	b.WriteString("};\n")
+	return b.Bytes()
+}
+
+// reverseBits returns v with its bits in reverse order.
+func reverseBits(v byte) byte {
+	v = v>>4 | v<<4
+	v = v>>2&0x33 | v<<2&0xcc
+	v = v>>1&0x55 | v<<1&0xaa
+	return v
+}
+
+// WritePBM writes a binary netpbm bitmap displaying the code to w.
+// See PBM.
+func (c *Code) WritePBM(w io.Writer, opts ...RenderOption) error {
+	b := bufio.NewWriter(w)
+	c.writePBM(b, c.render(opts))
+	return b.Flush()
+}
+
+// WritePGM writes a binary netpbm graymap displaying the code to w.
+// See PGM.
+func (c *Code) WritePGM(w io.Writer, opts ...RenderOption) error {
+	b := bufio.NewWriter(w)
+	c.writePGM(b, c.render(opts))
+	return b.Flush()
+}
+
+// WriteXBM writes an X bitmap displaying the code to w.
+// See XBM.
+func (c *Code) WriteXBM(w io.Writer, name string, opts ...RenderOption) error {
+	_, err := w.Write(c.XBM(name, opts...))
+	return err
+}
+
+// isNetpbm reports whether magic, the first bytes of a file,
+// starts a netpbm bitmap or graymap that readNetpbm can read.
+func isNetpbm(magic []byte) bool {
+	return len(magic) >= 2 && magic[0] == 'P' && bytes.IndexByte([]byte("1245"), magic[1]) >= 0
+}
+
+// readNetpbm reads a netpbm bitmap (P1 or P4) or graymap (P2 or P5)
+// from r as a gray image.  If check is not nil, readNetpbm calls it
+// with the size of the image before reading the pixels, and stops
+// if it returns an error.
+func readNetpbm(r *bufio.Reader, check func(w, h int) error) (*image.Gray, error) {
+	var magic [2]byte
+	if _, err := io.ReadFull(r, magic[:]); err != nil {
+		return nil, err
+	}
+	if !isNetpbm(magic[:]) {
+		return nil, fmt.Errorf("unsupported netpbm format %q", magic[:])
+	}
+	kind := magic[1]
+	w, err := pbmInt(r)
+	if err != nil {
+		return nil, err
+	}
+	h, err := pbmInt(r)
+	if err != nil {
+		return nil, err
+	}
+	maxval := 1
+	if kind == '2' || kind == '5' {
+		if maxval, err = pbmInt(r); err != nil {
+			return nil, err
+		}
+	}
+	if w <= 0 || h <= 0 || w > 1<<16 || h > 1<<16 || maxval <= 0 || maxval > 65535 {
+		return nil, fmt.Errorf("invalid netpbm header: %dx%d, maximum %d", w, h, maxval)
+	}
+	if check != nil {
+		if err := check(w, h); err != nil {
+			return nil, err
+		}
+	}
+	if kind == '4' || kind == '5' {
+		// A single whitespace byte separates the header from raw data.
+		if _, err := r.ReadByte(); err != nil {
+			return nil, err
+		}
+	}
+
+	// The header alone can claim a huge image, so grow the pixels
+	// as data arrives rather than allocating them all up front.
+	n := w * h
+	if n > 1<<20 {
+		n = 1 << 20
+	}
+	pix := make([]byte, 0, n)
+	switch kind {
+	case '1':
+		for i := 0; i < w*h; i++ {
+			c, err := pbmSkip(r)
+			if err != nil {
+				return nil, err
+			}
+			r.Discard(1)
+			if c != '0' && c != '1' {
+				return nil, fmt.Errorf("invalid plain PBM pixel %q", c)
+			}
+			pix = append(pix, 255*('1'-c)) // 1 is black
+		}
+	case '2':
+		for i := 0; i < w*h; i++ {
+			v, err := pbmInt(r)
+			if err != nil {
+				return nil, err
+			}
+			pix = append(pix, gray8(v, maxval))
+		}
+	case '4':
+		row := make([]byte, (w+7)/8)
+		for y := 0; y < h; y++ {
+			if _, err := io.ReadFull(r, row); err != nil {
+				return nil, err
+			}
+			for x := 0; x < w; x++ {
+				v := uint8(255)
+				if row[x/8]&(0x80>>uint(x&7)) != 0 {
+					v = 0
+				}
+				pix = append(pix, v)
+			}
+		}
+	case '5':
+		n := 1
+		if maxval > 255 {
+			n = 2
+		}
+		row := make([]byte, n*w)
+		for y := 0; y < h; y++ {
+			if _, err := io.ReadFull(r, row); err != nil {
+				return nil, err
+			}
+			for x := 0; x < w; x++ {
+				v := int(row[x])
+				if n == 2 {
+					v = int(row[2*x])<<8 | int(row[2*x+1])
+				}
+				pix = append(pix, gray8(v, maxval))
+			}
+		}
+	}
+	return &image.Gray{Pix: pix, Stride: w, Rect: image.Rect(0, 0, w, h)}, nil
+}
+
+// gray8 scales the gray level v, from 0 to maxval, to 0 to 255.
+func gray8(v, maxval int) uint8 {
+	if v > maxval {
+		v = maxval
+	}
+	return uint8((v*255 + maxval/2) / maxval)
+}
+
+// pbmSkip skips whitespace and comments in a netpbm header or plain
+// data and returns the next byte without consuming it.
+func pbmSkip(r *bufio.Reader) (byte, error) {
+	for {
+		c, err := r.ReadByte()
+		if err != nil {
+			if err == io.EOF {
+				err = io.ErrUnexpectedEOF
+			}
+			return 0, err
+		}
+		switch c {
+		case ' ', '\t', '\n', '\r', '\v', '\f':
+			continue
+		case '#':
+			if _, err := r.ReadString('\n'); err != nil {
+				return 0, io.ErrUnexpectedEOF
+			}
+			continue
+		}
+		return c, r.UnreadByte()
+	}
+}
+
+var errNetpbmNumber = errors.New("invalid number in netpbm file")
+
+// pbmInt reads a decimal number from a netpbm header or plain data.
+func pbmInt(r *bufio.Reader) (int, error) {
+	c, err := pbmSkip(r)
+	if err != nil {
+		return 0, err
+	}
+	if c < '0' || '9' < c {
+		return 0, errNetpbmNumber
+	}
+	n := 0
+	for {
+		c, err := r.ReadByte()
+		if err != nil {
+			if err == io.EOF {
+				return n, nil
+			}
+			return 0, err
+		}
+		if c < '0' || '9' < c {
+			return n, r.UnreadByte()
+		}
+		if n = 10*n + int(c-'0'); n > 1<<24 {
+			return 0, errNetpbmNumber
+		}
+	}
+}
@@ -0,0 +1,148 @@
+// Copyright 2011 The Go Authors.  All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package qr
+
+import (
+	"bytes"
+	"fmt"
+	"image/color"
+	"runtime"
+	"strconv"
+	"strings"
+	"testing"
+)
+
+// netpbmTests lists the render options used by the netpbm tests.
+var netpbmTests = [][]RenderOption{
+	{ModuleSize(1)},
+	{ModuleSize(3), Margin(1)},
+	{ModuleSize(2), Margin(0), Foreground(color.Gray{0x40}), Background(color.Gray{0xc0})},
+	{ModuleSize(2), Margin(3), QuietZone(1)},
+}
+
+// checkPixels checks that black reports the pixels of c drawn with opts.
+func checkPixels(t *testing.T, format string, c *Code, opts []RenderOption, d int, black func(x, y int) bool) {
+	t.Helper()
+	r := c.render(opts)
+	if want := (c.Size + 2*r.margin) * r.scale; d != want {
+		t.Errorf("%s: size %d, want %d", format, d, want)
+		return
+	}
+	nbad := 0
+	for y := 0; y < d; y++ {
+		for x := 0; x < d; x++ {
+			if b, want := black(x, y), c.Black(x/r.scale-r.margin, y/r.scale-r.margin); b != want {
+				t.Errorf("%s: pixel %d,%d black = %v, want %v", format, x, y, b, want)
+				if nbad++; nbad >= 10 {
+					t.Fatalf("too many bad pixels")
+				}
+			}
+		}
+	}
+}
+
+func TestNetpbm(t *testing.T) {
+	c, err := Encode("hello, world", L)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, opts := range netpbmTests {
+		// P4
+		var d int
+		p := c.PBM(opts...)
+		var n int
+		if _, err := fmt.Sscanf(string(p), "P4\n%d %d\n", &d, &n); err != nil {
+			t.Fatalf("PBM header: %v", err)
+		}
+		data := p[bytes.IndexByte(p[3:], '\n')+4:]
+		stride := (d + 7) / 8
+		if len(data) != d*stride {
+			t.Fatalf("PBM data is %d bytes, want %d", len(data), d*stride)
+		}
+		checkPixels(t, "PBM", c, opts, d, func(x, y int) bool {
+			return data[y*stride+x/8]&(0x80>>uint(x&7)) != 0
+		})
+
+		// P1
+		f := strings.Fields(string(c.PlainPBM(opts...)))
+		if f[0] != "P1" || f[1] != f[2] {
+			t.Fatalf("PlainPBM header %q", f[:3])
+		}
+		d, _ = strconv.Atoi(f[1])
+		bits := strings.Join(f[3:], "")
+		checkPixels(t, "PlainPBM", c, opts, d, func(x, y int) bool {
+			return bits[y*d+x] == '1'
+		})
+		for _, line := range strings.Split(string(c.PlainPBM(opts...)), "\n") {
+			if len(line) > 70 {
+				t.Errorf("PlainPBM line too long: %d characters", len(line))
+				break
+			}
+		}
+
+		// P5
+		r := c.render(opts)
+		fg := color.GrayModel.Convert(r.fg).(color.Gray).Y
+		p = c.PGM(opts...)
+		if _, err := fmt.Sscanf(string(p), "P5\n%d %d\n255\n", &d, &n); err != nil {
+			t.Fatalf("PGM header: %v", err)
+		}
+		gray := p[len(p)-d*d:]
+		checkPixels(t, "PGM", c, opts, d, func(x, y int) bool {
+			return gray[y*d+x] == fg
+		})
+	}
+}
+
+func TestXBM(t *testing.T) {
+	c, err := Encode("hello, world", L)
+	if err != nil {
+		t.Fatal(err)
+	}
+	opts := []RenderOption{ModuleSize(2)}
+	x := string(c.XBM("qr", opts...))
+	var d, n int
+	if _, err := fmt.Sscanf(x, "#define qr_width %d\n#define qr_height %d\n", &d, &n); err != nil {
+		t.Fatalf("XBM header: %v\n%s", err, x)
+	}
+	body := x[strings.Index(x, "{")+1 : strings.Index(x, "}")]
+	var data []byte
+	for _, f := range strings.Split(body, ",") {
+		v, err := strconv.ParseUint(strings.TrimSpace(f), 0, 8)
+		if err != nil {
+			t.Fatalf("XBM data: %v", err)
+		}
+		data = append(data, byte(v))
+	}
+	stride := (d + 7) / 8
+	if len(data) != d*stride {
+		t.Fatalf("XBM data is %d bytes, want %d", len(data), d*stride)
+	}
+	checkPixels(t, "XBM", c, opts, d, func(x, y int) bool {
+		return data[y*stride+x/8]&(1<<uint(x&7)) != 0
+	})
+}
+
+func TestNetpbmTruncated(t *testing.T) {
+	// A short file claiming a huge image must fail without
+	// allocating memory for the pixels it claims.
+	for _, hdr := range []string{
+		"P1 65536 65536\n",
+		"P2 65536 65536 255\n",
+		"P4 65536 65536\n",
+		"P5 65536 65536 255\n",
+	} {
+		var before, after runtime.MemStats
+		runtime.ReadMemStats(&before)
+		_, err := DecodeReader(strings.NewReader(hdr + "0 1 0 1 0 1"))
+		runtime.ReadMemStats(&after)
+		if err == nil {
+			t.Errorf("DecodeReader(%q) succeeded", hdr)
+		}
+		if n := after.TotalAlloc - before.TotalAlloc; n > 16<<20 {
+			t.Errorf("DecodeReader(%q) allocated %d bytes", hdr, n)
+		}
+	}
+}
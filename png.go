@@ -11,6 +11,9 @@ import (
 	"encoding/binary"
 	"hash"
 	"hash/crc32"
+	"image/color"
+	"image/png"
+	"io"
 )
 
 // PNG returns a PNG image displaying the code.
@@ -18,10 +21,22 @@ import (
 // PNG uses a custom encoder tailored to QR codes.
 // Its compressed size is about 2x away from optimal,
 // but it runs about 20x faster than calling png.Encode
-// on c.Image().
-func (c *Code) PNG() []byte {
+// on c.Image().  Codes drawn with a gradient fill are not
+// bilevel, so PNG encodes them with png.Encode.
+func (c *Code) PNG(opts ...RenderOption) []byte {
 	var p pngWriter
-	return p.encode(c)
+	r := c.render(opts)
+	if r.grad != nil {
+		return p.encodeImage(c, r)
+	}
+	return p.encode(c, r)
+}
+
+// WritePNG writes a PNG image displaying the code to w.
+// See PNG.
+func (c *Code) WritePNG(w io.Writer, opts ...RenderOption) error {
+	_, err := w.Write(c.PNG(opts...))
+	return err
 }
 
 type pngWriter struct {
@@ -34,8 +49,8 @@ type pngWriter struct {
 
 var pngHeader = []byte("\x89PNG\r\n\x1a\n")
 
-func (w *pngWriter) encode(c *Code) []byte {
-	scale := c.Scale
+func (w *pngWriter) encode(c *Code, r render) []byte {
+	scale := r.scale
 	siz := c.Size
 
 	w.buf.Reset()
@@ -44,20 +59,42 @@ func (w *pngWriter) encode(c *Code) []byte {
 	w.buf.Write(pngHeader)
 
 	// Header block
-	binary.BigEndian.PutUint32(w.tmp[0:4], uint32((siz+8)*scale))
-	binary.BigEndian.PutUint32(w.tmp[4:8], uint32((siz+8)*scale))
+	q := r.margin
+	binary.BigEndian.PutUint32(w.tmp[0:4], uint32((siz+2*q)*scale))
+	binary.BigEndian.PutUint32(w.tmp[4:8], uint32((siz+2*q)*scale))
 	w.tmp[8] = 1 // 1-bit
 	w.tmp[9] = 0 // gray
+	if !r.gray() {
+		w.tmp[9] = 3 // paletted
+	}
 	w.tmp[10] = 0
 	w.tmp[11] = 0
 	w.tmp[12] = 0
 	w.writeChunk("IHDR", w.tmp[:13])
 
+	// Palette: index 0 is black, 1 is white, as in the gray image.
+	if !r.gray() {
+		var plte [6]byte
+		var trns [2]byte
+		for i, col := range []color.Color{r.fg, r.bg} {
+			n := color.NRGBAModel.Convert(col).(color.NRGBA)
+			plte[3*i], plte[3*i+1], plte[3*i+2] = n.R, n.G, n.B
+			trns[i] = n.A
+		}
+		w.writeChunk("PLTE", plte[:])
+		if trns != [2]byte{0xff, 0xff} {
+			w.writeChunk("tRNS", trns[:])
+		}
+	}
+
+	// Physical size
+	w.writePHYs(&r)
+
 	// Comment
 	w.writeChunk("tEXt", comment)
 
 	// Data
-	w.zlib.writeCode(c)
+	w.zlib.writeCode(c, r)
 	w.writeChunk("IDAT", w.zlib.bytes.Bytes())
 
 	// End
@@ -66,6 +103,32 @@ func (w *pngWriter) encode(c *Code) []byte {
 	return w.buf.Bytes()
 }
 
+// encodeImage encodes c.Image using png.Encode,
+// adding the pHYs chunk that png.Encode does not write.
+func (w *pngWriter) encodeImage(c *Code, r render) []byte {
+	var img bytes.Buffer
+	png.Encode(&img, &codeImage{c, r})
+	data := img.Bytes()
+	const ihdrEnd = 8 + 4 + 4 + 13 + 4 // signature, IHDR length, type, data, CRC
+	w.buf.Reset()
+	w.buf.Write(data[:ihdrEnd])
+	w.writePHYs(&r)
+	w.buf.Write(data[ihdrEnd:])
+	return w.buf.Bytes()
+}
+
+// writePHYs writes a pHYs chunk recording the physical size
+// of the image, if known.
+func (w *pngWriter) writePHYs(r *render) {
+	if ppm := r.pixelsPerMeter(); ppm > 0 {
+		n := uint32(ppm + 0.5)
+		binary.BigEndian.PutUint32(w.tmp[0:4], n)
+		binary.BigEndian.PutUint32(w.tmp[4:8], n)
+		w.tmp[8] = 1 // meters
+		w.writeChunk("pHYs", w.tmp[:9])
+	}
+}
+
 var comment = []byte("Software\x00QR-PNG http://qr.swtch.com/")
 
 func (w *pngWriter) writeChunk(name string, data []byte) {
@@ -85,14 +148,14 @@ func (w *pngWriter) writeChunk(name string, data []byte) {
 	w.buf.Write(w.wctmp[0:4])
 }
 
-func (b *bitWriter) writeCode(c *Code) {
+func (b *bitWriter) writeCode(c *Code, r render) {
 	const ftNone = 0
 
 	b.adler32.Reset()
 	b.bytes.Reset()
 	b.nbit = 0
 
-	scale := c.Scale
+	scale := r.scale
 	siz := c.Size
 
 	// zlib header
@@ -105,65 +168,60 @@ func (b *bitWriter) writeCode(c *Code) {
 	b.writeBits(1, 1, false) // final block
 	b.writeBits(1, 2, false) // compressed, fixed Huffman tables
 
+	q := r.margin
+	n := (scale*(siz+2*q) + 7) / 8
+
 	// White border.
-	// First row.
-	b.byte(ftNone)
-	n := (scale*(siz+8) + 7) / 8
-	b.byte(255)
-	b.repeat(n-1, 1)
-	// 4*scale rows total.
-	b.repeat((4*scale-1)*(1+n), 1+n)
+	b.whiteRows(q*scale, n)
 
-	for i := 0; i < 4*scale; i++ {
-		b.adler32.WriteNByte(ftNone, 1)
-		b.adler32.WriteNByte(255, n)
+	crop := 0 // rows of the bitmap's quiet zone to omit
+	if q < 0 {
+		crop = -q
 	}
-
 	row := make([]byte, 1+n)
-	for y := 0; y < siz; y++ {
-		row[0] = ftNone
-		j := 1
-		var z uint8
-		nz := 0
-		for x := -4; x < siz+4; x++ {
-			// Raw data.
-			for i := 0; i < scale; i++ {
-				z <<= 1
-				if !c.Black(x, y) {
-					z |= 1
-				}
-				if nz++; nz == 8 {
-					row[j] = z
-					j++
-					nz = 0
+	for y := crop; y < siz-crop; y++ {
+		for sy := 0; sy < scale; sy++ {
+			// Square pixels make every image row of a QR row the same:
+			// write scale-1 copies of the first.
+			if sy > 0 && r.shape == ShapeSquare {
+				b.repeat((scale-1)*(1+n), 1+n)
+				b.adler32.WriteN(row, scale-1)
+				break
+			}
+			row[0] = ftNone
+			j := 1
+			var z uint8
+			nz := 0
+			for x := -q; x < siz+q; x++ {
+				// Raw data.
+				black := c.Black(x, y)
+				for i := 0; i < scale; i++ {
+					z <<= 1
+					if r.shape != ShapeSquare {
+						black = c.imageBlack(&r, (x+q)*scale+i, (y+q)*scale+sy)
+					}
+					if !black {
+						z |= 1
+					}
+					if nz++; nz == 8 {
+						row[j] = z
+						j++
+						nz = 0
+					}
 				}
 			}
+			if j < len(row) {
+				row[j] = z<<uint(8-nz) | 0xff>>uint(nz)
+			}
+			for _, z := range row {
+				b.byte(z)
+			}
+			b.adler32.WriteN(row, 1)
 		}
-		if j < len(row) {
-			row[j] = z
-		}
-		for _, z := range row {
-			b.byte(z)
-		}
-
-		// Scale-1 copies.
-		b.repeat((scale-1)*(1+n), 1+n)
-
-		b.adler32.WriteN(row, scale)
 	}
 
 	// White border.
-	// First row.
-	b.byte(ftNone)
-	b.byte(255)
-	b.repeat(n-1, 1)
-	// 4*scale rows total.
-	b.repeat((4*scale-1)*(1+n), 1+n)
-
-	for i := 0; i < 4*scale; i++ {
-		b.adler32.WriteNByte(ftNone, 1)
-		b.adler32.WriteNByte(255, n)
-	}
+	b.whiteRows(q*scale, n)
 
 	// End of block.
 	b.hcode(256)
@@ -174,6 +232,26 @@ func (b *bitWriter) writeCode(c *Code) {
 	b.bytes.Write(b.tmp[0:4])
 }
 
+// whiteRows writes rows image rows of n white bytes each.
+func (b *bitWriter) whiteRows(rows, n int) {
+	const ftNone = 0
+	if rows <= 0 {
+		return
+	}
+	// First row.
+	b.byte(ftNone)
+	b.run(255, n)
+	// Copies of the first row.
+	if rows > 1 {
+		b.repeat((rows-1)*(1+n), 1+n)
+	}
+
+	for i := 0; i < rows; i++ {
+		b.adler32.WriteNByte(ftNone, 1)
+		b.adler32.WriteNByte(255, n)
+	}
+}
+
 // A bitWriter is a write buffer for bit-oriented data like deflate.
 type bitWriter struct {
 	bytes bytes.Buffer
@@ -6,8 +6,10 @@ package qr
 
 import (
 	"bytes"
+	"encoding/binary"
 	"image"
 	"image/color"
+	"image/draw"
 	"image/png"
 	"io/ioutil"
 	"testing"
@@ -47,6 +49,80 @@ func TestPNG(t *testing.T) {
 	}
 }
 
+func TestPNGQuietZone(t *testing.T) {
+	for _, q := range []int{0, 2, 4, 6} {
+		for _, scale := range []int{1, 3, 8} {
+			c, err := newEncoder(t, WithQuietZone(q), WithScale(scale)).Encode("hello, world")
+			if err != nil {
+				t.Fatal(err)
+			}
+			if c.Size != 21+2*q || c.QuietZone != q {
+				t.Errorf("WithQuietZone(%d): Size, QuietZone = %d, %d, want %d, %d", q, c.Size, c.QuietZone, 21+2*q, q)
+			}
+			m, err := png.Decode(bytes.NewBuffer(c.PNG()))
+			if err != nil {
+				t.Fatal(err)
+			}
+			border := 4 - q
+			if border < 0 {
+				border = 0
+			}
+			d := (c.Size + 2*border) * scale
+			if b := m.Bounds(); b != image.Rect(0, 0, d, d) {
+				t.Fatalf("WithQuietZone(%d), WithScale(%d): bounds = %v, want %dx%d", q, scale, b, d, d)
+			}
+			nbad := 0
+			for y := 0; y < d; y++ {
+				for x := 0; x < d; x++ {
+					v := byte(255)
+					if c.Black(x/scale-border, y/scale-border) {
+						v = 0
+					}
+					if gv := m.At(x, y).(color.Gray).Y; gv != v {
+						t.Errorf("WithQuietZone(%d), WithScale(%d): %d,%d = %d, want %d", q, scale, x, y, gv, v)
+						if nbad++; nbad >= 20 {
+							t.Fatalf("too many bad pixels")
+						}
+					}
+				}
+			}
+		}
+	}
+}
+
+func TestQuietZoneOption(t *testing.T) {
+	for _, q := range []int{0, 2, 6} {
+		c, err := newEncoder(t, WithQuietZone(q), WithScale(3)).Encode("hello, world")
+		if err != nil {
+			t.Fatal(err)
+		}
+		for _, n := range []int{0, 1, 4, 8} {
+			m, err := png.Decode(bytes.NewBuffer(c.PNG(QuietZone(n), Margin(5))))
+			if err != nil {
+				t.Fatal(err)
+			}
+			d := (21 + 2*n) * 3
+			if b := m.Bounds(); b != image.Rect(0, 0, d, d) {
+				t.Fatalf("WithQuietZone(%d), QuietZone(%d): bounds = %v, want %dx%d", q, n, b, d, d)
+			}
+			for y := 0; y < d; y++ {
+				for x := 0; x < d; x++ {
+					v := byte(255)
+					if c.Black(x/3-n+q, y/3-n+q) {
+						v = 0
+					}
+					if gv := m.At(x, y).(color.Gray).Y; gv != v {
+						t.Fatalf("WithQuietZone(%d), QuietZone(%d): %d,%d = %d, want %d", q, n, x, y, gv, v)
+					}
+				}
+			}
+			if img := c.Image(QuietZone(n)); img.Bounds() != m.Bounds() {
+				t.Errorf("WithQuietZone(%d), QuietZone(%d): Image bounds = %v, want %v", q, n, img.Bounds(), m.Bounds())
+			}
+		}
+	}
+}
+
 func BenchmarkPNG(b *testing.B) {
 	c, err := Encode("0123456789012345678901234567890123456789", L)
 	if err != nil {
@@ -71,3 +147,148 @@ func BenchmarkImagePNG(b *testing.B) {
 	}
 	b.SetBytes(int64(buf.Len()))
 }
+
+func TestImage(t *testing.T) {
+	c, err := Encode("hello, world", L)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, tt := range []struct {
+		opts          []RenderOption
+		scale, margin int
+	}{
+		{nil, 8, 4},
+		{[]RenderOption{ModuleSize(3)}, 3, 4},
+		{[]RenderOption{ModuleSize(1), Margin(0)}, 1, 0},
+		{[]RenderOption{ModuleSize(2), Margin(6)}, 2, 6},
+	} {
+		m := c.Image(tt.opts...)
+		d := (c.Size + 2*tt.margin) * tt.scale
+		if b := m.Bounds(); b != image.Rect(0, 0, d, d) {
+			t.Errorf("scale %d, margin %d: Bounds = %v, want %dx%d", tt.scale, tt.margin, b, d, d)
+			continue
+		}
+		var buf bytes.Buffer
+		if err := png.Encode(&buf, m); err != nil {
+			t.Fatal(err)
+		}
+		pm, err := png.Decode(&buf)
+		if err != nil {
+			t.Fatal(err)
+		}
+		nbad := 0
+		for y := 0; y < d; y++ {
+			for x := 0; x < d; x++ {
+				v := byte(255)
+				if c.Black(x/tt.scale-tt.margin, y/tt.scale-tt.margin) {
+					v = 0
+				}
+				if gv := pm.At(x, y).(color.Gray).Y; gv != v {
+					t.Errorf("scale %d, margin %d: %d,%d = %d, want %d", tt.scale, tt.margin, x, y, gv, v)
+					if nbad++; nbad >= 20 {
+						t.Fatalf("too many bad pixels")
+					}
+				}
+			}
+		}
+	}
+}
+
+func TestDrawTo(t *testing.T) {
+	c, err := Encode("hello, world", L)
+	if err != nil {
+		t.Fatal(err)
+	}
+	red := color.RGBA{0xff, 0, 0, 0xff}
+	dst := image.NewRGBA(image.Rect(-10, -10, 200, 200))
+	draw.Draw(dst, dst.Bounds(), image.NewUniform(red), image.Point{}, draw.Src)
+	at := image.Pt(7, 11)
+	c.DrawTo(dst, at, ModuleSize(2), Margin(1))
+
+	img := c.Image(ModuleSize(2), Margin(1))
+	r := img.Bounds().Add(at)
+	for y := dst.Bounds().Min.Y; y < dst.Bounds().Max.Y; y++ {
+		for x := dst.Bounds().Min.X; x < dst.Bounds().Max.X; x++ {
+			var want color.Color = red
+			if image.Pt(x, y).In(r) {
+				want = img.At(x-at.X, y-at.Y)
+			}
+			if !sameColor(dst.At(x, y), want) {
+				t.Fatalf("pixel %d,%d = %v, want %v", x, y, dst.At(x, y), want)
+			}
+		}
+	}
+}
+
+func TestPNGOptions(t *testing.T) {
+	c, err := Encode("hello, world", L)
+	if err != nil {
+		t.Fatal(err)
+	}
+	fg := color.NRGBA{0x10, 0x20, 0x80, 0xff}
+	bg := color.NRGBA{0xff, 0xf0, 0xe0, 0x80}
+	var buf bytes.Buffer
+	if err := c.WritePNG(&buf, ModuleSize(3), Margin(2), Foreground(fg), Background(bg)); err != nil {
+		t.Fatal(err)
+	}
+	m, err := png.Decode(&buf)
+	if err != nil {
+		t.Fatal(err)
+	}
+	d := (c.Size + 4) * 3
+	if b := m.Bounds(); b != image.Rect(0, 0, d, d) {
+		t.Fatalf("Bounds = %v, want %dx%d", b, d, d)
+	}
+	want := c.Image(ModuleSize(3), Margin(2), Foreground(fg), Background(bg))
+	nbad := 0
+	for y := 0; y < d; y++ {
+		for x := 0; x < d; x++ {
+			if g, w := color.NRGBAModel.Convert(m.At(x, y)), color.NRGBAModel.Convert(want.At(x, y)); g != w {
+				t.Errorf("%d,%d = %v, want %v", x, y, g, w)
+				if nbad++; nbad >= 20 {
+					t.Fatalf("too many bad pixels")
+				}
+			}
+		}
+	}
+}
+
+// pngChunk returns the data of the first chunk with the given name in p.
+func pngChunk(p []byte, name string) []byte {
+	p = p[len(pngHeader):]
+	for len(p) >= 12 {
+		n := int(binary.BigEndian.Uint32(p))
+		if string(p[4:8]) == name {
+			return p[8 : 8+n]
+		}
+		p = p[12+n:]
+	}
+	return nil
+}
+
+func TestPNGPhysicalSize(t *testing.T) {
+	c, err := Encode("hello, world", L)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if phys := pngChunk(c.PNG(), "pHYs"); phys != nil {
+		t.Errorf("PNG without DPI has pHYs chunk")
+	}
+	for _, tt := range []struct {
+		opts []RenderOption
+		ppm  uint32
+	}{
+		{[]RenderOption{DPI(300)}, 11811},
+		{[]RenderOption{ModuleSize(10), PhysicalModuleSize(0.5)}, 20000},
+	} {
+		phys := pngChunk(c.PNG(tt.opts...), "pHYs")
+		if len(phys) != 9 {
+			t.Errorf("pHYs chunk = %x, want 9 bytes", phys)
+			continue
+		}
+		x, y := binary.BigEndian.Uint32(phys), binary.BigEndian.Uint32(phys[4:])
+		if x != tt.ppm || y != tt.ppm || phys[8] != 1 {
+			t.Errorf("pHYs = %d, %d, unit %d, want %d, %d, unit 1", x, y, phys[8], tt.ppm, tt.ppm)
+		}
+	}
+}
@@ -0,0 +1,45 @@
+// Copyright 2011 The Go Authors.  All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package qr
+
+import (
+	"fmt"
+	"math"
+)
+
+// A PrintSpec describes the physical constraints on a printed code.
+type PrintSpec struct {
+	Size      float64 // maximum width of the code, including quiet zone, in millimeters
+	DPI       float64 // printer resolution, in dots per inch
+	MinModule float64 // minimum width of a module (QR pixel), in millimeters
+}
+
+// Layout returns the version and scale (printer dots per module) for
+// printing text at the given level within the constraints of s.
+// It uses the smallest version that holds the text and the largest scale
+// that fits in s.Size, allowing for a 4-module quiet zone on each side.
+// It returns an error if no scale satisfies both s.Size and s.MinModule.
+func (s PrintSpec) Layout(text string, level Level) (Version, int, error) {
+	if s.Size <= 0 || s.DPI <= 0 || s.MinModule < 0 {
+		return 0, 0, fmt.Errorf("invalid print spec %+v", s)
+	}
+	v, err := SmallestVersion(text, level)
+	if err != nil {
+		return 0, 0, err
+	}
+	const mmPerInch = 25.4
+	modules := 4*int(v) + 17 + 2*4
+	dots := int(math.Floor(s.Size * s.DPI / mmPerInch))
+	scale := dots / modules
+	min := int(math.Ceil(s.MinModule * s.DPI / mmPerInch))
+	if min < 1 {
+		min = 1
+	}
+	if scale < min {
+		return 0, 0, fmt.Errorf("version %d code needs %.1fmm at %d dots per module, more than %.1fmm",
+			int(v), float64(modules*min)*mmPerInch/s.DPI, min, s.Size)
+	}
+	return v, scale, nil
+}
@@ -3,14 +3,14 @@
 // license that can be found in the LICENSE file.
 
 /*
-Package qr encodes QR codes.
+Package qr encodes and decodes QR codes.
 */
 package qr // import "rsc.io/qr"
 
 import (
+	"context"
 	"errors"
-	"image"
-	"image/color"
+	"fmt"
 
 	"github.com/inkstray/rsc-qr/coding"
 )
@@ -26,6 +26,31 @@ const (
 	H              // 65% redundant
 )
 
+// Errors returned by this package.  They are the same as
+// the corresponding errors in package coding.
+var (
+	ErrBadVersion = coding.ErrBadVersion
+	ErrBadLevel   = coding.ErrBadLevel
+	ErrBadMask    = coding.ErrBadMask
+)
+
+// ErrEmptyPayload is returned for empty text by an Encoder
+// created with WithRejectEmpty(true).
+var ErrEmptyPayload = errors.New("empty QR payload")
+
+// An ErrDataTooLong reports that text is too long for the allowed versions.
+type ErrDataTooLong = coding.ErrDataTooLong
+
+// An ErrInvalidChar reports a character that cannot be encoded.
+type ErrInvalidChar = coding.ErrInvalidChar
+
+// A Version denotes a QR version, from 1 to 40.
+// A code with version v has 4v+17 pixels on a side.
+type Version int
+
+// A Mask denotes a QR mask pattern, from 0 to 7.
+type Mask int
+
 var sizeClass = [3]struct {
 	min, max coding.Version
 }{
@@ -227,45 +252,113 @@ func split(sp []span, class int) *segment {
 
 // Encode returns an encoding of text at the given error correction level.
 func Encode(text string, level Level) (*Code, error) {
-	l := coding.Level(level)
-	// Estimate minimum QR version size class in a crude manner.
-	class := 0
-	weight := bits[0](len(text), 0, class)
-	for class < 2 && sizeClass[class].max.DataBytes(l)*8 < weight {
-		class++
+	e, err := NewEncoder(WithLevel(level))
+	if err != nil {
+		return nil, err
 	}
-	// Split string into spans.
-	sp := classify(text)
-	// Split string into segments for the size class.
-	seg := split(sp, class)
-	if seg != nil { // seg is nil if text == ""
-		weight = seg.weight
+	return e.Encode(text)
+}
+
+// EncodeContext is like Encode but stops early, returning ctx.Err(),
+// if ctx is done before the code is complete.
+func EncodeContext(ctx context.Context, text string, level Level) (*Code, error) {
+	e, err := NewEncoder(WithLevel(level))
+	if err != nil {
+		return nil, err
 	}
-	// If string is too big for the size class, increment class
-	// and resplit.  The weight will change, hence the loop.
-	for sizeClass[class].max.DataBytes(l)*8 < weight {
-		class++
-		for class < 3 && sizeClass[class].max.DataBytes(l)*8 < weight {
-			class++
-		}
-		if class == 3 {
-			return nil, errors.New("text too long to encode as QR")
-		}
-		seg = split(sp, class)
-		weight = seg.weight
+	return e.EncodeContext(ctx, text)
+}
+
+// Quick encodes text using level M, marking non-ASCII text as UTF-8
+// (see WithUTF8ECI), and otherwise the defaults described at NewEncoder.
+// Options given as arguments override these defaults.
+func Quick(text string, opts ...Option) (*Code, error) {
+	e, err := NewEncoder(append([]Option{WithLevel(M), WithUTF8ECI(true)}, opts...)...)
+	if err != nil {
+		return nil, err
 	}
+	return e.Encode(text)
+}
 
-	// Find version in the size class.
-	v := sizeClass[class].min
-	for max := sizeClass[class].max; v < max; {
-		if mid := (v + max) / 2; mid.DataBytes(l)*8 < weight {
-			v = mid + 1
-		} else {
-			max = mid
-		}
+// EncodeValue is like Encode but encodes the text form of v.
+// See Encoder.EncodeValue.
+func EncodeValue(v any, level Level) (*Code, error) {
+	e, err := NewEncoder(WithLevel(level))
+	if err != nil {
+		return nil, err
+	}
+	return e.EncodeValue(v)
+}
+
+// EncodeTo is like Encode but stores the code in dst,
+// reusing dst.Bitmap if it has enough capacity.
+// See Encoder.EncodeTo.
+func EncodeTo(dst *Code, text string, level Level) error {
+	e, err := NewEncoder(WithLevel(level))
+	if err != nil {
+		return err
+	}
+	return e.EncodeTo(dst, text)
+}
+
+// EncodeBoost is like Encode, but once it has chosen the smallest
+// version that holds text at the given level, it raises the level
+// as far as possible without increasing the version,
+// like many other QR encoders.  It returns the level used.
+func EncodeBoost(text string, level Level) (*Code, Level, error) {
+	e, err := NewEncoder(WithLevel(level), WithBoost(true))
+	if err != nil {
+		return nil, level, err
+	}
+	c, l, err := e.encodeText(context.Background(), nil, text)
+	return c, Level(l), err
+}
+
+// EncodeMask is like Encode but applies the given mask, from 0 to 7,
+// instead of choosing the mask with the lowest penalty.
+// Pinning the mask keeps the output identical across releases
+// even if the mask selection heuristics change.
+func EncodeMask(text string, level Level, mask Mask) (*Code, error) {
+	if mask < 0 || 7 < mask {
+		return nil, fmt.Errorf("%w %d", ErrBadMask, int(mask))
+	}
+	e, err := NewEncoder(WithLevel(level), WithMask(mask))
+	if err != nil {
+		return nil, err
+	}
+	return e.Encode(text)
+}
+
+// SmallestVersion returns the smallest version that can hold text
+// at the given level, using the same segmentation as Encode.
+// It is much cheaper than calling Encode.
+func SmallestVersion(text string, level Level) (Version, error) {
+	e, err := NewEncoder(WithLevel(level))
+	if err != nil {
+		return 0, err
+	}
+	segs := e.textSegments(text)
+	v, _ := e.version(coding.Level(level), segs)
+	if v == 0 {
+		return 0, e.tooLong(segs)
 	}
+	return Version(v), nil
+}
 
-	// Count and encode the segments.
+// Fits reports whether text fits in a code with the given
+// version and level, using the same segmentation as Encode.
+func Fits(text string, version Version, level Level) bool {
+	e, err := NewEncoder(WithLevel(level), WithVersionRange(int(version), int(version)))
+	if err != nil {
+		return false
+	}
+	v, _ := e.version(coding.Level(level), e.textSegments(text))
+	return v != 0
+}
+
+// encodings returns the encodings for the chain of segments
+// starting at seg, which describes text.
+func encodings(text string, seg *segment) []coding.Encoding {
 	n := 0
 	for s := seg; s != nil; s = s.next {
 		n++
@@ -287,14 +380,59 @@ func Encode(text string, level Level) (*Code, error) {
 		enc = append(enc, e)
 		seg = seg.next
 	}
+	return enc
+}
 
-	// Build and execute plan.
-	cc, err := coding.Encode(v, l, enc...)
-	if err != nil {
-		return nil, err
+// EncodeSeries returns an encoding of data as 8-bit data at the given
+// error correction level.  If data is too long for a single code,
+// EncodeSeries splits it into a Structured Append series of up to 16 codes,
+// which a reader reassembles into the original data.
+func EncodeSeries(data []byte, level Level) ([]*Code, error) {
+	l := coding.Level(level)
+	if c, err := coding.Encode(bytesVersion(len(data), l, 0), l, coding.Bytes(data)); err == nil {
+		return []*Code{{Bitmap: c.Bitmap, Size: c.Size, Stride: c.Stride, Scale: 8, Level: level, Info: c.Info}}, nil
+	}
+
+	// Split into the fewest codes, as evenly as possible.
+	const header = 4 + 4 + 4 + 8 // structured append
+	max := (coding.Version(coding.MaxVersion).DataBytes(l)*8 - header - coding.Bytes(nil).Bits(coding.MaxVersion)) / 8
+	n := (len(data) + max - 1) / max
+	if n > coding.MaxStructuredAppend {
+		return nil, &ErrDataTooLong{
+			Bits:     8 * len(data),
+			Capacity: 8 * max * coding.MaxStructuredAppend,
+			Level:    coding.Level(level),
+		}
+	}
+	per := (len(data) + n - 1) / n
+	sa := coding.StructuredAppend{Total: n, Parity: coding.Parity(data)}
+	codes := make([]*Code, 0, n)
+	for ; len(data) > 0; sa.Index++ {
+		chunk := data
+		if len(chunk) > per {
+			chunk = chunk[:per]
+		}
+		data = data[len(chunk):]
+		c, err := coding.Encode(bytesVersion(len(chunk), l, header), l, sa, coding.Bytes(chunk))
+		if err != nil {
+			return nil, err
+		}
+		codes = append(codes, &Code{Bitmap: c.Bitmap, Size: c.Size, Stride: c.Stride, Scale: 8, Level: level, Info: c.Info})
 	}
+	return codes, nil
+}
 
-	return &Code{cc.Bitmap, cc.Size, cc.Stride, 8}, nil
+// bytesVersion returns the smallest version that holds
+// extra bits followed by n bytes of 8-bit data at level l,
+// or MaxVersion if there is none.
+func bytesVersion(n int, l coding.Level, extra int) coding.Version {
+	v := coding.Version(coding.MinVersion)
+	for ; v < coding.MaxVersion; v++ {
+		if extra+coding.Bytes(nil).Bits(v)+8*n <= v.DataBytes(l)*8 {
+			break
+		}
+	}
+	return v
 }
 
 // A Code is a square pixel grid.
@@ -304,42 +442,93 @@ type Code struct {
 	Size   int    // number of pixels on a side
 	Stride int    // number of bytes per row
 	Scale  int    // number of image pixels per QR pixel
+	Level  Level  // error correction level
+
+	// Info describes how the code was encoded:
+	// the version, level, mask, and segments used.
+	// It is nil for codes not returned by an Encode function.
+	Info *coding.Info
+
+	// Newlines is the policy applied to line breaks in the text.
+	// See WithNewlines.
+	Newlines Newlines
+
+	// QuietZone is the width of the white border around the code,
+	// in QR pixels, that is already included in Bitmap and Size.
+	// Images of the code add to it as needed to make a
+	// border of at least 4 QR pixels.
+	QuietZone int
 }
 
-// Black returns true if the pixel at (x,y) is black.
-func (c *Code) Black(x, y int) bool {
-	return 0 <= x && x < c.Size && 0 <= y && y < c.Size &&
-		c.Bitmap[y*c.Stride+x/8]&(1<<uint(7-x&7)) != 0
+// border returns the number of QR pixels of white border
+// that images must add around the bitmap.
+func (c *Code) border() int {
+	if c.QuietZone >= 4 {
+		return 0
+	}
+	return 4 - c.QuietZone
 }
 
-// Image returns an Image displaying the code.
-func (c *Code) Image() image.Image {
-	return &codeImage{c}
-
+// withQuietZone returns a copy of c with a white border
+// of n QR pixels added to the bitmap.  The copy reuses buf
+// for its bitmap if buf has enough capacity.
+func (c *Code) withQuietZone(n int, buf []byte) *Code {
+	if n <= 0 {
+		return c
+	}
+	q := *c
+	q.Size = c.Size + 2*n
+	q.Stride = (q.Size + 7) / 8
+	if cap(buf) < q.Size*q.Stride {
+		buf = make([]byte, q.Size*q.Stride)
+	}
+	q.Bitmap = buf[:q.Size*q.Stride]
+	for i := range q.Bitmap {
+		q.Bitmap[i] = 0
+	}
+	q.QuietZone += n
+	for y := 0; y < c.Size; y++ {
+		for x := 0; x < c.Size; x++ {
+			if c.Black(x, y) {
+				xx, yy := x+n, y+n
+				q.Bitmap[yy*q.Stride+xx/8] |= 1 << uint(7-xx&7)
+			}
+		}
+	}
+	return &q
 }
 
-// codeImage implements image.Image
-type codeImage struct {
-	*Code
+// Clone returns a copy of c that does not share its bitmap or Info.
+func (c *Code) Clone() *Code {
+	d := *c
+	d.Bitmap = append([]byte(nil), c.Bitmap...)
+	d.Info = c.Info.Clone()
+	return &d
 }
 
-var (
-	whiteColor color.Color = color.Gray{0xFF}
-	blackColor color.Color = color.Gray{0x00}
-)
+// Equal reports whether c and d are the same code:
+// the same size, pixels, scale, level, and quiet zone.
+// Info is ignored.
+// The padding bits at the end of each row and the strides
+// themselves are ignored.
+func (c *Code) Equal(d *Code) bool {
+	return c.Scale == d.Scale && c.Level == d.Level && c.QuietZone == d.QuietZone &&
+		c.coding().Equal(d.coding())
+}
 
-func (c *codeImage) Bounds() image.Rectangle {
-	d := (c.Size + 8) * c.Scale
-	return image.Rect(0, 0, d, d)
+// coding returns c's bitmap as a coding.Code.
+func (c *Code) coding() *coding.Code {
+	return &coding.Code{Bitmap: c.Bitmap, Size: c.Size, Stride: c.Stride}
 }
 
-func (c *codeImage) At(x, y int) color.Color {
-	if c.Black(x, y) {
-		return blackColor
-	}
-	return whiteColor
+// Matrix returns the pixels of c's bitmap as a matrix indexed by [y][x],
+// with true for black.  See also coding.NewCodeFromMatrix.
+func (c *Code) Matrix() [][]bool {
+	return c.coding().Matrix()
 }
 
-func (c *codeImage) ColorModel() color.Model {
-	return color.GrayModel
+// Black returns true if the pixel at (x,y) is black.
+func (c *Code) Black(x, y int) bool {
+	return 0 <= x && x < c.Size && 0 <= y && y < c.Size &&
+		c.Bitmap[y*c.Stride+x/8]&(1<<uint(7-x&7)) != 0
 }
@@ -0,0 +1,563 @@
+// Copyright 2011 The Go Authors.  All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package qr
+
+import (
+	"bytes"
+	"context"
+	"crypto/sha256"
+	"errors"
+	"fmt"
+	"strings"
+	"testing"
+
+	"github.com/inkstray/rsc-qr/coding"
+)
+
+func TestEncodeSeries(t *testing.T) {
+	data := make([]byte, 5000)
+	for i := range data {
+		data[i] = byte(i)
+	}
+	codes, err := EncodeSeries(data, M)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(codes) != 3 {
+		t.Errorf("EncodeSeries(%d bytes, M) = %d codes, want 3", len(data), len(codes))
+	}
+	codes, err = EncodeSeries(data[:100], M)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(codes) != 1 {
+		t.Errorf("EncodeSeries(100 bytes, M) = %d codes, want 1", len(codes))
+	}
+	if _, err := EncodeSeries(make([]byte, 100000), M); err == nil {
+		t.Errorf("EncodeSeries(100000 bytes, M) succeeded, want error")
+	}
+}
+
+func TestEncodeBoost(t *testing.T) {
+	c, l, err := EncodeBoost("hello", L)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if c.Size != 21 || l != H {
+		t.Errorf("EncodeBoost(hello, L) = size %d, level %d, want 21, H", c.Size, l)
+	}
+	c, l, err = EncodeBoost("abcdefghijklmnopqrst", L)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if c.Size != 25 || l != Q {
+		t.Errorf("EncodeBoost = size %d, level %d, want 25, Q", c.Size, l)
+	}
+}
+
+func TestEncoder(t *testing.T) {
+	const text = "http://example.com/12345678901234567890"
+	for _, tt := range []struct {
+		opts []Option
+		size int
+	}{
+		{nil, 25},
+		{[]Option{WithSegmentation(SegmentSplit)}, 25},
+		{[]Option{WithSegmentation(SegmentByte)}, 29},
+		{[]Option{WithLevel(H)}, 33},
+		{[]Option{WithVersionRange(5, 40)}, 37},
+		{[]Option{WithMask(3)}, 25},
+	} {
+		c, err := newEncoder(t, tt.opts...).Encode(text)
+		if err != nil {
+			t.Errorf("Encode with %d options: %v", len(tt.opts), err)
+			continue
+		}
+		if c.Size != tt.size {
+			t.Errorf("Encode with %d options: size %d, want %d", len(tt.opts), c.Size, tt.size)
+		}
+	}
+	if _, err := newEncoder(t, WithVersionRange(1, 2)).Encode(text + text); err == nil {
+		t.Errorf("Encode with version range 1 to 2 succeeded, want error")
+	}
+	for _, fold := range []bool{false, true} {
+		c, err := newEncoder(t, WithCaseFolding(fold)).Encode("https://example.com/abc")
+		if err != nil {
+			t.Fatal(err)
+		}
+		if want := map[bool]int{false: 25, true: 21}[fold]; c.Size != want {
+			t.Errorf("Encode with case folding %v: size %d, want %d", fold, c.Size, want)
+		}
+	}
+	c, err := newEncoder(t, WithScale(2)).EncodeBytes([]byte{0, 1, 2})
+	if err != nil || c.Size != 21 || c.Scale != 2 {
+		t.Errorf("EncodeBytes = %+v, %v", c, err)
+	}
+}
+
+func TestEncoderDowngrade(t *testing.T) {
+	text := string(make([]byte, 1500))
+	if _, err := newEncoder(t, WithLevel(H)).Encode(text); err == nil {
+		t.Fatalf("Encode(1500 bytes, H) succeeded, want error")
+	}
+	c, err := newEncoder(t, WithLevel(H), WithDowngrade(true)).Encode(text)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if c.Level != Q {
+		t.Errorf("Encode(1500 bytes, H) with downgrade: level %v, want Q", c.Level)
+	}
+}
+
+func TestEncodeMask(t *testing.T) {
+	seen := make(map[string]Mask)
+	for m := Mask(0); m < 8; m++ {
+		c, err := EncodeMask("hello, world", M, m)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if old, ok := seen[string(c.Bitmap)]; ok {
+			t.Errorf("EncodeMask with mask %d = mask %d", m, old)
+		}
+		seen[string(c.Bitmap)] = m
+	}
+	if _, err := EncodeMask("hello, world", M, 8); err == nil {
+		t.Errorf("EncodeMask with mask 8 succeeded, want error")
+	}
+}
+
+func TestBuilder(t *testing.T) {
+	c, err := NewBuilder().Numeric("123").Alpha("ABC").Bytes([]byte{0xff}).Kanji("漢字").Level(Q).Build()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if c.Size != 25 || c.Level != Q {
+		t.Errorf("Build: size %d, level %v, want 25, Q", c.Size, c.Level)
+	}
+	if _, err := NewBuilder().Numeric("12a").Build(); err == nil {
+		t.Errorf("Build with invalid numeric segment succeeded")
+	}
+}
+
+func TestSmallestVersion(t *testing.T) {
+	for _, tt := range []struct {
+		text  string
+		level Level
+		v     Version
+	}{
+		{"", L, 1},
+		{"hello, world", L, 1},
+		{"http://example.com/12345678901234567890", L, 2},
+		{"http://example.com/12345678901234567890", H, 4},
+	} {
+		v, err := SmallestVersion(tt.text, tt.level)
+		if v != tt.v || err != nil {
+			t.Errorf("SmallestVersion(%q, %d) = %v, %v, want %v", tt.text, tt.level, v, err, tt.v)
+		}
+		c, err := Encode(tt.text, tt.level)
+		if err != nil || c.Size != 4*int(tt.v)+17 {
+			t.Errorf("Encode(%q, %d) = %v, %v, want version %v", tt.text, tt.level, c, err, tt.v)
+		}
+		if !Fits(tt.text, tt.v, tt.level) || tt.v > 1 && Fits(tt.text, tt.v-1, tt.level) {
+			t.Errorf("Fits(%q) disagrees with SmallestVersion", tt.text)
+		}
+	}
+	if _, err := SmallestVersion(string(make([]byte, 3000)), L); err == nil {
+		t.Errorf("SmallestVersion(3000 bytes) succeeded, want error")
+	}
+}
+
+func TestEncodeAll(t *testing.T) {
+	texts := make([]string, 100)
+	for i := range texts {
+		texts[i] = fmt.Sprintf("ticket %d", i)
+	}
+	codes, err := EncodeAll(texts, M)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for i, text := range texts {
+		c, err := Encode(text, M)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if !bytes.Equal(c.Bitmap, codes[i].Bitmap) {
+			t.Errorf("EncodeAll()[%d] differs from Encode(%q)", i, text)
+		}
+	}
+	texts[50] = string(make([]byte, 3000))
+	_, err = EncodeAll(texts, M)
+	var tooLong *ErrDataTooLong
+	if !errors.As(err, &tooLong) || !strings.HasPrefix(err.Error(), "text 50: ") {
+		t.Errorf("EncodeAll with long text = %v, want text 50: *ErrDataTooLong", err)
+	}
+}
+
+func TestEncodeContext(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	if _, err := EncodeContext(ctx, "hello, world", M); err != nil {
+		t.Fatal(err)
+	}
+	cancel()
+	if _, err := EncodeContext(ctx, "hello, world", M); err != context.Canceled {
+		t.Errorf("EncodeContext with canceled context = %v, want %v", err, context.Canceled)
+	}
+}
+
+func TestPrintLayout(t *testing.T) {
+	// Version 1 is 29 modules with quiet zone.
+	// 20mm at 300 dpi is 236 dots: 8 dots per module.
+	v, scale, err := PrintSpec{Size: 20, DPI: 300, MinModule: 0.3}.Layout("hello", M)
+	if v != 1 || scale != 8 || err != nil {
+		t.Errorf("Layout = %v, %v, %v, want 1, 8, nil", v, scale, err)
+	}
+	// 0.7mm modules need 9 dots per module.
+	if _, _, err := (PrintSpec{Size: 20, DPI: 300, MinModule: 0.7}).Layout("hello", M); err == nil {
+		t.Errorf("Layout with large modules succeeded, want error")
+	}
+}
+
+var deterministicTests = []struct {
+	text  string
+	level Level
+	sum   string // SHA-256 of Bitmap
+}{
+	{"hello, world", L, "91a0910810de0b4286a668e4967a86c4636225287b9d931f195ab3272086efee"},
+	{"HTTPS://EXAMPLE.COM/0123456789", L, "d40fdb4c7c666ee42c94183d554042a3f9227b59ce1e37a7be041dd11987d98c"},
+	{"点茗 12345 abc", L, "b07ed89a34a65907bae76b846c2f3b0f515a623662f877857fac640d829b5810"},
+	{"hello, world", H, "71c239ce279b0e308118b924c2cbe61a176dbdff0a390c82457c91c79aec153e"},
+	{"HTTPS://EXAMPLE.COM/0123456789", H, "c041a7bc828a654a48d1480573410441943dd839204165a626747c8baa78d9d3"},
+	{"点茗 12345 abc", H, "878070c89ac42b1523191744c65abc31f586d816bff46ec85c9e23959052f494"},
+}
+
+// TestDeterministic checks that deterministic mode output does not change.
+// If this test fails, deterministic output has changed, which breaks
+// the promise made by WithDeterministic.
+func TestDeterministic(t *testing.T) {
+	for _, seg := range []Segmentation{SegmentOptimal, SegmentSplit} {
+		for _, tt := range deterministicTests {
+			e := newEncoder(t, WithDeterministic(true), WithLevel(tt.level), WithSegmentation(seg))
+			c, err := e.Encode(tt.text)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if sum := fmt.Sprintf("%x", sha256.Sum256(c.Bitmap)); sum != tt.sum {
+				t.Errorf("Encode(%q) at level %v, segmentation %d: bitmap SHA-256 = %s, want %s", tt.text, tt.level, seg, sum, tt.sum)
+			}
+		}
+	}
+}
+
+func TestEncodeTo(t *testing.T) {
+	for _, q := range []int{0, 4} {
+		e := newEncoder(t, WithLevel(M), WithQuietZone(q))
+		var dst Code
+		for _, text := range []string{"hello, world", "0123456789", "HELLO WORLD"} {
+			want, err := e.Encode(text)
+			if err != nil {
+				t.Fatal(err)
+			}
+			old := dst.Bitmap
+			if err := e.EncodeTo(&dst, text); err != nil {
+				t.Fatal(err)
+			}
+			if dst.Size != want.Size || dst.QuietZone != want.QuietZone || !bytes.Equal(dst.Bitmap, want.Bitmap) {
+				t.Errorf("quiet zone %d: EncodeTo(%q) differs from Encode", q, text)
+			}
+			if old != nil && &old[0] != &dst.Bitmap[0] {
+				t.Errorf("quiet zone %d: EncodeTo(%q) did not reuse bitmap", q, text)
+			}
+		}
+	}
+}
+
+func TestMust(t *testing.T) {
+	old := DefaultEncoder()
+	defer SetDefaultEncoder(old)
+
+	if c := Must("hello, world"); c.Level != L || c.Scale != 8 {
+		t.Errorf("Must with default encoder: Level, Scale = %v, %d, want L, 8", c.Level, c.Scale)
+	}
+	SetDefaultEncoder(newEncoder(t, WithLevel(H), WithScale(4)))
+	if c := Must("hello, world"); c.Level != H || c.Scale != 4 {
+		t.Errorf("Must after SetDefaultEncoder: Level, Scale = %v, %d, want H, 4", c.Level, c.Scale)
+	}
+
+	defer func() {
+		if recover() == nil {
+			t.Errorf("Must with long text did not panic")
+		}
+	}()
+	Must(string(make([]byte, 3000)))
+}
+
+func TestCloneEqual(t *testing.T) {
+	c, err := Encode("hello, world", M)
+	if err != nil {
+		t.Fatal(err)
+	}
+	d := c.Clone()
+	if !c.Equal(d) {
+		t.Fatalf("Clone not Equal to original")
+	}
+	d.Scale++
+	if c.Equal(d) {
+		t.Errorf("Equal ignores Scale")
+	}
+	d = c.Clone()
+	d.Bitmap[0] ^= 0x80
+	if c.Equal(d) {
+		t.Errorf("Equal ignores pixels")
+	}
+
+	// The clone does not share Info with the original.
+	c.Info.Segments = []coding.Encoding{coding.Bytes("abc"), coding.Alpha("X")}
+	c.Info.Corrected = []int{1, 2}
+	d = c.Clone()
+	c.Info.Segments[0].(coding.Bytes)[0] = 'z'
+	c.Info.Segments[1] = coding.Num("1")
+	c.Info.Corrected[0] = 9
+	if d.Info == c.Info || string(d.Info.Segments[0].(coding.Bytes)) != "abc" ||
+		d.Info.Segments[1] != coding.Alpha("X") || d.Info.Corrected[0] != 1 {
+		t.Errorf("Clone shares Info with original: %v %v", d.Info.Segments, d.Info.Corrected)
+	}
+}
+
+func TestMatrix(t *testing.T) {
+	c, err := newEncoder(t, WithQuietZone(2)).Encode("hello, world")
+	if err != nil {
+		t.Fatal(err)
+	}
+	m := c.Matrix()
+	if len(m) != c.Size || len(m[0]) != c.Size {
+		t.Fatalf("Matrix is %dx%d, want %dx%d", len(m[0]), len(m), c.Size, c.Size)
+	}
+	for y, row := range m {
+		for x, black := range row {
+			if black != c.Black(x, y) {
+				t.Fatalf("Matrix()[%d][%d] = %v, want %v", y, x, black, c.Black(x, y))
+			}
+		}
+	}
+}
+
+func TestErrors(t *testing.T) {
+	_, err := newEncoder(t, WithLevel(H), WithVersionRange(1, 2)).Encode("hello, world, hello, world")
+	var long *ErrDataTooLong
+	if !errors.As(err, &long) {
+		t.Fatalf("Encode long text = %v, want *ErrDataTooLong", err)
+	}
+	if long.Capacity != 16*8 || long.MinVersion != 4 {
+		t.Errorf("Encode long text = %+v, want Capacity 128, MinVersion 4", *long)
+	}
+
+	_, err = NewBuilder().Alpha("hello").Build()
+	var char *ErrInvalidChar
+	if !errors.As(err, &char) || char.Rune != 'h' || char.Pos != 0 {
+		t.Errorf("Build with lower-case alphanumeric = %v, want *ErrInvalidChar for 'h'", err)
+	}
+
+	if _, err := EncodeMask("hello", L, 8); !errors.Is(err, ErrBadMask) {
+		t.Errorf("EncodeMask with mask 8 = %v, want ErrBadMask", err)
+	}
+	if _, err := NewEncoder(WithVersionRange(0, 41)); !errors.Is(err, ErrBadVersion) {
+		t.Errorf("NewEncoder with version range 0 to 41 = %v, want ErrBadVersion", err)
+	}
+}
+
+// newEncoder returns NewEncoder(opts...), failing the test on error.
+func newEncoder(t *testing.T, opts ...Option) *Encoder {
+	t.Helper()
+	e, err := NewEncoder(opts...)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return e
+}
+
+var badOptions = []struct {
+	opts []Option
+	err  error
+}{
+	{[]Option{WithLevel(H + 1)}, ErrBadLevel},
+	{[]Option{WithLevel(-1)}, ErrBadLevel},
+	{[]Option{WithVersionRange(10, 5)}, ErrBadVersion},
+	{[]Option{WithVersionRange(1, 41)}, ErrBadVersion},
+	{[]Option{WithMask(8)}, ErrBadMask},
+	{[]Option{WithMask(-2)}, ErrBadMask},
+	{[]Option{WithScale(0)}, nil},
+	{[]Option{WithQuietZone(-1)}, nil},
+	{[]Option{WithSegmentation(SegmentByte + 1)}, nil},
+}
+
+func TestNewEncoderInvalid(t *testing.T) {
+	for i, tt := range badOptions {
+		e, err := NewEncoder(tt.opts...)
+		if err == nil {
+			t.Errorf("#%d: NewEncoder succeeded, want error", i)
+			continue
+		}
+		if e != nil {
+			t.Errorf("#%d: NewEncoder returned non-nil Encoder with error", i)
+		}
+		if tt.err != nil && !errors.Is(err, tt.err) {
+			t.Errorf("#%d: NewEncoder = %v, want %v", i, err, tt.err)
+		}
+	}
+}
+
+func TestMaxVersion(t *testing.T) {
+	e := newEncoder(t, WithMaxVersion(2))
+	c, err := e.Encode("hello, world")
+	if err != nil || c.Size != 21 {
+		t.Fatalf("Encode short text = %v, %v, want version 1", c, err)
+	}
+	_, err = e.Encode(strings.Repeat("hello, world ", 10))
+	var long *ErrDataTooLong
+	if !errors.As(err, &long) || long.MinVersion != 6 {
+		t.Errorf("Encode long text = %v, want *ErrDataTooLong with MinVersion 6", err)
+	}
+	if _, err := NewEncoder(WithMaxVersion(41)); !errors.Is(err, ErrBadVersion) {
+		t.Errorf("NewEncoder(WithMaxVersion(41)) = %v, want ErrBadVersion", err)
+	}
+}
+
+func TestInfo(t *testing.T) {
+	c, err := EncodeMask("hello, world", Q, 5)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if c.Info == nil || c.Info.Version != 2 || c.Info.Level != coding.Q || c.Info.Mask != 5 {
+		t.Errorf("EncodeMask Info = %+v, want version 2, level Q, mask 5", c.Info)
+	}
+}
+
+type textID int
+
+func (id textID) MarshalText() ([]byte, error) {
+	if id < 0 {
+		return nil, errors.New("negative id")
+	}
+	return []byte(fmt.Sprintf("ID-%d", int(id))), nil
+}
+
+type stringID int
+
+func (id stringID) String() string { return fmt.Sprintf("ID-%d", int(id)) }
+
+func TestEncodeValue(t *testing.T) {
+	want, err := Encode("ID-42", M)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, v := range []any{"ID-42", textID(42), stringID(42)} {
+		c, err := EncodeValue(v, M)
+		if err != nil {
+			t.Errorf("EncodeValue(%T): %v", v, err)
+			continue
+		}
+		if !c.Equal(want) {
+			t.Errorf("EncodeValue(%T) differs from Encode", v)
+		}
+	}
+	b, err := EncodeValue([]byte("ID-42"), M)
+	if err != nil || fmt.Sprint(b.Info.Segments) != fmt.Sprint([]coding.Encoding{coding.Bytes("ID-42")}) {
+		t.Errorf("EncodeValue([]byte) = %v, %v, want 8-bit encoding", b, err)
+	}
+	if _, err := EncodeValue(textID(-1), M); err == nil {
+		t.Errorf("EncodeValue with failing MarshalText succeeded")
+	}
+	if _, err := EncodeValue(42, M); err == nil {
+		t.Errorf("EncodeValue(42) succeeded")
+	}
+}
+
+var newlineTests = []struct {
+	n    Newlines
+	in   string
+	out  string
+	fail bool
+}{
+	{NewlinesKeep, "a\nb\r\nc\rd", "a\nb\r\nc\rd", false},
+	{NewlinesCRLF, "a\nb\r\nc\rd\n", "a\r\nb\r\nc\r\nd\r\n", false},
+	{NewlinesCRLF, "\r\r\n\n", "\r\n\r\n\r\n", false},
+	{NewlinesLF, "a\nb\r\nc\rd", "a\nb\nc\nd", false},
+	{NewlinesReject, "abc", "abc", false},
+	{NewlinesReject, "a\r\nb", "", true},
+}
+
+func TestNewlines(t *testing.T) {
+	for _, tt := range newlineTests {
+		e := newEncoder(t, WithNewlines(tt.n))
+		c, err := e.Encode(tt.in)
+		if tt.fail {
+			if err == nil {
+				t.Errorf("Newlines(%d).Encode(%q) succeeded, want error", tt.n, tt.in)
+			}
+			continue
+		}
+		if err != nil {
+			t.Errorf("Newlines(%d).Encode(%q): %v", tt.n, tt.in, err)
+			continue
+		}
+		want, err := Encode(tt.out, L)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if !c.Equal(want) || c.Newlines != tt.n {
+			t.Errorf("Newlines(%d).Encode(%q) differs from Encode(%q)", tt.n, tt.in, tt.out)
+		}
+	}
+}
+
+func TestQuick(t *testing.T) {
+	c, err := Quick("hello, world")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if c.Level != M || len(c.Info.Segments) != 1 {
+		t.Errorf("Quick(ASCII) = level %v, segments %v, want level M, 1 segment", c.Level, c.Info.Segments)
+	}
+
+	c, err = Quick("héllo", WithLevel(H))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if c.Level != H || len(c.Info.Segments) != 2 || c.Info.Segments[0] != coding.ECI(26) {
+		t.Errorf("Quick(UTF-8, H) = level %v, segments %v, want level H, ECI(26) first", c.Level, c.Info.Segments)
+	}
+
+	c, err = Quick("héllo", WithUTF8ECI(false))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(c.Info.Segments) != 1 {
+		t.Errorf("Quick(UTF-8) without ECI: segments %v", c.Info.Segments)
+	}
+}
+
+func TestEmpty(t *testing.T) {
+	c, err := Encode("", M)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if c.Size != 21 || len(c.Info.Segments) != 0 || c.Info.DataBits != 0 {
+		t.Errorf("Encode(\"\") = size %d, info %+v, want empty version 1 code", c.Size, *c.Info)
+	}
+
+	e := newEncoder(t, WithRejectEmpty(true))
+	if _, err := e.Encode(""); err != ErrEmptyPayload {
+		t.Errorf("Encode(\"\") with WithRejectEmpty = %v, want ErrEmptyPayload", err)
+	}
+	if _, err := e.EncodeBytes(nil); err != ErrEmptyPayload {
+		t.Errorf("EncodeBytes(nil) with WithRejectEmpty = %v, want ErrEmptyPayload", err)
+	}
+	if _, err := NewBuilder().Options(WithRejectEmpty(true)).Build(); err != ErrEmptyPayload {
+		t.Errorf("empty Build with WithRejectEmpty = %v, want ErrEmptyPayload", err)
+	}
+	if _, err := e.Encode("x"); err != nil {
+		t.Errorf("Encode(\"x\") with WithRejectEmpty: %v", err)
+	}
+}
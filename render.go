@@ -0,0 +1,253 @@
+// Copyright 2011 The Go Authors.  All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package qr
+
+import (
+	"image"
+	"image/color"
+	"image/draw"
+)
+
+// A RenderOption configures how a code is drawn as an image.
+type RenderOption func(*render)
+
+// A render holds the settings for drawing a code.
+type render struct {
+	scale  int         // image pixels per QR pixel
+	margin int         // QR pixels of white border added around the bitmap
+	quiet  int         // total QR pixels of white border, or -1 to use margin
+	fg, bg color.Color // colors of black and white pixels
+	dpi    float64     // image pixels per inch, or 0 if unknown
+	mm     float64     // QR pixel size in millimeters, or 0 if unknown
+	ansi   bool        // use ANSI colors in terminal output
+
+	black, white string // ASCII glyphs for black and white pixels
+	invert       bool   // swap ASCII glyphs
+
+	htmlSVG    bool // HTML uses inline SVG instead of a table
+	svgClasses bool // SVG tags paths with pixel role classes
+
+	shape     Shape   // shape of black QR pixels
+	shapeSize float64 // size of shape; see ModuleShape
+
+	minContrast float64   // minimum fg/bg contrast ratio; see MinContrast
+	grad        *gradient // foreground gradient, or nil
+
+	darkness int // label printer darkness, or -1 for the printer's setting
+
+	artDark, artLight float64 // luminance bands for Blend
+	artCenter         float64 // fraction of each pixel forced by Blend
+}
+
+// pixelsPerMeter returns the physical resolution of the image,
+// or 0 if it is unknown.
+func (r *render) pixelsPerMeter() float64 {
+	switch {
+	case r.mm > 0:
+		return float64(r.scale) / r.mm * 1000
+	case r.dpi > 0:
+		return r.dpi / 0.0254
+	}
+	return 0
+}
+
+// gray reports whether r uses the default black and white colors.
+func (r *render) gray() bool {
+	return r.fg == blackColor && r.bg == whiteColor
+}
+
+// ModuleSize sets the number of image pixels per QR pixel.
+// The default is the code's Scale.
+func ModuleSize(n int) RenderOption {
+	return func(r *render) { r.scale = n }
+}
+
+// Margin sets the width, in QR pixels, of the white border added
+// around the code's bitmap.  The default is the 4-pixel quiet zone
+// required by the QR specification, less any QuietZone already
+// included in the bitmap.  To set the total border width instead,
+// use QuietZone.
+func Margin(n int) RenderOption {
+	return func(r *render) { r.margin = n }
+}
+
+// QuietZone sets the total width, in QR pixels, of the white border
+// around the code in the output, counting any quiet zone already
+// included in the code's bitmap.  The default is 4, the minimum
+// required by the QR specification.  QuietZone(0) draws no border at
+// all, for callers that compose their own margins; if the bitmap
+// includes a quiet zone, the output is cropped to the code itself.
+// QuietZone overrides Margin.
+func QuietZone(n int) RenderOption {
+	if n < 0 {
+		n = 0
+	}
+	return func(r *render) { r.quiet = n }
+}
+
+// Foreground sets the color of the black pixels of the code.
+// The default is black.
+func Foreground(c color.Color) RenderOption {
+	return func(r *render) { r.fg = c }
+}
+
+// Background sets the color of the white pixels of the code,
+// including the margin.  The default is white.
+// For the code to scan, the background must be much
+// lighter than the foreground; see CheckContrast.
+func Background(c color.Color) RenderOption {
+	return func(r *render) { r.bg = c }
+}
+
+// DPI records in the image that it has dpi pixels per inch,
+// so that programs printing the image render it at the intended size.
+// It does not change the pixels of the image.
+// Only PNG images record the resolution.
+func DPI(dpi float64) RenderOption {
+	return func(r *render) { r.dpi, r.mm = dpi, 0 }
+}
+
+// PhysicalModuleSize is like DPI but sets the resolution
+// from the intended size of a QR pixel in millimeters.
+func PhysicalModuleSize(mm float64) RenderOption {
+	return func(r *render) { r.mm, r.dpi = mm, 0 }
+}
+
+// ANSIColors sets whether terminal output sets the foreground
+// and background colors using ANSI escape sequences.
+// See Code.Terminal.
+func ANSIColors(on bool) RenderOption {
+	return func(r *render) { r.ansi = on }
+}
+
+// Glyphs sets the strings that ASCII uses for black and white pixels.
+// The default is "##" and "  ".  Using two characters per pixel
+// makes the code appear about square in a fixed-width font.
+func Glyphs(black, white string) RenderOption {
+	return func(r *render) { r.black, r.white = black, white }
+}
+
+// Invert sets whether ASCII swaps the glyphs for black and white
+// pixels, which displays correctly when the text is drawn light
+// on a dark background.
+func Invert(on bool) RenderOption {
+	return func(r *render) { r.invert = on }
+}
+
+// HTMLSVG sets whether HTML draws the code as an inline <svg> element
+// instead of a <table>.  See Code.HTML.
+func HTMLSVG(on bool) RenderOption {
+	return func(r *render) { r.htmlSVG = on }
+}
+
+// Darkness sets the print darkness recorded in output for label
+// printers: 0 to 30 for ZPL, 0 to 15 for EPL2.  By default the output
+// leaves the printer's configured darkness unchanged.
+func Darkness(n int) RenderOption {
+	return func(r *render) { r.darkness = n }
+}
+
+// SVGClasses sets whether SVG output draws each pixel role as a
+// separate path with a CSS class naming the role, such as
+// "qr-finder", "qr-timing", or "qr-data", so that external style
+// sheets can restyle parts of the code.  The background rectangle
+// has class "qr-quiet".  See PixelRole.
+func SVGClasses(on bool) RenderOption {
+	return func(r *render) { r.svgClasses = on }
+}
+
+// render returns the settings for drawing c with the given options.
+func (c *Code) render(opts []RenderOption) render {
+	r := render{
+		scale:  c.Scale,
+		margin: c.border(),
+		quiet:  -1,
+		fg:     blackColor,
+		bg:     whiteColor,
+		black:  "##",
+		white:  "  ",
+
+		minContrast: DefaultMinContrast,
+		darkness:    -1,
+		artDark:     0.1,
+		artLight:    0.5,
+		artCenter:   1.0 / 3,
+	}
+	for _, opt := range opts {
+		opt(&r)
+	}
+	if r.scale < 1 {
+		r.scale = 1
+	}
+	if r.margin < 0 {
+		r.margin = 0
+	}
+	if r.quiet >= 0 {
+		// A negative margin crops the bitmap's own quiet zone.
+		r.margin = r.quiet - c.QuietZone
+	}
+	if r.fg == nil {
+		r.fg = blackColor
+	}
+	if r.bg == nil {
+		r.bg = whiteColor
+	}
+	if r.grad != nil && r.minContrast > 0 {
+		r.grad = r.grad.clamp(r.bg, r.minContrast)
+	}
+	return r
+}
+
+// Image returns an Image displaying the code.
+// It can be passed directly to png.Encode, draw.Draw, and so on.
+func (c *Code) Image(opts ...RenderOption) image.Image {
+	return &codeImage{c, c.render(opts)}
+}
+
+// DrawTo draws the code onto dst with its top-left corner at the
+// point at, as Image would display it, without allocating an image.
+// Pixels outside dst's bounds are clipped.  Colors with transparency
+// are composited over dst.
+func (c *Code) DrawTo(dst draw.Image, at image.Point, opts ...RenderOption) {
+	img := c.Image(opts...)
+	draw.Draw(dst, img.Bounds().Add(at), img, image.Point{}, draw.Over)
+}
+
+// codeImage implements image.Image
+type codeImage struct {
+	*Code
+	r render
+}
+
+var (
+	whiteColor color.Color = color.Gray{0xFF}
+	blackColor color.Color = color.Gray{0x00}
+)
+
+func (c *codeImage) Bounds() image.Rectangle {
+	d := (c.Size + 2*c.r.margin) * c.r.scale
+	return image.Rect(0, 0, d, d)
+}
+
+func (c *codeImage) At(x, y int) color.Color {
+	if x >= 0 && y >= 0 && c.imageBlack(&c.r, x, y) {
+		if g := c.r.grad; g != nil {
+			s := float64(c.r.scale)
+			return g.colorAt(c.Size+2*c.r.margin, (float64(x)+0.5)/s, (float64(y)+0.5)/s)
+		}
+		return c.r.fg
+	}
+	return c.r.bg
+}
+
+func (c *codeImage) ColorModel() color.Model {
+	if c.r.grad != nil {
+		return color.NRGBAModel
+	}
+	if c.r.gray() {
+		return color.GrayModel
+	}
+	return color.Palette{c.r.fg, c.r.bg}
+}
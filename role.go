@@ -0,0 +1,107 @@
+// Copyright 2011 The Go Authors.  All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package qr
+
+// Pixel roles for custom QR code renderers.
+
+import (
+	"strconv"
+
+	"github.com/inkstray/rsc-qr/coding"
+)
+
+// A PixelRole describes the role of a QR pixel in a code.
+type PixelRole int
+
+const (
+	RoleQuiet     PixelRole = iota // quiet zone included in the bitmap
+	RoleFinder                     // finder patterns and their separators
+	RoleAlignment                  // alignment patterns
+	RoleTiming                     // timing patterns
+	RoleFormat                     // format information and the dark module
+	RoleVersion                    // version information
+	RoleData                       // data bits, including padding
+	RoleCheck                      // error correction bits
+)
+
+var roleNames = []string{
+	"quiet",
+	"finder",
+	"alignment",
+	"timing",
+	"format",
+	"version",
+	"data",
+	"check",
+}
+
+func (r PixelRole) String() string {
+	if RoleQuiet <= r && r <= RoleCheck {
+		return roleNames[r]
+	}
+	return "PixelRole(" + strconv.Itoa(int(r)) + ")"
+}
+
+// codingRoles maps coding.PixelRole to PixelRole.
+var codingRoles = [...]PixelRole{
+	coding.Position:  RoleFinder,
+	coding.Alignment: RoleAlignment,
+	coding.Timing:    RoleTiming,
+	coding.Format:    RoleFormat,
+	coding.PVersion:  RoleVersion,
+	coding.Unused:    RoleFormat,
+	coding.Data:      RoleData,
+	coding.Check:     RoleCheck,
+	coding.Extra:     RoleData,
+}
+
+// Render calls f for each pixel of c's bitmap, in row-major order,
+// with the pixel's coordinates, its color, and its role in the code.
+// It lets custom renderers draw a code without decoding the bitmap
+// or locating the function patterns themselves.
+// If c is not a standard QR code size, every pixel outside the
+// quiet zone is reported as RoleData.
+func Render(c *Code, f func(x, y int, black bool, role PixelRole)) {
+	pix := c.pixelMap()
+	for y := 0; y < c.Size; y++ {
+		for x := 0; x < c.Size; x++ {
+			f(x, y, c.Black(x, y), c.role(pix, x, y))
+		}
+	}
+}
+
+// role returns the role of the pixel (x, y) of c, which has pixel map pix.
+// Pixels outside the bitmap are RoleQuiet.
+func (c *Code) role(pix [][]coding.Pixel, x, y int) PixelRole {
+	q := c.QuietZone
+	if x < q || y < q || x >= c.Size-q || y >= c.Size-q {
+		return RoleQuiet
+	}
+	if pix != nil {
+		if r := pix[y-q][x-q].Role(); int(r) < len(codingRoles) && r != 0 {
+			return codingRoles[r]
+		}
+	}
+	return RoleData
+}
+
+// pixelMap returns the coding pixel map for c, without its quiet zone,
+// or nil if c is not a standard size.
+func (c *Code) pixelMap() [][]coding.Pixel {
+	n := c.Size - 2*c.QuietZone
+	if n < 21 || (n-17)%4 != 0 {
+		return nil
+	}
+	v := coding.Version((n - 17) / 4)
+	l := coding.Level(c.Level)
+	if c.Info != nil {
+		l = c.Info.Level
+	}
+	pix, err := coding.PixelMap(v, l)
+	if err != nil {
+		return nil
+	}
+	return pix
+}
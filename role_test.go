@@ -0,0 +1,46 @@
+// Copyright 2011 The Go Authors.  All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package qr
+
+import "testing"
+
+func TestRender(t *testing.T) {
+	c, err := newEncoder(t, WithLevel(L), WithMaxVersion(1), WithQuietZone(2)).Encode("hello, world")
+	if err != nil {
+		t.Fatal(err)
+	}
+	count := make(map[PixelRole]int)
+	n := 0
+	Render(c, func(x, y int, black bool, role PixelRole) {
+		if x != n%c.Size || y != n/c.Size {
+			t.Fatalf("pixel %d at %d,%d, want %d,%d", n, x, y, n%c.Size, n/c.Size)
+		}
+		n++
+		if black != c.Black(x, y) {
+			t.Fatalf("pixel %d,%d black = %v, want %v", x, y, black, c.Black(x, y))
+		}
+		if role == RoleQuiet && black {
+			t.Fatalf("quiet zone pixel %d,%d is black", x, y)
+		}
+		count[role]++
+	})
+	if n != c.Size*c.Size {
+		t.Errorf("Render visited %d pixels, want %d", n, c.Size*c.Size)
+	}
+	// A version 1-L code has 19 data and 7 check bytes.
+	want := map[PixelRole]int{
+		RoleQuiet:  25*25 - 21*21,
+		RoleFinder: 3 * 64,
+		RoleTiming: 2 * 5,
+		RoleFormat: 2*15 + 1,
+		RoleData:   19 * 8,
+		RoleCheck:  7 * 8,
+	}
+	for role := RoleQuiet; role <= RoleCheck; role++ {
+		if count[role] != want[role] {
+			t.Errorf("%d %v pixels, want %d", count[role], role, want[role])
+		}
+	}
+}
@@ -0,0 +1,84 @@
+// Copyright 2011 The Go Authors.  All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package qr
+
+// Row-at-a-time rendering of QR codes.
+
+// A Rows iterates over the rows of pixels of a rendered code, scaled
+// and with the margin, one at a time, so that large codes can be
+// streamed to printers or small devices without building the whole
+// image.  It holds only one row in memory.
+//
+// Use it like bufio.Scanner:
+//
+//	rows := c.Rows(qr.ModuleSize(4))
+//	for rows.Next() {
+//		send(rows.Bits())
+//	}
+type Rows struct {
+	c   *Code
+	r   render
+	d   int    // image size in pixels
+	y   int    // current row, or -1 before the first call to Next
+	qy  int    // QR pixel row of row, or a value outside the code
+	row []byte // packed current row
+}
+
+// Rows returns an iterator over the rows of the image of c drawn
+// with the given options.  Colors and shapes are ignored.
+func (c *Code) Rows(opts ...RenderOption) *Rows {
+	return c.rows(c.render(opts))
+}
+
+func (c *Code) rows(r render) *Rows {
+	d := (c.Size + 2*r.margin) * r.scale
+	return &Rows{c: c, r: r, d: d, y: -1, qy: -1 << 30, row: make([]byte, (d+7)/8)}
+}
+
+// Next advances to the next row, reporting whether there is one.
+func (it *Rows) Next() bool {
+	if it.y+1 >= it.d {
+		it.y = it.d
+		return false
+	}
+	it.y++
+	qy := it.y/it.r.scale - it.r.margin
+	if qy == it.qy {
+		// Same QR row as before: the pixels are unchanged.
+		return true
+	}
+	it.qy = qy
+	for i := range it.row {
+		it.row[i] = 0
+	}
+	for x := 0; x < it.d; x++ {
+		if it.c.Black(x/it.r.scale-it.r.margin, qy) {
+			it.row[x/8] |= 0x80 >> uint(x&7)
+		}
+	}
+	return true
+}
+
+// Size returns the width and height of the image in pixels.
+func (it *Rows) Size() int {
+	return it.d
+}
+
+// Y returns the index of the current row, starting at 0.
+func (it *Rows) Y() int {
+	return it.y
+}
+
+// Bits returns the current row as packed bits, most significant bit
+// first, with 1 for black and 0 for white.  Unused bits at the end of
+// the row are 0.  The slice is overwritten by later calls to Next.
+func (it *Rows) Bits() []byte {
+	return it.row
+}
+
+// Black reports whether pixel x of the current row is black.
+func (it *Rows) Black(x int) bool {
+	return 0 <= x && x < it.d && it.row[x/8]&(0x80>>uint(x&7)) != 0
+}
@@ -0,0 +1,39 @@
+// Copyright 2011 The Go Authors.  All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package qr
+
+import "testing"
+
+func TestRows(t *testing.T) {
+	c, err := newEncoder(t, WithVersionRange(40, 40), WithLevel(L)).Encode("hello, world")
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, opts := range netpbmTests {
+		rows := c.Rows(opts...)
+		d := rows.Size()
+		pix := make([][]bool, 0, d)
+		for rows.Next() {
+			if rows.Y() != len(pix) {
+				t.Fatalf("Y = %d, want %d", rows.Y(), len(pix))
+			}
+			if len(rows.Bits()) != (d+7)/8 {
+				t.Fatalf("Bits has %d bytes, want %d", len(rows.Bits()), (d+7)/8)
+			}
+			row := make([]bool, d)
+			for x := range row {
+				row[x] = rows.Black(x)
+			}
+			pix = append(pix, row)
+		}
+		if rows.Next() {
+			t.Errorf("Next after end = true")
+		}
+		if len(pix) != d {
+			t.Fatalf("got %d rows, want %d", len(pix), d)
+		}
+		checkPixels(t, "Rows", c, opts, d, func(x, y int) bool { return pix[y][x] })
+	}
+}
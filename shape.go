@@ -0,0 +1,130 @@
+// Copyright 2011 The Go Authors.  All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package qr
+
+// Module shapes for QR codes.
+
+import (
+	"fmt"
+	"io"
+)
+
+// A Shape is the shape used to draw each black QR pixel.
+type Shape int
+
+const (
+	ShapeSquare  Shape = iota // plain squares, the default
+	ShapeCircle               // circles
+	ShapeRounded              // squares with rounded corners
+	ShapeDiamond              // squares rotated 45 degrees
+)
+
+// ModuleShape sets the shape used to draw black QR pixels.
+// For ShapeCircle and ShapeDiamond, size is the diameter of the shape
+// as a fraction of the QR pixel size, default 1.  For ShapeRounded,
+// size is the corner radius as a fraction of half the QR pixel size,
+// default 0.5.  A size of 0 selects the default.
+//
+// The three finder patterns in the corners are always drawn as solid
+// squares, so that scanners can still locate the code.
+// Only Image, PNG, SVG, and HTML output draw shapes;
+// the other formats always draw squares.
+func ModuleShape(shape Shape, size float64) RenderOption {
+	return func(r *render) { r.shape, r.shapeSize = shape, size }
+}
+
+// shapeRadius returns the radius of r's shape as a fraction of
+// the QR pixel size.
+func (r *render) shapeRadius() float64 {
+	s := r.shapeSize
+	if s <= 0 || s > 1 {
+		s = 1
+		if r.shape == ShapeRounded {
+			s = 0.5
+		}
+	}
+	return s / 2
+}
+
+// inFinder reports whether the QR pixel (x, y) is part of a finder pattern.
+func (c *Code) inFinder(x, y int) bool {
+	x -= c.QuietZone
+	y -= c.QuietZone
+	n := c.Size - 2*c.QuietZone
+	return (x >= 0 && y >= 0 && x < 7 && y < 7) ||
+		(x >= n-7 && x < n && y >= 0 && y < 7) ||
+		(x >= 0 && x < 7 && y >= n-7 && y < n)
+}
+
+// imageBlack reports whether the image pixel (x, y) is black
+// when drawing c with the settings r.
+func (c *Code) imageBlack(r *render, x, y int) bool {
+	mx, my := x/r.scale-r.margin, y/r.scale-r.margin
+	if !c.Black(mx, my) {
+		return false
+	}
+	if r.shape == ShapeSquare || c.inFinder(mx, my) {
+		return true
+	}
+	// Position of the pixel center relative to the module center,
+	// in module units.
+	u := (float64(x%r.scale)+0.5)/float64(r.scale) - 0.5
+	v := (float64(y%r.scale)+0.5)/float64(r.scale) - 0.5
+	if u < 0 {
+		u = -u
+	}
+	if v < 0 {
+		v = -v
+	}
+	rad := r.shapeRadius()
+	switch r.shape {
+	case ShapeCircle:
+		return u*u+v*v <= rad*rad
+	case ShapeDiamond:
+		return u+v <= rad
+	case ShapeRounded:
+		if u <= 0.5-rad || v <= 0.5-rad {
+			return true
+		}
+		u -= 0.5 - rad
+		v -= 0.5 - rad
+		return u*u+v*v <= rad*rad
+	}
+	return true
+}
+
+// writeShapes writes SVG path commands drawing the pixels of c
+// for which black returns true with r's shape, offset by r's margin.
+func (c *Code) writeShapes(b io.Writer, r *render, black func(x, y int) bool) {
+	rad := r.shapeRadius()
+	for y := 0; y < c.Size; y++ {
+		for x := 0; x < c.Size; x++ {
+			if !black(x, y) {
+				continue
+			}
+			px, py := x+r.margin, y+r.margin
+			if c.inFinder(x, y) {
+				fmt.Fprintf(b, "M%d %dh1v1h-1z", px, py)
+				continue
+			}
+			cx, cy := float64(px)+0.5, float64(py)+0.5
+			switch r.shape {
+			case ShapeCircle:
+				fmt.Fprintf(b, "M%g %ga%g %g 0 1 0 %g 0a%g %g 0 1 0 %g 0z",
+					cx-rad, cy, rad, rad, 2*rad, rad, rad, -2*rad)
+			case ShapeDiamond:
+				fmt.Fprintf(b, "M%g %gl%g %gl%g %gl%g %gz",
+					cx, cy-rad, rad, rad, -rad, rad, -rad, -rad)
+			case ShapeRounded:
+				e := 1 - 2*rad // length of each straight edge
+				fmt.Fprintf(b, "M%g %dh%ga%g %g 0 0 1 %g %gv%ga%g %g 0 0 1 %g %gh%ga%g %g 0 0 1 %g %gv%ga%g %g 0 0 1 %g %gz",
+					float64(px)+rad, py, e, rad, rad, rad, rad,
+					e, rad, rad, -rad, rad,
+					-e, rad, rad, -rad, -rad,
+					-e, rad, rad, rad, -rad)
+			}
+		}
+	}
+}
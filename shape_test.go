@@ -0,0 +1,81 @@
+// Copyright 2011 The Go Authors.  All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package qr
+
+import (
+	"bytes"
+	"encoding/xml"
+	"image"
+	"image/color"
+	"image/png"
+	"testing"
+)
+
+func TestModuleShape(t *testing.T) {
+	c, err := Encode("hello, world", L)
+	if err != nil {
+		t.Fatal(err)
+	}
+	const scale = 10
+	m := c.border()
+	for _, shape := range []Shape{ShapeSquare, ShapeCircle, ShapeRounded, ShapeDiamond} {
+		opts := []RenderOption{ModuleSize(scale), ModuleShape(shape, 0)}
+
+		// PNG and Image draw the same pixels.
+		img := c.Image(opts...)
+		p, err := png.Decode(bytes.NewReader(c.PNG(opts...)))
+		if err != nil {
+			t.Fatalf("shape %d: png.Decode: %v", shape, err)
+		}
+		if p.Bounds() != img.Bounds() {
+			t.Fatalf("shape %d: PNG bounds %v, Image bounds %v", shape, p.Bounds(), img.Bounds())
+		}
+		for y := 0; y < img.Bounds().Dy(); y++ {
+			for x := 0; x < img.Bounds().Dx(); x++ {
+				if !sameColor(p.At(x, y), img.At(x, y)) {
+					t.Fatalf("shape %d: pixel (%d, %d) differs between PNG and Image", shape, x, y)
+				}
+			}
+		}
+
+		for y := 0; y < c.Size; y++ {
+			for x := 0; x < c.Size; x++ {
+				if !c.Black(x, y) {
+					continue
+				}
+				// The center of each black module is black.
+				if !isBlack(img, (x+m)*scale+scale/2, (y+m)*scale+scale/2) {
+					t.Fatalf("shape %d: center of module (%d, %d) is white", shape, x, y)
+				}
+				// Corners are black only for squares and finder patterns.
+				corner := isBlack(img, (x+m)*scale, (y+m)*scale)
+				if want := shape == ShapeSquare || c.inFinder(x, y); corner != want {
+					t.Fatalf("shape %d: corner of module (%d, %d) black = %v, want %v", shape, x, y, corner, want)
+				}
+			}
+		}
+
+		// SVG output is well-formed.
+		var doc struct {
+			Path struct {
+				D string `xml:"d,attr"`
+			} `xml:"path"`
+		}
+		if err := xml.Unmarshal(c.SVG(opts...), &doc); err != nil || doc.Path.D == "" {
+			t.Errorf("shape %d: bad SVG: %v", shape, err)
+		}
+	}
+}
+
+func sameColor(a, b color.Color) bool {
+	r1, g1, b1, a1 := a.RGBA()
+	r2, g2, b2, a2 := b.RGBA()
+	return r1 == r2 && g1 == g2 && b1 == b2 && a1 == a2
+}
+
+func isBlack(img image.Image, x, y int) bool {
+	r, _, _, _ := img.At(x, y).RGBA()
+	return r == 0
+}
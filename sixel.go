@@ -0,0 +1,86 @@
+// Copyright 2011 The Go Authors.  All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package qr
+
+// Sixel writer for QR codes.
+
+import (
+	"bufio"
+	"fmt"
+	"image/color"
+	"io"
+)
+
+// Sixel writes the code to w as Sixel graphics, which terminals such as
+// xterm, mlterm, and WezTerm display as an image.
+// ModuleSize sets the number of screen pixels per QR pixel.
+func (c *Code) Sixel(w io.Writer, opts ...RenderOption) error {
+	r := c.render(opts)
+	d := (c.Size + 2*r.margin) * r.scale
+	var rows [][]byte
+	c.packedRows(r, func(row []byte) {
+		rows = append(rows, append([]byte(nil), row...))
+	})
+	black := func(x, y int) bool {
+		return y < d && rows[y][x/8]&(0x80>>uint(x&7)) != 0
+	}
+
+	bw := bufio.NewWriter(w)
+	// Start sixel mode with square pixels and set the image size.
+	fmt.Fprintf(bw, "\x1bP0;1q\"1;1;%d;%d", d, d)
+	// Register 0 is the background, 1 the foreground.
+	for i, col := range []color.Color{r.bg, r.fg} {
+		n := color.NRGBAModel.Convert(col).(color.NRGBA)
+		fmt.Fprintf(bw, "#%d;2;%d;%d;%d", i, pct(n.R), pct(n.G), pct(n.B))
+	}
+	for y := 0; y < d; y += 6 {
+		if y > 0 {
+			bw.WriteByte('-') // next band
+		}
+		for reg := 0; reg < 2; reg++ {
+			if reg > 0 {
+				bw.WriteByte('$') // back to start of band
+			}
+			fmt.Fprintf(bw, "#%d", reg)
+			var last byte
+			n := 0
+			for x := 0; x < d; x++ {
+				var six byte
+				for i := 0; i < 6 && y+i < d; i++ {
+					if black(x, y+i) == (reg == 1) {
+						six |= 1 << uint(i)
+					}
+				}
+				if six != last && n > 0 {
+					writeSixels(bw, last, n)
+					n = 0
+				}
+				last = six
+				n++
+			}
+			writeSixels(bw, last, n)
+		}
+	}
+	bw.WriteString("\x1b\\")
+	return bw.Flush()
+}
+
+// writeSixels writes n copies of the sixel with bits six,
+// using run-length encoding when shorter.
+func writeSixels(w *bufio.Writer, six byte, n int) {
+	ch := '?' + six
+	if n > 3 {
+		fmt.Fprintf(w, "!%d%c", n, ch)
+		return
+	}
+	for ; n > 0; n-- {
+		w.WriteByte(ch)
+	}
+}
+
+// pct returns v, a color component from 0 to 255, as a percentage.
+func pct(v uint8) int {
+	return (int(v)*100 + 127) / 255
+}
@@ -0,0 +1,85 @@
+// Copyright 2011 The Go Authors.  All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package qr
+
+import (
+	"bytes"
+	"fmt"
+	"strings"
+	"testing"
+)
+
+// decodeSixel decodes the sixel data written by Code.Sixel,
+// returning the register of each pixel.
+func decodeSixel(t *testing.T, s string) (d int, pix []int) {
+	if !strings.HasPrefix(s, "\x1bP0;1q\"1;1;") || !strings.HasSuffix(s, "\x1b\\") {
+		t.Fatalf("bad sixel framing %q", s)
+	}
+	s = strings.TrimSuffix(strings.TrimPrefix(s, "\x1bP0;1q\"1;1;"), "\x1b\\")
+	var h int
+	if _, err := fmt.Sscanf(s, "%d;%d", &d, &h); err != nil || d != h {
+		t.Fatalf("bad raster attributes: %v", err)
+	}
+	s = s[strings.Index(s, "#"):]
+	pix = make([]int, d*d)
+	for i := range pix {
+		pix[i] = -1
+	}
+	x, y, reg := 0, 0, 0
+	for len(s) > 0 {
+		ch := s[0]
+		s = s[1:]
+		n := 1
+		switch {
+		case ch == '#':
+			i := strings.IndexAny(s, "?@ABCDEFGHIJKLMNOPQRSTUVWXYZ[\\]^_`abcdefghijklmnopqrstuvwxyz{|}~!#$-")
+			if strings.Contains(s[:i], ";") {
+				s = s[i:] // color definition
+				continue
+			}
+			fmt.Sscanf(s[:i], "%d", &reg)
+			s = s[i:]
+			continue
+		case ch == '$':
+			x = 0
+			continue
+		case ch == '-':
+			x, y = 0, y+6
+			continue
+		case ch == '!':
+			i := strings.IndexFunc(s, func(r rune) bool { return r < '0' || r > '9' })
+			fmt.Sscanf(s[:i], "%d", &n)
+			ch, s = s[i], s[i+1:]
+		}
+		for ; n > 0; n-- {
+			for i := 0; i < 6; i++ {
+				if (ch-'?')&(1<<uint(i)) != 0 {
+					pix[(y+i)*d+x] = reg
+				}
+			}
+			x++
+		}
+	}
+	return d, pix
+}
+
+func TestSixel(t *testing.T) {
+	c, err := Encode("hello, world", L)
+	if err != nil {
+		t.Fatal(err)
+	}
+	opts := []RenderOption{ModuleSize(2)}
+	var buf bytes.Buffer
+	if err := c.Sixel(&buf, opts...); err != nil {
+		t.Fatal(err)
+	}
+	d, pix := decodeSixel(t, buf.String())
+	checkPixels(t, "Sixel", c, opts, d, func(x, y int) bool {
+		if pix[y*d+x] < 0 {
+			t.Fatalf("pixel %d,%d not drawn", x, y)
+		}
+		return pix[y*d+x] == 1
+	})
+}
@@ -0,0 +1,145 @@
+// Copyright 2011 The Go Authors.  All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package qr
+
+// Frame buffer and C and Go source writers for QR codes,
+// for displays and firmware.
+
+import (
+	"bytes"
+	"fmt"
+)
+
+// A Layout describes how a bitmap is packed into bytes.
+// Black pixels are 1 bits.
+//
+// In the default row-major layout, each byte holds 8 horizontally
+// adjacent pixels, and each row of pixels is a separate run of bytes.
+// In the column-major layout used by SSD1306 OLED displays and many
+// e-paper controllers, each byte holds 8 vertically adjacent pixels,
+// and the bytes for each band ("page") of 8 rows run left to right.
+type Layout struct {
+	ColumnMajor bool // pack 8 vertically adjacent pixels per byte
+	LSBFirst    bool // store the leftmost (or topmost) pixel in the low bit
+	RowAlign    int  // pad each row (or page) to a multiple of RowAlign bytes
+}
+
+// String returns a short description of l.
+func (l Layout) String() string {
+	order := "MSB first"
+	if l.LSBFirst {
+		order = "LSB first"
+	}
+	if l.ColumnMajor {
+		return "column-major, " + order
+	}
+	return order
+}
+
+// stride returns the number of bytes in each row (or page)
+// of a d×d bitmap packed with l.
+func (l Layout) stride(d int) int {
+	n := (d + 7) / 8
+	if l.ColumnMajor {
+		n = d
+	}
+	if l.RowAlign > 1 {
+		n = (n + l.RowAlign - 1) / l.RowAlign * l.RowAlign
+	}
+	return n
+}
+
+// Framebuffer returns the code drawn as a 1-bit bitmap packed
+// according to l, for copying directly into a display's frame buffer.
+// For a bitmap d pixels square, the result holds d rows, or (d+7)/8
+// pages if l.ColumnMajor is set, each padded to l.RowAlign bytes.
+// For example, an SSD1306 OLED display takes
+// Layout{ColumnMajor: true, LSBFirst: true}.  Colors are ignored.
+func (c *Code) Framebuffer(l Layout, opts ...RenderOption) []byte {
+	data, _, _ := c.pack(c.render(opts), l)
+	return data
+}
+
+// pack returns the image of c drawn with r packed according to l,
+// along with the image size in pixels and the bytes per row or page.
+func (c *Code) pack(r render, l Layout) (data []byte, d, stride int) {
+	d = (c.Size + 2*r.margin) * r.scale
+	stride = l.stride(d)
+	if l.ColumnMajor {
+		data = make([]byte, stride*((d+7)/8))
+		for y := 0; y < d; y++ {
+			bit := byte(0x80 >> uint(y&7))
+			if l.LSBFirst {
+				bit = 1 << uint(y&7)
+			}
+			row := data[y/8*stride:]
+			for x := 0; x < d; x++ {
+				if c.Black(x/r.scale-r.margin, y/r.scale-r.margin) {
+					row[x] |= bit
+				}
+			}
+		}
+		return data, d, stride
+	}
+	data = make([]byte, 0, stride*d)
+	c.packedRows(r, func(row []byte) {
+		for _, v := range row {
+			if l.LSBFirst {
+				v = reverseBits(v)
+			}
+			data = append(data, v)
+		}
+		for i := len(row); i < stride; i++ {
+			data = append(data, 0)
+		}
+	})
+	return data, d, stride
+}
+
+// writeBytes writes data as a comma-separated list of hex bytes,
+// 12 to a line, each line starting with indent.
+func writeBytes(b *bytes.Buffer, data []byte, indent string) {
+	for i, v := range data {
+		if i%12 == 0 {
+			b.WriteString(indent)
+		} else {
+			b.WriteString(" ")
+		}
+		fmt.Fprintf(b, "0x%02x,", v)
+		if i%12 == 11 || i == len(data)-1 {
+			b.WriteString("\n")
+		}
+	}
+}
+
+// CArray returns C source code declaring the code's bitmap, packed
+// according to l, as a const uint8_t array named name, along with
+// name_WIDTH, name_HEIGHT, and name_STRIDE macros giving its size
+// in pixels and its bytes per row (or page).  Colors are ignored.
+func (c *Code) CArray(name string, l Layout, opts ...RenderOption) []byte {
+	data, d, stride := c.pack(c.render(opts), l)
+	var b bytes.Buffer
+	fmt.Fprintf(&b, "/* QR code, %dx%d pixels, %s, %d bytes per row. */\n", d, d, l, stride)
+	fmt.Fprintf(&b, "#define %s_WIDTH %d\n#define %s_HEIGHT %d\n#define %s_STRIDE %d\n", name, d, name, d, name, stride)
+	fmt.Fprintf(&b, "const uint8_t %s[%d] = {\n", name, len(data))
+	writeBytes(&b, data, "\t")
+	b.WriteString("};\n")
+	return b.Bytes()
+}
+
+// GoArray returns Go source code declaring the code's bitmap, packed
+// according to l, as a []byte variable named name, along with
+// nameWidth, nameHeight, and nameStride constants giving its size
+// in pixels and its bytes per row (or page).  Colors are ignored.
+func (c *Code) GoArray(name string, l Layout, opts ...RenderOption) []byte {
+	data, d, stride := c.pack(c.render(opts), l)
+	var b bytes.Buffer
+	fmt.Fprintf(&b, "// %s is a QR code, %dx%d pixels, %s, %d bytes per row.\n", name, d, d, l, stride)
+	fmt.Fprintf(&b, "var %s = []byte{\n", name)
+	writeBytes(&b, data, "\t")
+	b.WriteString("}\n\n")
+	fmt.Fprintf(&b, "const (\n\t%sWidth  = %d\n\t%sHeight = %d\n\t%sStride = %d\n)\n", name, d, name, d, name, stride)
+	return b.Bytes()
+}
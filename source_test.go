@@ -0,0 +1,113 @@
+// Copyright 2011 The Go Authors.  All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package qr
+
+import (
+	"bytes"
+	"go/format"
+	"go/parser"
+	"go/token"
+	"regexp"
+	"strconv"
+	"testing"
+)
+
+var hexByte = regexp.MustCompile(`0x[0-9a-f]{2}`)
+
+// sourceBytes returns the hex bytes listed in src.
+func sourceBytes(t *testing.T, src []byte) []byte {
+	var data []byte
+	for _, m := range hexByte.FindAll(src, -1) {
+		v, err := strconv.ParseUint(string(m[2:]), 16, 8)
+		if err != nil {
+			t.Fatal(err)
+		}
+		data = append(data, byte(v))
+	}
+	return data
+}
+
+func TestSourceArrays(t *testing.T) {
+	c, err := Encode("hello, world", L)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, l := range []Layout{{}, {LSBFirst: true}, {RowAlign: 4}} {
+		opts := []RenderOption{ModuleSize(2), Margin(1)}
+		d := (c.Size + 2) * 2
+		stride := (d + 7) / 8
+		if l.RowAlign > 1 {
+			stride = (stride + l.RowAlign - 1) / l.RowAlign * l.RowAlign
+		}
+		check := func(format string, data []byte) {
+			t.Helper()
+			if len(data) != stride*d {
+				t.Fatalf("%s %v: %d bytes, want %d", format, l, len(data), stride*d)
+			}
+			checkPixels(t, format, c, opts, d, func(x, y int) bool {
+				v := data[y*stride+x/8]
+				if l.LSBFirst {
+					return v&(1<<uint(x&7)) != 0
+				}
+				return v&(0x80>>uint(x&7)) != 0
+			})
+		}
+
+		src := c.CArray("qr_code", l, opts...)
+		if !bytes.Contains(src, []byte("const uint8_t qr_code[")) ||
+			!bytes.Contains(src, []byte("#define qr_code_STRIDE "+strconv.Itoa(stride)+"\n")) {
+			t.Errorf("bad C source:\n%s", src)
+		}
+		check("CArray", sourceBytes(t, src))
+
+		src = c.GoArray("qrCode", l, opts...)
+		file := append([]byte("package p\n\n"), src...)
+		if _, err := parser.ParseFile(token.NewFileSet(), "x.go", file, 0); err != nil {
+			t.Errorf("GoArray does not parse: %v\n%s", err, src)
+		}
+		if f, err := format.Source(file); err != nil || !bytes.Equal(f, file) {
+			t.Errorf("GoArray is not gofmt-formatted:\n%s", src)
+		}
+		check("GoArray", sourceBytes(t, src))
+	}
+}
+
+func TestFramebuffer(t *testing.T) {
+	c, err := Encode("hello, world", L)
+	if err != nil {
+		t.Fatal(err)
+	}
+	opts := []RenderOption{ModuleSize(1), Margin(2)}
+	d := c.Size + 4 // 25: not a multiple of 8
+	for _, l := range []Layout{
+		{},
+		{LSBFirst: true, RowAlign: 2},
+		{ColumnMajor: true},
+		{ColumnMajor: true, LSBFirst: true, RowAlign: 16},
+	} {
+		data := c.Framebuffer(l, opts...)
+		stride := l.stride(d)
+		rows := d
+		if l.ColumnMajor {
+			rows = (d + 7) / 8
+			if stride < d {
+				t.Fatalf("%v: stride %d < width %d", l, stride, d)
+			}
+		}
+		if len(data) != stride*rows {
+			t.Fatalf("%v: %d bytes, want %d", l, len(data), stride*rows)
+		}
+		checkPixels(t, "Framebuffer "+l.String(), c, opts, d, func(x, y int) bool {
+			i, bit := y*stride+x/8, uint(x&7)
+			if l.ColumnMajor {
+				i, bit = y/8*stride+x, uint(y&7)
+			}
+			if !l.LSBFirst {
+				bit = 7 - bit
+			}
+			return data[i]&(1<<bit) != 0
+		})
+	}
+}
@@ -0,0 +1,161 @@
+// Copyright 2011 The Go Authors.  All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package qr
+
+// SVG writer for QR codes.
+
+import (
+	"bufio"
+	"bytes"
+	"fmt"
+	"image/color"
+	"io"
+)
+
+// SVG returns an SVG image displaying the code.
+// The image uses one coordinate unit per QR pixel, scaled to
+// ModuleSize pixels per QR pixel by the width and height attributes.
+//
+// Black pixels are drawn as a single path made of the fewest rectangles
+// found by merging horizontal runs of black pixels with identical runs
+// in the rows below, so the output stays small and renders without
+// hairline gaps between pixels.  With SVGClasses(true), each pixel role
+// is drawn as a separate path instead, so that style sheets can
+// restyle them.
+func (c *Code) SVG(opts ...RenderOption) []byte {
+	r := c.render(opts)
+	var b bytes.Buffer
+	d := c.Size + 2*r.margin
+	c.writeSVG(&b, &r, fmt.Sprint(d*r.scale))
+	return b.Bytes()
+}
+
+// writeSVG writes the SVG image of c to b, with the given width and height.
+func (c *Code) writeSVG(b io.Writer, r *render, size string) {
+	d := c.Size + 2*r.margin
+	fmt.Fprintf(b, `<svg xmlns="http://www.w3.org/2000/svg" version="1.1" width="%s" height="%s" viewBox="0 0 %d %d" shape-rendering="crispEdges">`+"\n",
+		size, size, d, d)
+	class := ""
+	if r.svgClasses {
+		class = ` class="qr-quiet"`
+	}
+	fmt.Fprintf(b, `<rect%s width="%d" height="%d"%s/>`+"\n", class, d, d, svgFill(r.bg))
+	fill := svgFill(r.fg)
+	if r.grad != nil {
+		r.grad.writeSVG(b, d)
+		fill = fmt.Sprintf(` fill="url(#%s)"`, r.grad.id(d))
+	}
+	if !r.svgClasses {
+		fmt.Fprintf(b, `<path%s d="`, fill)
+		c.writePath(b, r, c.Black)
+		io.WriteString(b, "\"/>\n</svg>\n")
+		return
+	}
+	pix := c.pixelMap()
+	for role := RoleQuiet + 1; role <= RoleCheck; role++ {
+		role := role
+		black := func(x, y int) bool { return c.Black(x, y) && c.role(pix, x, y) == role }
+		if !c.any(black) {
+			continue
+		}
+		fmt.Fprintf(b, `<path class="qr-%s"%s d="`, role, fill)
+		c.writePath(b, r, black)
+		io.WriteString(b, "\"/>\n")
+	}
+	io.WriteString(b, "</svg>\n")
+}
+
+// any reports whether black returns true for any pixel of c.
+func (c *Code) any(black func(x, y int) bool) bool {
+	for y := 0; y < c.Size; y++ {
+		for x := 0; x < c.Size; x++ {
+			if black(x, y) {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// writePath writes SVG path commands drawing the pixels of c
+// for which black returns true, offset by r's margin.
+func (c *Code) writePath(b io.Writer, r *render, black func(x, y int) bool) {
+	if r.shape != ShapeSquare {
+		c.writeShapes(b, r, black)
+		return
+	}
+	for _, rc := range c.rectsOf(black) {
+		x, y := rc.x+r.margin, rc.y+r.margin
+		fmt.Fprintf(b, "M%d %dh%dv%dh-%dz", x, y, rc.w, rc.h, rc.w)
+	}
+}
+
+// WriteSVG writes an SVG image displaying the code to w.
+// See SVG.
+func (c *Code) WriteSVG(w io.Writer, opts ...RenderOption) error {
+	r := c.render(opts)
+	b := bufio.NewWriter(w)
+	d := c.Size + 2*r.margin
+	c.writeSVG(b, &r, fmt.Sprint(d*r.scale))
+	return b.Flush()
+}
+
+// svgFill returns the fill attributes for col.
+func svgFill(col color.Color) string {
+	n := color.NRGBAModel.Convert(col).(color.NRGBA)
+	s := fmt.Sprintf(` fill="#%02x%02x%02x"`, n.R, n.G, n.B)
+	if n.A != 0xff {
+		s += fmt.Sprintf(` fill-opacity="%.3g"`, float64(n.A)/0xff)
+	}
+	return s
+}
+
+// A rect is a rectangle of black pixels in a code.
+type rect struct {
+	x, y, w, h int
+}
+
+// rects returns a set of rectangles that exactly cover the black pixels of c.
+func (c *Code) rects() []rect {
+	return c.rectsOf(c.Black)
+}
+
+// rectsOf returns a set of rectangles that exactly cover the pixels
+// of c for which black returns true.
+// Each horizontal run of such pixels is merged with the identical runs
+// directly below it.
+func (c *Code) rectsOf(black func(x, y int) bool) []rect {
+	// run returns the length of the run of black pixels starting at (x, y).
+	run := func(x, y int) int {
+		n := 0
+		for x+n < c.Size && black(x+n, y) {
+			n++
+		}
+		return n
+	}
+	var rects []rect
+	done := make([]bool, c.Size*c.Size) // pixel already covered
+	for y := 0; y < c.Size; y++ {
+		for x := 0; x < c.Size; {
+			if !black(x, y) || done[y*c.Size+x] {
+				x++
+				continue
+			}
+			w := run(x, y)
+			h := 1
+			for y+h < c.Size && run(x, y+h) == w && (x == 0 || !black(x-1, y+h)) {
+				h++
+			}
+			for i := 0; i < h; i++ {
+				for j := 0; j < w; j++ {
+					done[(y+i)*c.Size+x+j] = true
+				}
+			}
+			rects = append(rects, rect{x, y, w, h})
+			x += w
+		}
+	}
+	return rects
+}
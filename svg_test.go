@@ -0,0 +1,138 @@
+// Copyright 2011 The Go Authors.  All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package qr
+
+import (
+	"bytes"
+	"encoding/xml"
+	"fmt"
+	"image/color"
+	"strings"
+	"testing"
+)
+
+func TestSVG(t *testing.T) {
+	c, err := Encode("hello, world", L)
+	if err != nil {
+		t.Fatal(err)
+	}
+	svg := c.SVG(ModuleSize(4), Margin(2), Foreground(color.NRGBA{0x11, 0x22, 0x33, 0x80}))
+
+	// The output is well-formed XML.
+	var doc struct {
+		Width   int    `xml:"width,attr"`
+		Height  int    `xml:"height,attr"`
+		ViewBox string `xml:"viewBox,attr"`
+		Path    struct {
+			Fill    string `xml:"fill,attr"`
+			Opacity string `xml:"fill-opacity,attr"`
+			D       string `xml:"d,attr"`
+		} `xml:"path"`
+	}
+	if err := xml.Unmarshal(svg, &doc); err != nil {
+		t.Fatalf("xml.Unmarshal: %v\n%s", err, svg)
+	}
+	d := c.Size + 4
+	if doc.Width != 4*d || doc.Height != 4*d || doc.ViewBox != fmt.Sprintf("0 0 %d %d", d, d) {
+		t.Errorf("size = %d, %d, %q, want %d, %d, \"0 0 %d %d\"", doc.Width, doc.Height, doc.ViewBox, 4*d, 4*d, d, d)
+	}
+	if doc.Path.Fill != "#112233" || doc.Path.Opacity != "0.502" {
+		t.Errorf("fill = %q, opacity %q, want #112233, 0.502", doc.Path.Fill, doc.Path.Opacity)
+	}
+
+	// The path covers exactly the black pixels, once each.
+	count := make([]int, c.Size*c.Size)
+	nrect := 0
+	for _, cmd := range strings.SplitAfter(doc.Path.D, "z") {
+		if cmd == "" {
+			continue
+		}
+		var x, y, w, h, w1 int
+		if _, err := fmt.Sscanf(cmd, "M%d %dh%dv%dh-%dz", &x, &y, &w, &h, &w1); err != nil || w != w1 {
+			t.Fatalf("bad path command %q: %v", cmd, err)
+		}
+		nrect++
+		for i := y - 2; i < y-2+h; i++ {
+			for j := x - 2; j < x-2+w; j++ {
+				count[i*c.Size+j]++
+			}
+		}
+	}
+	nblack := 0
+	for y := 0; y < c.Size; y++ {
+		for x := 0; x < c.Size; x++ {
+			want := 0
+			if c.Black(x, y) {
+				want = 1
+				nblack++
+			}
+			if count[y*c.Size+x] != want {
+				t.Errorf("pixel %d,%d covered %d times, want %d", x, y, count[y*c.Size+x], want)
+			}
+		}
+	}
+	if nrect*2 > nblack {
+		t.Errorf("%d rectangles for %d black pixels; merging is not effective", nrect, nblack)
+	}
+
+	var buf bytes.Buffer
+	if err := c.WriteSVG(&buf); err != nil || !bytes.Equal(buf.Bytes(), c.SVG()) {
+		t.Errorf("WriteSVG differs from SVG")
+	}
+}
+
+func TestSVGClasses(t *testing.T) {
+	c, err := Encode("hello, world", L)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var doc struct {
+		Rect struct {
+			Class string `xml:"class,attr"`
+		} `xml:"rect"`
+		Paths []struct {
+			Class string `xml:"class,attr"`
+			D     string `xml:"d,attr"`
+		} `xml:"path"`
+	}
+	svg := c.SVG(SVGClasses(true), Margin(0))
+	if err := xml.Unmarshal(svg, &doc); err != nil {
+		t.Fatalf("xml.Unmarshal: %v\n%s", err, svg)
+	}
+	if doc.Rect.Class != "qr-quiet" {
+		t.Errorf("background class = %q, want qr-quiet", doc.Rect.Class)
+	}
+
+	// Each path covers exactly the black pixels of its role.
+	roles := make(map[string]bool)
+	covered := make(map[[2]int]string)
+	for _, p := range doc.Paths {
+		roles[p.Class] = true
+		for _, cmd := range strings.SplitAfter(p.D, "z") {
+			if cmd == "" {
+				continue
+			}
+			var x, y, w, h, w1 int
+			if _, err := fmt.Sscanf(cmd, "M%d %dh%dv%dh-%dz", &x, &y, &w, &h, &w1); err != nil {
+				t.Fatalf("bad path command %q: %v", cmd, err)
+			}
+			for i := y; i < y+h; i++ {
+				for j := x; j < x+w; j++ {
+					covered[[2]int{j, i}] = p.Class
+				}
+			}
+		}
+	}
+	for _, class := range []string{"qr-finder", "qr-timing", "qr-format", "qr-data", "qr-check"} {
+		if !roles[class] {
+			t.Errorf("no path with class %s", class)
+		}
+	}
+	Render(c, func(x, y int, black bool, role PixelRole) {
+		if got := covered[[2]int{x, y}]; black && got != "qr-"+role.String() || !black && got != "" {
+			t.Errorf("pixel %d,%d (%v, black=%v) drawn with class %q", x, y, role, black, got)
+		}
+	})
+}
@@ -0,0 +1,73 @@
+// Copyright 2011 The Go Authors.  All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package qr
+
+// Terminal writer for QR codes.
+
+import (
+	"bufio"
+	"fmt"
+	"image/color"
+	"io"
+)
+
+// Terminal writes the code to w as text for display in a terminal,
+// using the Unicode half block characters ▀, ▄, and █ to draw two
+// QR pixels per character cell vertically.  Since terminal cells are
+// about twice as tall as they are wide, the code appears square.
+// ModuleSize is ignored.
+//
+// By default, Terminal draws the white pixels, including the margin,
+// as blocks, which displays correctly on terminals with light text
+// on a dark background.  With ANSIColors(true), Terminal instead
+// sets the terminal colors to the foreground and background colors
+// and draws the black pixels as blocks, which displays correctly
+// on any terminal that supports 24-bit color.
+func (c *Code) Terminal(w io.Writer, opts ...RenderOption) error {
+	r := c.render(opts)
+	bw := bufio.NewWriter(w)
+	d := c.Size + 2*r.margin
+	var start, end string
+	if r.ansi {
+		start = ansiColor(38, r.fg) + ansiColor(48, r.bg)
+		end = "\x1b[0m"
+	}
+	for y := 0; y < d; y += 2 {
+		bw.WriteString(start)
+		for x := 0; x < d; x++ {
+			top := c.Black(x-r.margin, y-r.margin)
+			bot := y+1 < d && c.Black(x-r.margin, y+1-r.margin)
+			if !r.ansi {
+				// Draw white pixels.
+				top, bot = !top, !(bot || y+1 >= d)
+			}
+			bw.WriteString(halfBlock(top, bot))
+		}
+		bw.WriteString(end)
+		bw.WriteString("\n")
+	}
+	return bw.Flush()
+}
+
+// halfBlock returns the character drawing the top half of a cell
+// if top is set and the bottom half if bot is set.
+func halfBlock(top, bot bool) string {
+	switch {
+	case top && bot:
+		return "█"
+	case top:
+		return "▀"
+	case bot:
+		return "▄"
+	}
+	return " "
+}
+
+// ansiColor returns the escape sequence setting a 24-bit color:
+// the foreground color for code 38, or the background color for 48.
+func ansiColor(code int, col color.Color) string {
+	n := color.NRGBAModel.Convert(col).(color.NRGBA)
+	return fmt.Sprintf("\x1b[%d;2;%d;%d;%dm", code, n.R, n.G, n.B)
+}
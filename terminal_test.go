@@ -0,0 +1,52 @@
+// Copyright 2011 The Go Authors.  All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package qr
+
+import (
+	"bytes"
+	"strings"
+	"testing"
+)
+
+func TestTerminal(t *testing.T) {
+	c, err := Encode("hello, world", L)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, ansi := range []bool{false, true} {
+		var buf bytes.Buffer
+		if err := c.Terminal(&buf, Margin(1), ANSIColors(ansi)); err != nil {
+			t.Fatal(err)
+		}
+		d := c.Size + 2
+		lines := strings.Split(strings.TrimSuffix(buf.String(), "\n"), "\n")
+		if len(lines) != (d+1)/2 {
+			t.Fatalf("ANSI %v: %d lines, want %d", ansi, len(lines), (d+1)/2)
+		}
+		for i, line := range lines {
+			if ansi {
+				if !strings.HasPrefix(line, "\x1b[38;2;0;0;0m\x1b[48;2;255;255;255m") || !strings.HasSuffix(line, "\x1b[0m") {
+					t.Fatalf("ANSI line %d missing color codes: %q", i, line)
+				}
+				line = strings.TrimSuffix(line, "\x1b[0m")
+				line = line[strings.LastIndex(line, "m")+1:]
+			}
+			cells := []rune(line)
+			if len(cells) != d {
+				t.Fatalf("ANSI %v: line %d has %d cells, want %d", ansi, i, len(cells), d)
+			}
+			for x, r := range cells {
+				top := r == '█' || r == '▀'
+				bot := r == '█' || r == '▄'
+				y := 2 * i
+				wantTop := c.Black(x-1, y-1) == ansi
+				wantBot := c.Black(x-1, y) == ansi && y+1 < d
+				if top != wantTop || bot != wantBot {
+					t.Errorf("ANSI %v: cell %d,%d = %q", ansi, x, i, r)
+				}
+			}
+		}
+	}
+}
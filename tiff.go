@@ -0,0 +1,98 @@
+// Copyright 2011 The Go Authors.  All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package qr
+
+// TIFF writer for QR codes.
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/binary"
+	"io"
+	"math"
+)
+
+// TIFF returns an uncompressed bilevel TIFF image displaying the code,
+// stored as a single strip.  The resolution tags record the resolution
+// set by DPI or PhysicalModuleSize, or 72 dpi if neither is given.
+// Colors are ignored.
+func (c *Code) TIFF(opts ...RenderOption) []byte {
+	var b bytes.Buffer
+	c.writeTIFF(&b, c.render(opts))
+	return b.Bytes()
+}
+
+// WriteTIFF writes a TIFF image displaying the code to w.
+// See TIFF.
+func (c *Code) WriteTIFF(w io.Writer, opts ...RenderOption) error {
+	b := bufio.NewWriter(w)
+	c.writeTIFF(b, c.render(opts))
+	return b.Flush()
+}
+
+// writeTIFF writes the TIFF image of c drawn with r to b.
+func (c *Code) writeTIFF(b io.Writer, r render) {
+	d := (c.Size + 2*r.margin) * r.scale
+	dpi := r.pixelsPerMeter() * 0.0254
+	if dpi <= 0 {
+		dpi = 72
+	}
+	res := uint32(math.Floor(dpi*100 + 0.5)) // in 1/100 dpi
+
+	const (
+		tShort    = 3
+		tLong     = 4
+		tRational = 5
+	)
+	type entry struct {
+		tag, typ uint16
+		val      uint32
+	}
+	const nentry = 12
+	const ifd = 8
+	const rational = ifd + 2 + 12*nentry + 4
+	const strip = rational + 8
+	stride := (d + 7) / 8
+	entries := [nentry]entry{
+		{256, tLong, uint32(d)},          // ImageWidth
+		{257, tLong, uint32(d)},          // ImageLength
+		{258, tShort, 1},                 // BitsPerSample
+		{259, tShort, 1},                 // Compression: none
+		{262, tShort, 0},                 // PhotometricInterpretation: WhiteIsZero
+		{273, tLong, strip},              // StripOffsets
+		{277, tShort, 1},                 // SamplesPerPixel
+		{278, tLong, uint32(d)},          // RowsPerStrip
+		{279, tLong, uint32(d * stride)}, // StripByteCounts
+		{282, tRational, rational},       // XResolution
+		{283, tRational, rational},       // YResolution
+		{296, tShort, 2},                 // ResolutionUnit: inch
+	}
+
+	le := binary.LittleEndian
+	var tmp [12]byte
+	io.WriteString(b, "II*\x00")
+	le.PutUint32(tmp[:4], ifd)
+	b.Write(tmp[:4])
+	le.PutUint16(tmp[:2], nentry)
+	b.Write(tmp[:2])
+	for _, e := range entries {
+		le.PutUint16(tmp[0:], e.tag)
+		le.PutUint16(tmp[2:], e.typ)
+		le.PutUint32(tmp[4:], 1)
+		le.PutUint32(tmp[8:], 0)
+		if e.typ == tShort {
+			le.PutUint16(tmp[8:], uint16(e.val))
+		} else {
+			le.PutUint32(tmp[8:], e.val)
+		}
+		b.Write(tmp[:12])
+	}
+	le.PutUint32(tmp[:4], 0) // no next IFD
+	b.Write(tmp[:4])
+	le.PutUint32(tmp[0:], res)
+	le.PutUint32(tmp[4:], 100)
+	b.Write(tmp[:8])
+	c.packedRows(r, func(row []byte) { b.Write(row) })
+}
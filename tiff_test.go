@@ -0,0 +1,49 @@
+// Copyright 2011 The Go Authors.  All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package qr
+
+import (
+	"encoding/binary"
+	"testing"
+)
+
+func TestTIFF(t *testing.T) {
+	c, err := Encode("hello, world", L)
+	if err != nil {
+		t.Fatal(err)
+	}
+	opts := []RenderOption{ModuleSize(3), DPI(300)}
+	p := c.TIFF(opts...)
+	if string(p[:4]) != "II*\x00" {
+		t.Fatalf("bad TIFF header %q", p[:4])
+	}
+	le := binary.LittleEndian
+	ifd := p[le.Uint32(p[4:]):]
+	tags := make(map[uint16]uint32)
+	for i, n := 0, int(le.Uint16(ifd)); i < n; i++ {
+		e := ifd[2+12*i:]
+		v := le.Uint32(e[8:])
+		if le.Uint16(e[2:]) == 3 {
+			v = uint32(le.Uint16(e[8:]))
+		}
+		tags[le.Uint16(e)] = v
+	}
+	d := int(tags[256])
+	if d != 3*(c.Size+8) || tags[257] != uint32(d) || tags[258] != 1 || tags[259] != 1 || tags[262] != 0 {
+		t.Fatalf("bad TIFF tags %v", tags)
+	}
+	x := p[tags[282]:]
+	if num, den := le.Uint32(x), le.Uint32(x[4:]); num/den != 300 || tags[296] != 2 {
+		t.Errorf("XResolution = %d/%d, unit %d, want 300 dpi", num, den, tags[296])
+	}
+	stride := (d + 7) / 8
+	data := p[tags[273]:]
+	if len(data) != d*stride || tags[279] != uint32(d*stride) {
+		t.Fatalf("strip is %d bytes, count %d, want %d", len(data), tags[279], d*stride)
+	}
+	checkPixels(t, "TIFF", c, opts, d, func(x, y int) bool {
+		return data[y*stride+x/8]&(0x80>>uint(x&7)) != 0
+	})
+}
@@ -0,0 +1,56 @@
+// Copyright 2011 The Go Authors.  All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package qr
+
+// ZPL writer for QR codes.
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+)
+
+// ZPL returns a ZPL II label printing the code as a ^GFA graphic field
+// at the top-left corner of the label.  Each QR pixel is ModuleSize
+// printer dots wide; the margin is included in the graphic.
+// If Darkness is set, the label begins with a ~SD command setting it.
+// Colors are ignored.
+//
+// The graphic is printed exactly as encoded, unlike ^BQN, which
+// would have the printer re-encode the text with its own choices
+// of version, mask, and segmentation.
+func (c *Code) ZPL(opts ...RenderOption) []byte {
+	r := c.render(opts)
+	d := (c.Size + 2*r.margin) * r.scale
+	n := (d + 7) / 8
+	var b bytes.Buffer
+	if r.darkness >= 0 {
+		fmt.Fprintf(&b, "~SD%02d\n", clampInt(r.darkness, 0, 30))
+	}
+	fmt.Fprintf(&b, "^XA\n^FO0,0^GFA,%d,%d,%d,\n", n*d, n*d, n)
+	c.packedRows(r, func(row []byte) {
+		fmt.Fprintf(&b, "%X\n", row)
+	})
+	b.WriteString("^FS\n^XZ\n")
+	return b.Bytes()
+}
+
+// WriteZPL writes a ZPL II label printing the code to w.
+// See ZPL.
+func (c *Code) WriteZPL(w io.Writer, opts ...RenderOption) error {
+	_, err := w.Write(c.ZPL(opts...))
+	return err
+}
+
+// clampInt returns x clamped to the range [lo, hi].
+func clampInt(x, lo, hi int) int {
+	if x < lo {
+		return lo
+	}
+	if x > hi {
+		return hi
+	}
+	return x
+}
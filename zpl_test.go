@@ -0,0 +1,55 @@
+// Copyright 2011 The Go Authors.  All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package qr
+
+import (
+	"encoding/hex"
+	"fmt"
+	"strings"
+	"testing"
+)
+
+func TestZPL(t *testing.T) {
+	c, err := Encode("hello, world", L)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, opts := range [][]RenderOption{
+		{ModuleSize(3)},
+		{ModuleSize(5), Margin(2), Darkness(40)},
+	} {
+		lines := strings.Split(strings.TrimSuffix(string(c.ZPL(opts...)), "\n"), "\n")
+		if c.render(opts).darkness >= 0 {
+			if lines[0] != "~SD30" {
+				t.Errorf("darkness line = %q, want ~SD30", lines[0])
+			}
+			lines = lines[1:]
+		}
+		var total, total2, n int
+		if lines[0] != "^XA" || len(lines) < 4 {
+			t.Fatalf("bad ZPL header %q", lines)
+		}
+		if _, err := fmt.Sscanf(lines[1], "^FO0,0^GFA,%d,%d,%d,", &total, &total2, &n); err != nil {
+			t.Fatalf("bad ^GFA line %q: %v", lines[1], err)
+		}
+		rows := lines[2 : len(lines)-2]
+		if lines[len(lines)-2] != "^FS" || lines[len(lines)-1] != "^XZ" {
+			t.Fatalf("bad ZPL trailer %q", lines[len(lines)-2:])
+		}
+		d := len(rows)
+		if total != total2 || total != n*d || n != (d+7)/8 {
+			t.Fatalf("^GFA sizes %d,%d,%d for %d rows", total, total2, n, d)
+		}
+		data := make([][]byte, d)
+		for i, row := range rows {
+			if data[i], err = hex.DecodeString(row); err != nil || len(data[i]) != n {
+				t.Fatalf("bad row %q: %v", row, err)
+			}
+		}
+		checkPixels(t, "ZPL", c, opts, d, func(x, y int) bool {
+			return data[y][x/8]&(0x80>>uint(x&7)) != 0
+		})
+	}
+}